@@ -0,0 +1,296 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go/v4"
+	"github.com/cloudflare/cloudflare-go/v4/zones"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/zclconf/go-cty/cty"
+)
+
+var adoptCmd = &cobra.Command{
+	Use:    "adopt",
+	Short:  "Match existing resource blocks to live Cloudflare resources and output import blocks for them",
+	Run:    runAdopt(),
+	PreRun: sharedPreRun,
+}
+
+func init() {
+	rootCmd.AddCommand(adoptCmd)
+}
+
+// adoptMatchAttributes are the attributes that identify a live object for
+// each resource type that can be adopted.
+var adoptMatchAttributes = map[string][]string{
+	"cloudflare_dns_record":                          {"name", "type", "content"},
+	"cloudflare_email_routing_rule":                  {"name"},
+	"cloudflare_healthcheck":                         {"name"},
+	"cloudflare_list":                                {"name"},
+	"cloudflare_load_balancer":                       {"name"},
+	"cloudflare_load_balancer_pool":                  {"name"},
+	"cloudflare_notification_policy":                 {"name"},
+	"cloudflare_r2_bucket":                           {"name"},
+	"cloudflare_ruleset":                             {"name", "kind", "phase"},
+	"cloudflare_waiting_room":                        {"name"},
+	"cloudflare_workers_kv_namespace":                {"title"},
+	"cloudflare_workers_route":                       {"pattern"},
+	"cloudflare_zero_trust_access_application":       {"name"},
+	"cloudflare_zero_trust_access_group":             {"name"},
+	"cloudflare_zero_trust_access_identity_provider": {"name"},
+	"cloudflare_zero_trust_access_policy":            {"name"},
+	"cloudflare_zero_trust_gateway_policy":           {"name"},
+	"cloudflare_zero_trust_list":                     {"name"},
+	"cloudflare_zero_trust_tunnel_cloudflared":       {"name"},
+	"cloudflare_zone":                                {"name"},
+}
+
+// adoptMatch is a configured resource matched to a live object.
+type adoptMatch struct {
+	resource configResource
+	id       string
+	data     map[string]interface{}
+}
+
+// adoptFailure is a configured resource that couldn't be matched.
+type adoptFailure struct {
+	resource configResource
+	reason   string
+}
+
+func runAdopt() func(cmd *cobra.Command, args []string) {
+	return func(cmd *cobra.Command, args []string) {
+		zoneID = viper.GetString("zone")
+		accountID = viper.GetString("account")
+
+		tf, _, cleanup := initTerraform()
+		defer cleanup()
+
+		if !strings.HasPrefix(providerVersionString, "5") {
+			log.Fatalf("adopting resources requires v5 of the provider, found %s", providerVersionString)
+		}
+
+		resources, err := parseConfigResources(tf.WorkingDir())
+		if err != nil {
+			log.Fatalf("failed to parse Terraform configuration: %s", err)
+		}
+
+		zoneName := ""
+		if zoneID != "" {
+			zoneName = fetchZoneName(zoneID)
+		}
+
+		byType := make(map[string][]configResource)
+		var types []string
+		for _, c := range resources {
+			if _, ok := byType[c.Type]; !ok {
+				types = append(types, c.Type)
+			}
+			byType[c.Type] = append(byType[c.Type], c)
+		}
+		sort.Strings(types)
+
+		var matches []adoptMatch
+		var failures []adoptFailure
+		for _, resourceType := range types {
+			if _, ok := adoptMatchAttributes[resourceType]; !ok {
+				for _, c := range byType[resourceType] {
+					failures = append(failures, adoptFailure{resource: c, reason: "resource type can't be matched automatically"})
+				}
+				continue
+			}
+
+			live, err := fetchResourcesV5(resourceType, nil)
+			if err != nil {
+				log.WithFields(logrus.Fields{
+					"resource": resourceType,
+				}).Debugf("no live resources found: %s", err)
+			}
+
+			m, f := matchConfigResources(resourceType, byType[resourceType], live, zoneName)
+			matches = append(matches, m...)
+			failures = append(failures, f...)
+		}
+
+		writeAdoptImports(cmd.OutOrStdout(), matches)
+		writeAdoptFailures(cmd.OutOrStderr(), failures)
+	}
+}
+
+// fetchZoneName looks up the name of a zone which DNS record names are
+// relative to.
+func fetchZoneName(id string) string {
+	zone, err := api.Zones.Get(context.Background(), zones.ZoneGetParams{ZoneID: cloudflare.F(id)})
+	if err != nil {
+		log.Fatalf("failed to fetch zone %s: %s", id, err)
+	}
+
+	return zone.Name
+}
+
+// matchConfigResources matches each configured resource to exactly one live
+// object using the identifying attributes of the resource type. Each live
+// object can only be matched once.
+func matchConfigResources(resourceType string, resources []configResource, live []interface{}, zoneName string) ([]adoptMatch, []adoptFailure) {
+	attributes := adoptMatchAttributes[resourceType]
+	claimed := make(map[string]string)
+
+	var matches []adoptMatch
+	var failures []adoptFailure
+	for _, c := range resources {
+		want, err := adoptIdentity(resourceType, attributes, c, zoneName)
+		if err != nil {
+			failures = append(failures, adoptFailure{resource: c, reason: err.Error()})
+			continue
+		}
+
+		var candidates []map[string]interface{}
+		for _, l := range live {
+			data, ok := l.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if adoptValuesMatch(resourceType, want, data) {
+				candidates = append(candidates, data)
+			}
+		}
+
+		switch len(candidates) {
+		case 0:
+			failures = append(failures, adoptFailure{resource: c, reason: "no live object with " + adoptIdentityString(attributes, want)})
+			continue
+		case 1:
+		default:
+			failures = append(failures, adoptFailure{resource: c, reason: fmt.Sprintf("%d live objects with %s", len(candidates), adoptIdentityString(attributes, want))})
+			continue
+		}
+
+		id := resourceIDFromData(candidates[0])
+		if other, ok := claimed[id]; ok {
+			failures = append(failures, adoptFailure{resource: c, reason: "live object already matched by " + other})
+			continue
+		}
+		claimed[id] = c.Address()
+
+		matches = append(matches, adoptMatch{resource: c, id: id, data: candidates[0]})
+	}
+
+	return matches, failures
+}
+
+// adoptIdentity evaluates the identifying attributes of a configured
+// resource. Attributes must be set to literal values so that they can be
+// compared to the API.
+func adoptIdentity(resourceType string, attributes []string, c configResource, zoneName string) (map[string]string, error) {
+	values := make(map[string]string, len(attributes))
+	for _, name := range attributes {
+		attr, ok := c.Body.Attributes[name]
+		if !ok {
+			// DNS records can omit their content when using `data`.
+			if resourceType == "cloudflare_dns_record" && name == "content" {
+				continue
+			}
+			return nil, fmt.Errorf("%s is not set", name)
+		}
+
+		v, diags := attr.Expr.Value(driftEvalContext)
+		if diags.HasErrors() || !v.IsWhollyKnown() || v.IsNull() || v.Type() != cty.String {
+			return nil, fmt.Errorf("%s is not a literal string", name)
+		}
+		values[name] = v.AsString()
+	}
+
+	if resourceType == "cloudflare_dns_record" {
+		values["name"] = dnsRecordFQDN(values["name"], zoneName)
+	}
+
+	return values, nil
+}
+
+// adoptValuesMatch reports whether the live object has the same identifying
+// values as the configured resource.
+func adoptValuesMatch(resourceType string, want map[string]string, data map[string]interface{}) bool {
+	for name, value := range want {
+		live, _ := data[name].(string)
+		if resourceType == "cloudflare_dns_record" {
+			// DNS names are case insensitive and may be written with the
+			// trailing root label.
+			if !strings.EqualFold(strings.TrimSuffix(live, "."), strings.TrimSuffix(value, ".")) {
+				return false
+			}
+			continue
+		}
+		if live != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// dnsRecordFQDN expands a DNS record name written relative to the zone (or
+// as `@` for the apex) into the fully qualified name the API returns.
+func dnsRecordFQDN(name, zoneName string) string {
+	if zoneName == "" {
+		return name
+	}
+	if name == "@" {
+		return zoneName
+	}
+
+	trimmed := strings.TrimSuffix(name, ".")
+	if strings.EqualFold(trimmed, zoneName) || strings.HasSuffix(strings.ToLower(trimmed), "."+strings.ToLower(zoneName)) {
+		return trimmed
+	}
+
+	return trimmed + "." + zoneName
+}
+
+// adoptIdentityString describes identifying values for error messages.
+func adoptIdentityString(attributes []string, values map[string]string) string {
+	parts := make([]string, 0, len(attributes))
+	for _, name := range attributes {
+		if v, ok := values[name]; ok {
+			parts = append(parts, fmt.Sprintf("%s=%q", name, v))
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// writeAdoptImports outputs an import block for each matched resource using
+// the address the resource already has in the configuration.
+func writeAdoptImports(w io.Writer, matches []adoptMatch) {
+	f := hclwrite.NewEmptyFile()
+	for _, m := range matches {
+		appendImportBlock(f.Body(), importEntry{
+			Address:      m.resource.Address(),
+			ID:           buildRawImportAddress(m.resource.Type, m.id, resourceToEndpoint[m.resource.Type]["get"], m.data),
+			resourceType: m.resource.Type,
+			resourceID:   m.id,
+			attributes:   m.data,
+		}, nil)
+		f.Body().AppendNewline()
+	}
+
+	fmt.Fprint(w, string(hclwrite.Format(f.Bytes())))
+}
+
+// writeAdoptFailures lists the resources that couldn't be matched.
+func writeAdoptFailures(w io.Writer, failures []adoptFailure) {
+	if len(failures) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "unable to match %d resources:\n", len(failures))
+	for _, f := range failures {
+		fmt.Fprintf(w, "  %s (%s:%d): %s\n", f.resource.Address(), f.resource.File, f.resource.Line, f.reason)
+	}
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const adoptTestConfig = `
+variable "zone_id" {}
+
+resource "cloudflare_dns_record" "apex" {
+  zone_id = var.zone_id
+  name    = "@"
+  type    = "A"
+  content = "192.0.2.1"
+}
+
+resource "cloudflare_dns_record" "www" {
+  zone_id = var.zone_id
+  name    = "WWW.example.com."
+  type    = "CNAME"
+  content = "example.com"
+}
+
+resource "cloudflare_dns_record" "missing" {
+  zone_id = var.zone_id
+  name    = "missing"
+  type    = "A"
+  content = "192.0.2.9"
+}
+
+resource "cloudflare_dns_record" "dynamic" {
+  zone_id = var.zone_id
+  name    = var.zone_id
+  type    = "A"
+  content = "192.0.2.1"
+}
+
+resource "cloudflare_dns_record" "round_robin" {
+  zone_id = var.zone_id
+  name    = "rr"
+  type    = "A"
+  content = "192.0.2.5"
+}
+`
+
+func TestMatchConfigResources(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "dns.tf"), []byte(adoptTestConfig), 0o644))
+
+	resources, err := parseConfigResources(dir)
+	require.NoError(t, err)
+
+	live := []interface{}{
+		map[string]interface{}{"id": "1", "name": "example.com", "type": "A", "content": "192.0.2.1"},
+		map[string]interface{}{"id": "2", "name": "www.example.com", "type": "CNAME", "content": "example.com"},
+		map[string]interface{}{"id": "3", "name": "www.example.com", "type": "A", "content": "192.0.2.1"},
+		map[string]interface{}{"id": "4", "name": "rr.example.com", "type": "A", "content": "192.0.2.5"},
+		map[string]interface{}{"id": "5", "name": "rr.example.com", "type": "A", "content": "192.0.2.5"},
+	}
+
+	matches, failures := matchConfigResources("cloudflare_dns_record", resources, live, "example.com")
+
+	matched := make(map[string]string)
+	for _, m := range matches {
+		matched[m.resource.Address()] = m.id
+	}
+	assert.Equal(t, map[string]string{
+		"cloudflare_dns_record.apex": "1",
+		"cloudflare_dns_record.www":  "2",
+	}, matched)
+
+	reasons := make(map[string]string)
+	for _, f := range failures {
+		reasons[f.resource.Address()] = f.reason
+	}
+	assert.Equal(t, map[string]string{
+		"cloudflare_dns_record.missing":     `no live object with name="missing.example.com", type="A", content="192.0.2.9"`,
+		"cloudflare_dns_record.dynamic":     "name is not a literal string",
+		"cloudflare_dns_record.round_robin": `2 live objects with name="rr.example.com", type="A", content="192.0.2.5"`,
+	}, reasons)
+}
+
+func TestDNSRecordFQDN(t *testing.T) {
+	tests := map[string]struct {
+		name     string
+		zoneName string
+		expected string
+	}{
+		"apex":             {name: "@", zoneName: "example.com", expected: "example.com"},
+		"relative":         {name: "www", zoneName: "example.com", expected: "www.example.com"},
+		"fully qualified":  {name: "www.example.com", zoneName: "example.com", expected: "www.example.com"},
+		"trailing dot":     {name: "www.example.com.", zoneName: "example.com", expected: "www.example.com"},
+		"zone name":        {name: "example.com", zoneName: "example.com", expected: "example.com"},
+		"unknown zone":     {name: "www", zoneName: "", expected: "www"},
+		"similar suffix":   {name: "notexample.com", zoneName: "example.com", expected: "notexample.com.example.com"},
+		"mixed case apex":  {name: "Example.COM", zoneName: "example.com", expected: "Example.COM"},
+		"nested subdomain": {name: "a.b", zoneName: "example.com", expected: "a.b.example.com"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, dnsRecordFQDN(tc.name, tc.zoneName))
+		})
+	}
+}
+
+func TestWriteAdoptFailures(t *testing.T) {
+	var buf bytes.Buffer
+	writeAdoptFailures(&buf, []adoptFailure{{
+		resource: configResource{Type: "cloudflare_list", Name: "ips", File: "lists.tf", Line: 3},
+		reason:   "name is not set",
+	}})
+
+	assert.Equal(t, "unable to match 1 resources:\n  cloudflare_list.ips (lists.tf:3): name is not set\n", buf.String())
+}
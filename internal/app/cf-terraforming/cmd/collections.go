@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+const (
+	collectionFormatJSON = "json"
+	collectionFormatCSV  = "csv"
+)
+
+// externalizedCollection is a collection moved out of generated configuration
+// into a data file.
+type externalizedCollection struct {
+	Name string
+	Data []byte
+}
+
+// externalizeCollections replaces every top-level list attribute of the
+// resources in f with more than threshold items, such as the `items` of a
+// `cloudflare_list`, with a reference to a data file. CSV is only used when
+// every item is an object with the same string attributes as `csvdecode`
+// returns strings, otherwise the data is written as JSON so that it decodes
+// to the same value as the inline form. referenceDir is the directory the
+// data files are referenced from.
+func externalizeCollections(f *hclwrite.File, threshold int, format, referenceDir string) []externalizedCollection {
+	if threshold <= 0 {
+		return nil
+	}
+
+	var collections []externalizedCollection
+	for _, block := range f.Body().Blocks() {
+		if block.Type() != "resource" || len(block.Labels()) != 2 {
+			continue
+		}
+
+		body := block.Body()
+		attrs := body.Attributes()
+		for _, name := range sortedAttributeNames(attrs) {
+			v, ok := evaluateTokens(attrs[name].Expr().BuildTokens(nil))
+			if !ok || v.IsNull() || !(v.Type().IsTupleType() || v.Type().IsListType() || v.Type().IsSetType()) {
+				continue
+			}
+			if v.LengthInt() <= threshold {
+				continue
+			}
+
+			fileName := placeholderVariableName(block.Labels()[0], block.Labels()[1], name)
+			decode := "jsondecode"
+			var data []byte
+			var err error
+			if format == collectionFormatCSV && csvCompatible(v) {
+				fileName += ".csv"
+				decode = "csvdecode"
+				data, err = collectionCSV(v)
+			} else {
+				fileName += ".json"
+				data, err = collectionJSON(v)
+			}
+			if err != nil {
+				log.Warnf("failed to externalize %s of %s.%s: %s", name, block.Labels()[0], block.Labels()[1], err)
+				continue
+			}
+
+			body.SetAttributeRaw(name, hclwrite.Tokens{{
+				Type:  hclsyntax.TokenIdent,
+				Bytes: []byte(fmt.Sprintf("%s(file(%q))", decode, referenceDir+"/"+fileName)),
+			}})
+			collections = append(collections, externalizedCollection{Name: fileName, Data: data})
+		}
+	}
+
+	return collections
+}
+
+// externalizeGeneratedCollections externalizes large collections when using
+// `--collection-threshold` and writes their data files.
+func externalizeGeneratedCollections(f *hclwrite.File) []generatedDataFile {
+	if collectionThreshold <= 0 {
+		return nil
+	}
+
+	var files []generatedDataFile
+	reference, dir := dataFileDir(collectionDir)
+	for _, c := range externalizeCollections(f, collectionThreshold, collectionFormat, reference) {
+		writeDataFile(filepath.Join(dir, c.Name), c.Data)
+		files = append(files, generatedDataFile{reference: reference + "/" + c.Name, data: c.Data})
+	}
+
+	return files
+}
+
+// csvCompatible reports whether every item of a collection is an object with
+// the same attributes, all of which are non-null strings.
+func csvCompatible(v cty.Value) bool {
+	var columns []string
+	for it := v.ElementIterator(); it.Next(); {
+		_, item := it.Element()
+		if item.IsNull() || !item.Type().IsObjectType() {
+			return false
+		}
+
+		names := make([]string, 0, len(item.Type().AttributeTypes()))
+		for name, ty := range item.Type().AttributeTypes() {
+			if ty != cty.String || item.GetAttr(name).IsNull() {
+				return false
+			}
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		if columns == nil {
+			columns = names
+			continue
+		}
+		if len(names) != len(columns) {
+			return false
+		}
+		for i := range names {
+			if names[i] != columns[i] {
+				return false
+			}
+		}
+	}
+
+	return len(columns) > 0
+}
+
+// collectionCSV encodes a collection of string objects as CSV with a header
+// row of the sorted attribute names.
+func collectionCSV(v cty.Value) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	var columns []string
+	for it := v.ElementIterator(); it.Next(); {
+		_, item := it.Element()
+		if columns == nil {
+			for name := range item.Type().AttributeTypes() {
+				columns = append(columns, name)
+			}
+			sort.Strings(columns)
+			if err := w.Write(columns); err != nil {
+				return nil, err
+			}
+		}
+
+		row := make([]string, len(columns))
+		for i, name := range columns {
+			row[i] = item.GetAttr(name).AsString()
+		}
+		if err := w.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+
+	return buf.Bytes(), w.Error()
+}
+
+// collectionJSON encodes a collection as indented JSON.
+func collectionJSON(v cty.Value) ([]byte, error) {
+	b, err := ctyjson.Marshal(v, v.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, b, "", "  "); err != nil {
+		return nil, err
+	}
+	out.WriteString("\n")
+
+	return out.Bytes(), nil
+}
@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+func inlineAttributeValue(t *testing.T, src, attribute string) cty.Value {
+	t.Helper()
+
+	f := parseTestHCL(t, src)
+	v, ok := evaluateTokens(f.Body().Blocks()[0].Body().GetAttribute(attribute).Expr().BuildTokens(nil))
+	require.True(t, ok)
+
+	return v
+}
+
+func TestExternalizeCollections_CSV(t *testing.T) {
+	src := `resource "cloudflare_list" "terraform_managed_resource_a1" {
+  kind = "ip"
+  items = [{
+    comment = "office, London"
+    ip      = "192.0.2.1"
+    }, {
+    comment = "vpn"
+    ip      = "192.0.2.2"
+    }, {
+    comment = ""
+    ip      = "2001:db8::/32"
+  }]
+}
+`
+	inline := inlineAttributeValue(t, src, "items")
+
+	f := parseTestHCL(t, src)
+	collections := externalizeCollections(f, 2, collectionFormatCSV, "${path.module}")
+	require.Len(t, collections, 1)
+
+	assert.Equal(t, "list_terraform_managed_resource_a1_items.csv", collections[0].Name)
+	assert.Equal(t, `comment,ip
+"office, London",192.0.2.1
+vpn,192.0.2.2
+,2001:db8::/32
+`, string(collections[0].Data))
+	assert.Equal(t, `resource "cloudflare_list" "terraform_managed_resource_a1" {
+  kind  = "ip"
+  items = csvdecode(file("${path.module}/list_terraform_managed_resource_a1_items.csv"))
+}
+`, string(hclwrite.Format(f.Bytes())))
+
+	// csvdecode returns a list rather than a tuple, both of which Terraform
+	// converts to the type of the attribute.
+	decoded, err := stdlib.CSVDecode(cty.StringVal(string(collections[0].Data)))
+	require.NoError(t, err)
+	converted, err := convert.Convert(inline, decoded.Type())
+	require.NoError(t, err)
+	assert.True(t, decoded.RawEquals(converted))
+}
+
+func TestExternalizeCollections_JSON(t *testing.T) {
+	src := `resource "cloudflare_list" "terraform_managed_resource_a1" {
+  kind = "asn"
+  items = [{
+    asn = 13335
+    }, {
+    asn     = 209242
+    comment = "second"
+  }]
+}
+`
+	inline := inlineAttributeValue(t, src, "items")
+
+	// CSV is requested but the items aren't all strings.
+	f := parseTestHCL(t, src)
+	collections := externalizeCollections(f, 1, collectionFormatCSV, "${path.module}/data")
+	require.Len(t, collections, 1)
+
+	assert.Equal(t, "list_terraform_managed_resource_a1_items.json", collections[0].Name)
+	assert.Contains(t, string(hclwrite.Format(f.Bytes())), `items = jsondecode(file("${path.module}/data/list_terraform_managed_resource_a1_items.json"))`)
+
+	decoded, err := stdlib.JSONDecode(cty.StringVal(string(collections[0].Data)))
+	require.NoError(t, err)
+	assert.True(t, decoded.RawEquals(inline))
+}
+
+func TestExternalizeCollections_BelowThreshold(t *testing.T) {
+	src := `resource "cloudflare_list" "terraform_managed_resource_a1" {
+  kind = "ip"
+  items = [{
+    ip = "192.0.2.1"
+  }]
+}
+`
+	f := parseTestHCL(t, src)
+
+	assert.Empty(t, externalizeCollections(f, 1, collectionFormatJSON, "${path.module}"))
+	assert.Empty(t, externalizeCollections(f, 0, collectionFormatJSON, "${path.module}"))
+	assert.Equal(t, src, string(f.Bytes()))
+}
+
+func TestExternalizeGeneratedCollections_MergeInto(t *testing.T) {
+	defer func(threshold int, format, dir, merge string) {
+		collectionThreshold, collectionFormat, collectionDir, mergeIntoDir = threshold, format, dir, merge
+	}(collectionThreshold, collectionFormat, collectionDir, mergeIntoDir)
+	collectionThreshold, collectionFormat, collectionDir = 1, collectionFormatJSON, "data"
+	mergeIntoDir = t.TempDir()
+
+	f := parseTestHCL(t, `resource "cloudflare_list" "terraform_managed_resource_a1" {
+  kind = "asn"
+  items = [{
+    asn = 13335
+    }, {
+    asn = 209242
+  }]
+}
+`)
+	files := externalizeGeneratedCollections(f)
+	require.Len(t, files, 1)
+
+	// the reference is relative to the configuration being merged into,
+	// which is where the file is written.
+	assert.Equal(t, "${path.module}/data/list_terraform_managed_resource_a1_items.json", files[0].reference)
+	data, err := os.ReadFile(filepath.Join(mergeIntoDir, "data", "list_terraform_managed_resource_a1_items.json"))
+	require.NoError(t, err)
+	assert.Equal(t, files[0].data, data)
+}
+
+func TestFinishGeneratedFile_ForEachWithCollections(t *testing.T) {
+	defer func(forEach bool, threshold int, format, dir, forEachDir, merge string) {
+		useForEach, collectionThreshold, collectionFormat, collectionDir, forEachDataDir, mergeIntoDir = forEach, threshold, format, dir, forEachDir, merge
+	}(useForEach, collectionThreshold, collectionFormat, collectionDir, forEachDataDir, mergeIntoDir)
+	useForEach, collectionThreshold, collectionFormat = true, 1, collectionFormatJSON
+	collectionDir, forEachDataDir = "data", "data"
+	mergeIntoDir = t.TempDir()
+
+	f := parseTestHCL(t, `resource "cloudflare_list" "terraform_managed_resource_a1" {
+  account_id = "f037e56e89293a057740de681ac9abbe"
+  kind       = "ip"
+  name       = "office"
+  items = [{
+    ip = "192.0.2.1"
+    }, {
+    ip = "192.0.2.2"
+  }]
+}
+
+resource "cloudflare_list" "terraform_managed_resource_b2" {
+  account_id = "f037e56e89293a057740de681ac9abbe"
+  kind       = "ip"
+  name       = "vpn"
+  items = [{
+    ip = "198.51.100.1"
+    }, {
+    ip = "198.51.100.2"
+  }]
+}
+`)
+	f, files := finishGeneratedFile(f, "cloudflare_list", nil)
+
+	// the items vary by instance so they're collapsed into the for_each
+	// data file rather than externalized beforehand, which would leave
+	// nothing literal to collapse.
+	require.Len(t, files, 1)
+	assert.Equal(t, "${path.module}/data/list.json", files[0].reference)
+	assert.Contains(t, string(hclwrite.Format(f.Bytes())), `for_each   = jsondecode(file("${path.module}/data/list.json"))`)
+	assert.Contains(t, string(hclwrite.Format(f.Bytes())), `items      = each.value.items`)
+
+	data, err := os.ReadFile(filepath.Join(mergeIntoDir, "data", "list.json"))
+	require.NoError(t, err)
+	assert.Equal(t, files[0].data, data)
+	assert.Contains(t, string(data), "198.51.100.2")
+}
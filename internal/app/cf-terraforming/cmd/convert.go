@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/zclconf/go-cty/cty"
+)
+
+var convertCmd = &cobra.Command{
+	Use:   "convert",
+	Short: "Convert deprecated resources into the resources that replace them",
+}
+
+func init() {
+	rootCmd.AddCommand(convertCmd)
+}
+
+// convertPreRun only initialises the API clients when converting live
+// objects. Converting existing configuration doesn't need credentials.
+func convertPreRun(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		accountID = viper.GetString("account")
+		zoneID = viper.GetString("zone")
+		return
+	}
+
+	sharedPreRun(cmd, args)
+}
+
+// convertSource is a resource block of a deprecated type in existing
+// configuration.
+type convertSource struct {
+	Type string
+	Name string
+	Body *hclwrite.Body
+}
+
+// Address is the address of the resource in state.
+func (s convertSource) Address() string {
+	return s.Type + "." + s.Name
+}
+
+// parseConvertSources reads the resource blocks of resourceType from a
+// configuration file or every `.tf` file in a directory.
+func parseConvertSources(path, resourceType string) ([]convertSource, error) {
+	files := []string{path}
+	if info, err := os.Stat(path); err != nil {
+		return nil, err
+	} else if info.IsDir() {
+		if files, err = filepath.Glob(filepath.Join(path, "*.tf")); err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+	}
+
+	var sources []convertSource
+	for _, filename := range files {
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		f, diags := hclwrite.ParseConfig(src, filename, hcl.InitialPos)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+
+		for _, block := range f.Body().Blocks() {
+			if block.Type() != "resource" || len(block.Labels()) != 2 || block.Labels()[0] != resourceType {
+				continue
+			}
+			sources = append(sources, convertSource{Type: resourceType, Name: block.Labels()[1], Body: block.Body()})
+		}
+	}
+
+	return sources, nil
+}
+
+// convertedRuleAttributes are the attributes of the rules of an existing
+// entrypoint ruleset kept when converted rules are merged into it.
+var convertedRuleAttributes = []string{"action", "action_parameters", "description", "enabled", "exposed_credential_check", "expression", "logging", "ratelimit", "ref"}
+
+// zoneEntrypoints fetches the existing entrypoint ruleset of each of phases
+// with converted rules, keyed by phase. A zone can only have one entrypoint
+// ruleset per phase so the converted rules must be merged into it.
+func zoneEntrypoints(zone string, phases []string, rules map[string][]map[string]interface{}) map[string]map[string]interface{} {
+	entrypoints := make(map[string]map[string]interface{})
+	for _, phase := range phases {
+		if len(rules[phase]) == 0 {
+			continue
+		}
+
+		ruleset, err := fetchZoneEntrypoint(zone, phase)
+		if err != nil {
+			log.Fatalf("failed to fetch the %s entrypoint ruleset: %s", phase, err)
+		}
+		if ruleset != nil {
+			entrypoints[phase] = ruleset
+		}
+	}
+
+	return entrypoints
+}
+
+// entrypointRules returns the rules of an existing entrypoint ruleset in the
+// form they are written to configuration.
+func entrypointRules(ruleset map[string]interface{}) []map[string]interface{} {
+	processRulesetsV5([]interface{}{ruleset})
+
+	items, _ := ruleset["rules"].([]interface{})
+	rules := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		r, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		rule := make(map[string]interface{})
+		for _, name := range convertedRuleAttributes {
+			if r[name] != nil {
+				rule[name] = r[name]
+			}
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+// writeConvertedRulesets outputs a zone phase entrypoint ruleset for each of
+// phases with converted rules. The resources are named after the prefix and
+// phase and title describes what the rules were converted from. When the zone
+// already has an entrypoint for a phase, the converted rules are added to its
+// existing rules, before them for the phases in convertedFirst and after them
+// otherwise, and an import block adopts it.
+func writeConvertedRulesets(w io.Writer, zone, prefix, title string, phases []string, rules map[string][]map[string]interface{}, entrypoints map[string]map[string]interface{}, convertedFirst []string) {
+	f := hclwrite.NewEmptyFile()
+	body := f.Body()
+
+	for _, phase := range phases {
+		if len(rules[phase]) == 0 {
+			continue
+		}
+
+		name := "Converted from " + title
+		phaseRules := rules[phase]
+		existing := entrypoints[phase]
+		if existing != nil {
+			if n, ok := existing["name"].(string); ok && n != "" {
+				name = n
+			}
+			if contains(convertedFirst, phase) {
+				phaseRules = append(phaseRules, entrypointRules(existing)...)
+			} else {
+				phaseRules = append(entrypointRules(existing), phaseRules...)
+			}
+		}
+
+		values := make([]cty.Value, 0, len(phaseRules))
+		for _, r := range phaseRules {
+			values = append(values, processExpression(r))
+		}
+
+		address := prefix + "_" + phase
+		resource := body.AppendNewBlock("resource", []string{"cloudflare_ruleset", address}).Body()
+		resource.SetAttributeValue("kind", cty.StringVal("zone"))
+		resource.SetAttributeValue("name", cty.StringVal(name))
+		resource.SetAttributeValue("phase", cty.StringVal(phase))
+		resource.SetAttributeValue("zone_id", cty.StringVal(zone))
+		resource.SetAttributeValue("rules", cty.TupleVal(values))
+		body.AppendNewline()
+
+		if existing != nil {
+			imp := body.AppendNewBlock("import", nil).Body()
+			imp.SetAttributeTraversal("to", addressTraversal("cloudflare_ruleset."+address))
+			imp.SetAttributeValue("id", cty.StringVal(fmt.Sprintf("zones/%s/%v", zone, existing["id"])))
+			body.AppendNewline()
+		}
+	}
+
+	fmt.Fprint(w, string(hclwrite.Format(f.Bytes())))
+}
+
+// writeMergedEntrypoints lists the existing entrypoint rulesets the converted
+// rules were merged into.
+func writeMergedEntrypoints(w io.Writer, phases []string, entrypoints map[string]map[string]interface{}, convertedFirst []string) {
+	for _, phase := range phases {
+		existing, ok := entrypoints[phase]
+		if !ok {
+			continue
+		}
+
+		position := "after"
+		if contains(convertedFirst, phase) {
+			position = "before"
+		}
+		rules, _ := existing["rules"].([]interface{})
+		fmt.Fprintf(w, "%s: added %s the %d rules of the existing entrypoint ruleset %v, check the order of the rules and remove the import block if the ruleset is already managed by Terraform\n", phase, position, len(rules), existing["id"])
+	}
+}
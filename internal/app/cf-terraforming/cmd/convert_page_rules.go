@@ -0,0 +1,620 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var convertPageRulesCmd = &cobra.Command{
+	Use:   "page-rules",
+	Short: "Convert the page rules of a zone into equivalent cloudflare_ruleset rules",
+	Long: `Convert the page rules of the zone set with --zone into cloudflare_ruleset
+rules in the cache settings, redirect, config and origin phases. Each rule
+matches the target URL pattern of the page rule it was converted from. Actions
+with no equivalent are reported.`,
+	Args:   cobra.NoArgs,
+	Run:    runConvertPageRules(),
+	PreRun: convertPreRun,
+}
+
+func init() {
+	convertCmd.AddCommand(convertPageRulesCmd)
+}
+
+// pageRulePhase is a ruleset phase that page rule actions are converted into.
+type pageRulePhase struct {
+	phase  string
+	action string
+	// firstMatch is set when only the first matching rule is executed, such
+	// as for redirects. Otherwise later rules override earlier ones.
+	firstMatch bool
+}
+
+// pageRulePhases are the phases page rules are converted into in the order
+// their rulesets are output.
+var pageRulePhases = []pageRulePhase{
+	{phase: "http_request_dynamic_redirect", action: "redirect", firstMatch: true},
+	{phase: "http_request_origin", action: "route"},
+	{phase: "http_config_settings", action: "set_config"},
+	{phase: "http_request_cache_settings", action: "set_cache_settings"},
+}
+
+// pageRuleConfigSettings maps page rule actions to the equivalent
+// `set_config` parameter. Actions with an on or off value are converted to
+// a boolean.
+var pageRuleConfigSettings = map[string]string{
+	"automatic_https_rewrites": "automatic_https_rewrites",
+	"browser_check":            "bic",
+	"disable_apps":             "disable_apps",
+	"disable_zaraz":            "disable_zaraz",
+	"email_obfuscation":        "email_obfuscation",
+	"hotlink_protection":       "hotlink_protection",
+	"mirage":                   "mirage",
+	"opportunistic_encryption": "opportunistic_encryption",
+	"polish":                   "polish",
+	"rocket_loader":            "rocket_loader",
+	"security_level":           "security_level",
+	"server_side_exclude":      "server_side_excludes",
+	"ssl":                      "ssl",
+}
+
+// pageRule is a page rule as returned by the API once prepared for
+// generation, with its actions flattened into a map.
+type pageRule struct {
+	ID       string
+	Target   string
+	Priority int
+	Status   string
+	Actions  map[string]interface{}
+}
+
+// unsupportedPageRuleAction is a page rule action that has no equivalent in
+// rulesets.
+type unsupportedPageRuleAction struct {
+	Target string
+	Action string
+	Reason string
+}
+
+// convertedRule is a ruleset rule converted from a page rule.
+type convertedRule struct {
+	priority int
+	rule     map[string]interface{}
+}
+
+func runConvertPageRules() func(cmd *cobra.Command, args []string) {
+	return func(cmd *cobra.Command, args []string) {
+		if zoneID == "" {
+			log.Fatal("converting page rules requires --zone")
+		}
+
+		data, err := fetchResourcesV5("cloudflare_page_rule", nil)
+		if err != nil {
+			log.Fatalf("failed to fetch page rules: %s", err)
+		}
+
+		rules := pageRulesFromData(data)
+		phases, unsupported := convertPageRules(rules)
+
+		entrypoints := zoneEntrypoints(zoneID, pageRulePhaseNames(), phases)
+		writePageRuleRulesets(cmd.OutOrStdout(), zoneID, phases, entrypoints)
+		writePageRulesSummary(cmd.OutOrStderr(), rules, phases, unsupported)
+		writeMergedEntrypoints(cmd.OutOrStderr(), pageRulePhaseNames(), entrypoints, pageRuleConvertedFirst())
+	}
+}
+
+// pageRulesFromData reads the page rules from the prepared API response.
+func pageRulesFromData(data []interface{}) []pageRule {
+	var rules []pageRule
+	for _, d := range data {
+		m, ok := d.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		r := pageRule{Actions: map[string]interface{}{}}
+		r.ID, _ = m["id"].(string)
+		r.Target, _ = m["target"].(string)
+		r.Status, _ = m["status"].(string)
+		if p, ok := m["priority"].(float64); ok {
+			r.Priority = int(p)
+		}
+		if actions, ok := m["actions"].(map[string]interface{}); ok {
+			r.Actions = actions
+		}
+		rules = append(rules, r)
+	}
+
+	return rules
+}
+
+// convertPageRules converts page rules into rules for each phase. Only the
+// highest priority page rule matching a request is applied, whereas every
+// matching ruleset rule is executed, so each rule excludes the requests
+// matched by the active page rules with a higher priority. Rules are also
+// ordered so that the highest priority page rule takes precedence: first in
+// phases where only the first match is executed and last in the others.
+func convertPageRules(rules []pageRule) (map[string][]map[string]interface{}, []unsupportedPageRuleAction) {
+	converted := make(map[string][]convertedRule)
+	var unsupported []unsupportedPageRuleAction
+
+	for _, r := range rules {
+		params, u := convertPageRuleActions(r)
+		unsupported = append(unsupported, u...)
+
+		for _, p := range pageRulePhases {
+			parameters, ok := params.phases[p.phase]
+			if !ok {
+				continue
+			}
+
+			conditions := pageRuleConditions(r.Target)
+			if p.phase == "http_request_dynamic_redirect" && params.httpsOnly && !contains(conditions, "not ssl") {
+				conditions = append(conditions, "not ssl")
+			}
+			conditions = append(conditions, higherPriorityExclusions(rules, r)...)
+
+			converted[p.phase] = append(converted[p.phase], convertedRule{
+				priority: r.Priority,
+				rule: map[string]interface{}{
+					"action":            p.action,
+					"action_parameters": parameters,
+					"description":       "Converted from the page rule for " + r.Target,
+					"enabled":           r.Status == "active",
+					"expression":        joinConditions(conditions),
+				},
+			})
+		}
+	}
+
+	phases := make(map[string][]map[string]interface{})
+	for _, p := range pageRulePhases {
+		c := converted[p.phase]
+		sort.SliceStable(c, func(i, j int) bool {
+			if p.firstMatch {
+				return c[i].priority > c[j].priority
+			}
+			return c[i].priority < c[j].priority
+		})
+		for _, r := range c {
+			phases[p.phase] = append(phases[p.phase], r.rule)
+		}
+	}
+
+	return phases, unsupported
+}
+
+// higherPriorityExclusions returns a condition excluding the requests matched
+// by each active page rule with a higher priority than r, highest first.
+func higherPriorityExclusions(rules []pageRule, r pageRule) []string {
+	var higher []pageRule
+	for _, h := range rules {
+		if h.Priority > r.Priority && h.Status == "active" {
+			higher = append(higher, h)
+		}
+	}
+	sort.SliceStable(higher, func(i, j int) bool {
+		return higher[i].Priority > higher[j].Priority
+	})
+
+	exclusions := make([]string, 0, len(higher))
+	for _, h := range higher {
+		exclusions = append(exclusions, "not ("+pageRuleExpression(h.Target)+")")
+	}
+
+	return exclusions
+}
+
+// pageRuleParameters are the action parameters of each phase a single page
+// rule is converted into.
+type pageRuleParameters struct {
+	phases map[string]map[string]interface{}
+	// httpsOnly is set when redirecting to HTTPS, which only applies to
+	// requests that aren't already using it.
+	httpsOnly bool
+}
+
+// set sets a nested action parameter of a phase.
+func (p *pageRuleParameters) set(phase string, value interface{}, path ...string) {
+	if p.phases[phase] == nil {
+		p.phases[phase] = make(map[string]interface{})
+	}
+
+	m := p.phases[phase]
+	for _, key := range path[:len(path)-1] {
+		next, ok := m[key].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			m[key] = next
+		}
+		m = next
+	}
+	m[path[len(path)-1]] = value
+}
+
+// convertPageRuleActions maps each action of a page rule to the action
+// parameters of the phase that replaces it.
+func convertPageRuleActions(r pageRule) (*pageRuleParameters, []unsupportedPageRuleAction) {
+	params := &pageRuleParameters{phases: make(map[string]map[string]interface{})}
+	var unsupported []unsupportedPageRuleAction
+	unsupportedAction := func(action, reason string) {
+		unsupported = append(unsupported, unsupportedPageRuleAction{Target: r.Target, Action: action, Reason: reason})
+	}
+
+	const (
+		cache    = "http_request_cache_settings"
+		config   = "http_config_settings"
+		origin   = "http_request_origin"
+		redirect = "http_request_dynamic_redirect"
+	)
+
+	actions := make([]string, 0, len(r.Actions))
+	for action := range r.Actions {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+
+	for _, action := range actions {
+		value := r.Actions[action]
+
+		if setting, ok := pageRuleConfigSettings[action]; ok {
+			if b, ok := onOff(value); ok {
+				params.set(config, b, setting)
+			} else {
+				params.set(config, value, setting)
+			}
+			continue
+		}
+
+		switch action {
+		case "always_use_https":
+			params.httpsOnly = true
+			params.set(redirect, map[string]interface{}{
+				"status_code":           301,
+				"target_url":            map[string]interface{}{"expression": `concat("https://", http.host, http.request.uri.path)`},
+				"preserve_query_string": true,
+			}, "from_value")
+		case "forwarding_url":
+			forwarding, ok := value.(map[string]interface{})
+			if !ok {
+				unsupportedAction(action, "unexpected value")
+				continue
+			}
+			params.set(redirect, map[string]interface{}{
+				"status_code":           forwarding["status_code"],
+				"target_url":            forwardingTargetURL(r.Target, fmt.Sprint(forwarding["url"])),
+				"preserve_query_string": true,
+			}, "from_value")
+		case "host_header_override":
+			params.set(origin, value, "host_header")
+		case "resolve_override":
+			params.set(origin, value, "origin", "host")
+		case "disable_performance":
+			params.set(config, false, "mirage")
+			params.set(config, false, "rocket_loader")
+			params.set(config, "off", "polish")
+		case "cache_level":
+			switch value {
+			case "bypass":
+				params.set(cache, false, "cache")
+			case "cache_everything":
+				params.set(cache, true, "cache")
+			case "simplified":
+				params.set(cache, true, "cache_key", "custom_key", "query_string", "exclude", "all")
+			case "aggressive":
+				// standard caching is the default.
+			default:
+				unsupportedAction(action, fmt.Sprintf("cache level %v has no equivalent", value))
+			}
+		case "edge_cache_ttl":
+			params.set(cache, "override_origin", "edge_ttl", "mode")
+			params.set(cache, value, "edge_ttl", "default")
+		case "browser_cache_ttl":
+			if ttl, ok := value.(float64); ok && ttl == 0 {
+				params.set(cache, "respect_origin", "browser_ttl", "mode")
+				continue
+			}
+			params.set(cache, "override_origin", "browser_ttl", "mode")
+			params.set(cache, value, "browser_ttl", "default")
+		case "cache_ttl_by_status":
+			ttls, err := statusCodeTTLs(value)
+			if err != nil {
+				unsupportedAction(action, err.Error())
+				continue
+			}
+			if params.phases[cache] == nil || params.phases[cache]["edge_ttl"] == nil {
+				params.set(cache, "respect_origin", "edge_ttl", "mode")
+			}
+			params.set(cache, ttls, "edge_ttl", "status_code_ttl")
+		case "cache_by_device_type":
+			if b, ok := onOff(value); ok {
+				params.set(cache, b, "cache_key", "cache_by_device_type")
+			}
+		case "cache_deception_armor":
+			if b, ok := onOff(value); ok {
+				params.set(cache, b, "cache_key", "cache_deception_armor")
+			}
+		case "sort_query_string_for_cache":
+			if b, ok := onOff(value); ok {
+				params.set(cache, b, "cache_key", "ignore_query_strings_order")
+			}
+		case "cache_key_fields":
+			fields, ok := value.(map[string]interface{})
+			if !ok {
+				unsupportedAction(action, "unexpected value")
+				continue
+			}
+			params.set(cache, cacheCustomKey(fields), "cache_key", "custom_key")
+		case "explicit_cache_control":
+			if b, ok := onOff(value); ok {
+				params.set(cache, b, "origin_cache_control")
+			}
+		case "origin_error_page_pass_thru":
+			if b, ok := onOff(value); ok {
+				params.set(cache, b, "origin_error_page_passthru")
+			}
+		case "respect_strong_etag":
+			if b, ok := onOff(value); ok {
+				params.set(cache, b, "respect_strong_etags")
+			}
+		default:
+			unsupportedAction(action, "no equivalent ruleset action")
+		}
+	}
+
+	return params, unsupported
+}
+
+// onOff converts an on or off page rule value to a boolean.
+func onOff(value interface{}) (bool, bool) {
+	switch value {
+	case "on":
+		return true, true
+	case "off":
+		return false, true
+	}
+
+	return false, false
+}
+
+// statusCodeTTLs converts the remapped `cache_ttl_by_status` of a page rule,
+// a list of codes such as `404` or `500-599` and their TTL, into
+// `status_code_ttl` entries.
+func statusCodeTTLs(value interface{}) ([]interface{}, error) {
+	var entries []map[string]interface{}
+	switch v := value.(type) {
+	case []map[string]interface{}:
+		entries = v
+	case []interface{}:
+		for _, e := range v {
+			if m, ok := e.(map[string]interface{}); ok {
+				entries = append(entries, m)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unexpected value %v", value)
+	}
+
+	var ttls []interface{}
+	for _, e := range entries {
+		codes := fmt.Sprint(e["codes"])
+		ttl := map[string]interface{}{"value": e["ttl"]}
+
+		from, to, isRange := strings.Cut(codes, "-")
+		f, err := strconv.Atoi(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid status codes %q", codes)
+		}
+		if isRange {
+			t, err := strconv.Atoi(to)
+			if err != nil {
+				return nil, fmt.Errorf("invalid status codes %q", codes)
+			}
+			ttl["status_code_range"] = map[string]interface{}{"from": f, "to": t}
+		} else {
+			ttl["status_code"] = f
+		}
+		ttls = append(ttls, ttl)
+	}
+
+	return ttls, nil
+}
+
+// cacheCustomKey converts the remapped `cache_key_fields` of a page rule into
+// a custom cache key. An ignored query string excludes all parameters.
+func cacheCustomKey(fields map[string]interface{}) map[string]interface{} {
+	key := make(map[string]interface{})
+	for _, name := range []string{"cookie", "header", "host", "user"} {
+		if v, ok := fields[name].(map[string]interface{}); ok && len(v) > 0 {
+			key[name] = v
+		}
+	}
+
+	query, ok := fields["query_string"].(map[string]interface{})
+	if !ok {
+		return key
+	}
+	switch {
+	case query["ignore"] == true:
+		key["query_string"] = map[string]interface{}{"exclude": map[string]interface{}{"all": true}}
+	case query["ignore"] == false && query["include"] == nil && query["exclude"] == nil:
+		key["query_string"] = map[string]interface{}{"include": map[string]interface{}{"all": true}}
+	default:
+		qs := make(map[string]interface{})
+		for _, name := range []string{"include", "exclude"} {
+			if list, ok := query[name].([]interface{}); ok && len(list) > 0 {
+				qs[name] = map[string]interface{}{"list": list}
+			}
+		}
+		if len(qs) > 0 {
+			key["query_string"] = qs
+		}
+	}
+
+	return key
+}
+
+// pageRuleTarget splits a page rule target URL pattern into its scheme, if
+// any, host and path. The path of a target without one is `/`.
+func pageRuleTarget(target string) (scheme, host, path string) {
+	rest := target
+	if s, r, ok := strings.Cut(target, "://"); ok {
+		scheme, rest = strings.ToLower(s), r
+	}
+
+	host, path, ok := strings.Cut(rest, "/")
+	if !ok {
+		return scheme, strings.ToLower(host), "/"
+	}
+
+	return scheme, strings.ToLower(host), "/" + path
+}
+
+// pageRuleExpression builds a wirefilter expression matching the same
+// requests as a page rule target URL pattern.
+func pageRuleExpression(target string) string {
+	return joinConditions(pageRuleConditions(target))
+}
+
+// pageRuleConditions returns the conditions a request must match for a page
+// rule target URL pattern. The host and path are matched separately where
+// possible and the full URI otherwise, such as for targets with a port or
+// query string.
+func pageRuleConditions(target string) []string {
+	scheme, host, path := pageRuleTarget(target)
+	if strings.Contains(host, ":") || strings.Contains(path, "?") {
+		return []string{"http.request.full_uri wildcard " + wirefilterString(pageRuleFullURI(target))}
+	}
+
+	var conditions []string
+	switch scheme {
+	case "https":
+		conditions = append(conditions, "ssl")
+	case "http":
+		conditions = append(conditions, "not ssl")
+	}
+
+	switch {
+	case host == "*":
+	case strings.Contains(host, "*"):
+		conditions = append(conditions, "http.host wildcard "+wirefilterString(host))
+	default:
+		conditions = append(conditions, "http.host eq "+wirefilterString(host))
+	}
+
+	switch {
+	case path == "/*":
+	case strings.Contains(path, "*"):
+		conditions = append(conditions, "http.request.uri.path wildcard "+wirefilterString(path))
+	default:
+		conditions = append(conditions, "http.request.uri.path eq "+wirefilterString(path))
+	}
+
+	return conditions
+}
+
+// joinConditions builds an expression that requires every condition to
+// match. Without any conditions every request matches.
+func joinConditions(conditions []string) string {
+	if len(conditions) == 0 {
+		return "true"
+	}
+
+	return strings.Join(conditions, " and ")
+}
+
+// pageRuleFullURI converts a page rule target into a pattern for the full
+// URI, which always includes the scheme.
+func pageRuleFullURI(target string) string {
+	scheme, host, path := pageRuleTarget(target)
+	if scheme == "" {
+		scheme = "*"
+	}
+
+	return scheme + "://" + host + path
+}
+
+// wirefilterString quotes a string for a wirefilter expression.
+func wirefilterString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// forwardingReferences matches the `$1` style references to the wildcards
+// of a page rule target in a forwarding URL.
+var forwardingReferences = regexp.MustCompile(`\$(\d+)`)
+
+// forwardingTargetURL converts the URL of a forwarding page rule into a
+// redirect target. URLs that reference the wildcards of the target are
+// converted into a `wildcard_replace` of the full URI, which numbers the
+// wildcards the same way once a wildcard for a missing scheme is accounted
+// for.
+func forwardingTargetURL(target, forwardURL string) map[string]interface{} {
+	if !forwardingReferences.MatchString(forwardURL) {
+		return map[string]interface{}{"value": forwardURL}
+	}
+
+	offset := 0
+	if scheme, _, _ := pageRuleTarget(target); scheme == "" {
+		offset = 1
+	}
+	replacement := forwardingReferences.ReplaceAllStringFunc(forwardURL, func(ref string) string {
+		n, _ := strconv.Atoi(ref[1:])
+		return fmt.Sprintf("${%d}", n+offset)
+	})
+
+	return map[string]interface{}{
+		"expression": fmt.Sprintf("wildcard_replace(http.request.full_uri, %s, %s)", wirefilterString(pageRuleFullURI(target)), wirefilterString(replacement)),
+	}
+}
+
+// pageRulePhaseNames returns the names of pageRulePhases.
+func pageRulePhaseNames() []string {
+	names := make([]string, 0, len(pageRulePhases))
+	for _, p := range pageRulePhases {
+		names = append(names, p.phase)
+	}
+
+	return names
+}
+
+// pageRuleConvertedFirst returns the phases where the converted rules are
+// placed before the rules of an existing entrypoint. Rules take precedence
+// over page rules so the existing rules must run last, unless only the first
+// match is executed.
+func pageRuleConvertedFirst() []string {
+	var phases []string
+	for _, p := range pageRulePhases {
+		if !p.firstMatch {
+			phases = append(phases, p.phase)
+		}
+	}
+
+	return phases
+}
+
+// writePageRuleRulesets outputs a phase entrypoint ruleset for each phase
+// with converted rules, merged into the existing entrypoints.
+func writePageRuleRulesets(w io.Writer, zone string, phases map[string][]map[string]interface{}, entrypoints map[string]map[string]interface{}) {
+	writeConvertedRulesets(w, zone, "page_rules", "Page Rules", pageRulePhaseNames(), phases, entrypoints, pageRuleConvertedFirst())
+}
+
+// writePageRulesSummary lists the actions that couldn't be converted and the
+// number of rules in each phase.
+func writePageRulesSummary(w io.Writer, rules []pageRule, phases map[string][]map[string]interface{}, unsupported []unsupportedPageRuleAction) {
+	for _, u := range unsupported {
+		fmt.Fprintf(w, "%s: unable to convert %s (%s)\n", u.Target, u.Action, u.Reason)
+	}
+
+	for _, p := range pageRulePhases {
+		if n := len(phases[p.phase]); n > 0 {
+			fmt.Fprintf(w, "%s: %d rules\n", p.phase, n)
+		}
+	}
+	fmt.Fprintf(w, "%d page rules converted\n", len(rules))
+}
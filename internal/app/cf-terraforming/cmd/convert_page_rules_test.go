@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPageRuleExpression(t *testing.T) {
+	tests := map[string]string{
+		"example.com/*":                   `http.host eq "example.com"`,
+		"*example.com/images/*":           `http.host wildcard "*example.com" and http.request.uri.path wildcard "/images/*"`,
+		"https://www.example.com/about":   `ssl and http.host eq "www.example.com" and http.request.uri.path eq "/about"`,
+		"http://Example.com":              `not ssl and http.host eq "example.com" and http.request.uri.path eq "/"`,
+		"*/*":                             `true`,
+		"example.com:8443/*":              `http.request.full_uri wildcard "*://example.com:8443/*"`,
+		"example.com/search?q=*":          `http.request.full_uri wildcard "*://example.com/search?q=*"`,
+		`example.com/say"hello"`:          `http.host eq "example.com" and http.request.uri.path eq "/say\"hello\""`,
+		"https://*.example.com/blog/*.js": `ssl and http.host wildcard "*.example.com" and http.request.uri.path wildcard "/blog/*.js"`,
+	}
+
+	for target, expected := range tests {
+		assert.Equal(t, expected, pageRuleExpression(target), target)
+	}
+}
+
+func TestForwardingTargetURL(t *testing.T) {
+	assert.Equal(t, map[string]interface{}{"value": "https://www.example.com/"}, forwardingTargetURL("example.com/*", "https://www.example.com/"))
+	assert.Equal(t, map[string]interface{}{
+		"expression": `wildcard_replace(http.request.full_uri, "*://example.com/*", "https://www.example.com/${2}")`,
+	}, forwardingTargetURL("example.com/*", "https://www.example.com/$1"))
+	assert.Equal(t, map[string]interface{}{
+		"expression": `wildcard_replace(http.request.full_uri, "https://*.example.com/*", "https://example.com/${1}/${2}")`,
+	}, forwardingTargetURL("https://*.example.com/*", "https://example.com/$1/$2"))
+}
+
+func TestConvertPageRules(t *testing.T) {
+	rules := pageRulesFromData([]interface{}{
+		map[string]interface{}{
+			"id": "a", "target": "*example.com/_assets/*", "priority": float64(2), "status": "active",
+			"actions": map[string]interface{}{
+				"cache_level":            "cache_everything",
+				"edge_cache_ttl":         float64(7200),
+				"explicit_cache_control": "on",
+				"host_header_override":   "assets.example.com",
+				"resolve_override":       "assets-s3.example.com",
+				"ssl":                    "full",
+				"cache_ttl_by_status": []map[string]interface{}{
+					{"codes": "404", "ttl": float64(30)},
+					{"codes": "500-599", "ttl": 0},
+				},
+			},
+		},
+		map[string]interface{}{
+			"id": "b", "target": "example.com/*", "priority": float64(3), "status": "disabled",
+			"actions": map[string]interface{}{
+				"email_obfuscation": "off",
+				"cache_level":       "bypass",
+				"minify":            map[string]interface{}{"css": "on"},
+			},
+		},
+		map[string]interface{}{
+			"id": "c", "target": "http://example.com/*", "priority": float64(1), "status": "active",
+			"actions": map[string]interface{}{"always_use_https": true},
+		},
+		map[string]interface{}{
+			"id": "d", "target": "old.example.com/*", "priority": float64(4), "status": "active",
+			"actions": map[string]interface{}{
+				"forwarding_url": map[string]interface{}{"url": "https://example.com/$1", "status_code": float64(301)},
+			},
+		},
+	})
+
+	phases, unsupported := convertPageRules(rules)
+
+	assert.Equal(t, []unsupportedPageRuleAction{
+		{Target: "example.com/*", Action: "minify", Reason: "no equivalent ruleset action"},
+	}, unsupported)
+
+	var buf bytes.Buffer
+	writePageRuleRulesets(&buf, "0da42c8d2132a9ddaf714f9e7c920711", phases, nil)
+	assert.Equal(t, `resource "cloudflare_ruleset" "page_rules_http_request_dynamic_redirect" {
+  kind    = "zone"
+  name    = "Converted from Page Rules"
+  phase   = "http_request_dynamic_redirect"
+  zone_id = "0da42c8d2132a9ddaf714f9e7c920711"
+  rules = [{
+    action = "redirect"
+    action_parameters = {
+      from_value = {
+        preserve_query_string = true
+        status_code           = 301
+        target_url = {
+          expression = "wildcard_replace(http.request.full_uri, \"*://old.example.com/*\", \"https://example.com/$${2}\")"
+        }
+      }
+    }
+    description = "Converted from the page rule for old.example.com/*"
+    enabled     = true
+    expression  = "http.host eq \"old.example.com\""
+    }, {
+    action = "redirect"
+    action_parameters = {
+      from_value = {
+        preserve_query_string = true
+        status_code           = 301
+        target_url = {
+          expression = "concat(\"https://\", http.host, http.request.uri.path)"
+        }
+      }
+    }
+    description = "Converted from the page rule for http://example.com/*"
+    enabled     = true
+    expression  = "not ssl and http.host eq \"example.com\" and not (http.host eq \"old.example.com\") and not (http.host wildcard \"*example.com\" and http.request.uri.path wildcard \"/_assets/*\")"
+  }]
+}
+
+resource "cloudflare_ruleset" "page_rules_http_request_origin" {
+  kind    = "zone"
+  name    = "Converted from Page Rules"
+  phase   = "http_request_origin"
+  zone_id = "0da42c8d2132a9ddaf714f9e7c920711"
+  rules = [{
+    action = "route"
+    action_parameters = {
+      host_header = "assets.example.com"
+      origin = {
+        host = "assets-s3.example.com"
+      }
+    }
+    description = "Converted from the page rule for *example.com/_assets/*"
+    enabled     = true
+    expression  = "http.host wildcard \"*example.com\" and http.request.uri.path wildcard \"/_assets/*\" and not (http.host eq \"old.example.com\")"
+  }]
+}
+
+resource "cloudflare_ruleset" "page_rules_http_config_settings" {
+  kind    = "zone"
+  name    = "Converted from Page Rules"
+  phase   = "http_config_settings"
+  zone_id = "0da42c8d2132a9ddaf714f9e7c920711"
+  rules = [{
+    action = "set_config"
+    action_parameters = {
+      ssl = "full"
+    }
+    description = "Converted from the page rule for *example.com/_assets/*"
+    enabled     = true
+    expression  = "http.host wildcard \"*example.com\" and http.request.uri.path wildcard \"/_assets/*\" and not (http.host eq \"old.example.com\")"
+    }, {
+    action = "set_config"
+    action_parameters = {
+      email_obfuscation = false
+    }
+    description = "Converted from the page rule for example.com/*"
+    enabled     = false
+    expression  = "http.host eq \"example.com\" and not (http.host eq \"old.example.com\")"
+  }]
+}
+
+resource "cloudflare_ruleset" "page_rules_http_request_cache_settings" {
+  kind    = "zone"
+  name    = "Converted from Page Rules"
+  phase   = "http_request_cache_settings"
+  zone_id = "0da42c8d2132a9ddaf714f9e7c920711"
+  rules = [{
+    action = "set_cache_settings"
+    action_parameters = {
+      cache = true
+      edge_ttl = {
+        default = 7200
+        mode    = "override_origin"
+        status_code_ttl = [{
+          status_code = 404
+          value       = 30
+          }, {
+          status_code_range = {
+            from = 500
+            to   = 599
+          }
+          value = 0
+        }]
+      }
+      origin_cache_control = true
+    }
+    description = "Converted from the page rule for *example.com/_assets/*"
+    enabled     = true
+    expression  = "http.host wildcard \"*example.com\" and http.request.uri.path wildcard \"/_assets/*\" and not (http.host eq \"old.example.com\")"
+    }, {
+    action = "set_cache_settings"
+    action_parameters = {
+      cache = false
+    }
+    description = "Converted from the page rule for example.com/*"
+    enabled     = false
+    expression  = "http.host eq \"example.com\" and not (http.host eq \"old.example.com\")"
+  }]
+}
+
+`, buf.String())
+
+	buf.Reset()
+	writePageRulesSummary(&buf, rules, phases, unsupported)
+	assert.Equal(t, `example.com/*: unable to convert minify (no equivalent ruleset action)
+http_request_dynamic_redirect: 2 rules
+http_request_origin: 1 rules
+http_config_settings: 2 rules
+http_request_cache_settings: 2 rules
+4 page rules converted
+`, buf.String())
+}
+
+func TestCacheCustomKey(t *testing.T) {
+	assert.Equal(t, map[string]interface{}{
+		"host":         map[string]interface{}{"resolved": true},
+		"query_string": map[string]interface{}{"exclude": map[string]interface{}{"all": true}},
+	}, cacheCustomKey(map[string]interface{}{
+		"host":         map[string]interface{}{"resolved": true},
+		"query_string": map[string]interface{}{"include": nil, "exclude": nil, "ignore": true},
+		"cookie":       map[string]interface{}{},
+	}))
+
+	assert.Equal(t, map[string]interface{}{
+		"query_string": map[string]interface{}{"include": map[string]interface{}{"list": []interface{}{"page"}}},
+	}, cacheCustomKey(map[string]interface{}{
+		"query_string": map[string]interface{}{"include": []interface{}{"page"}},
+	}))
+}
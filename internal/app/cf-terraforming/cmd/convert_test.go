@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go/v4"
+	"github.com/cloudflare/cloudflare-go/v4/option"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteConvertedRulesets_ExistingEntrypoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/zones/" + cloudflareTestZoneID + "/rulesets/phases/http_config_settings/entrypoint":
+			w.Write([]byte(`{"success": true, "errors": [], "messages": [], "result": {
+  "id": "4814384a9e5d4991b9815dcfc25d2f1f",
+  "name": "default",
+  "kind": "zone",
+  "phase": "http_config_settings",
+  "version": "3",
+  "last_updated": "2024-01-01T00:00:00Z",
+  "rules": [{
+    "id": "3a03d665bac047339bb530ecb439a90d",
+    "ref": "3a03d665bac047339bb530ecb439a90d",
+    "version": "1",
+    "action": "set_config",
+    "action_parameters": {"rocket_loader": false},
+    "expression": "http.request.uri.path eq \"/app\"",
+    "enabled": true,
+    "last_updated": "2024-01-01T00:00:00Z"
+  }]
+}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"success": false, "errors": [{"code": 10003, "message": "not found"}], "messages": [], "result": null}`))
+		}
+	}))
+	defer server.Close()
+
+	defer func(c *cloudflare.Client) { api = c }(api)
+	api = cloudflare.NewClient(option.WithBaseURL(server.URL), option.WithAPIToken("token"), option.WithMaxRetries(0))
+
+	phases := map[string][]map[string]interface{}{
+		"http_config_settings": {{
+			"action":            "set_config",
+			"action_parameters": map[string]interface{}{"ssl": "full"},
+			"description":       "Converted from the page rule for example.com/*",
+			"enabled":           true,
+			"expression":        `http.host eq "example.com"`,
+		}},
+		"http_request_cache_settings": {{
+			"action":            "set_cache_settings",
+			"action_parameters": map[string]interface{}{"cache": false},
+			"description":       "Converted from the page rule for example.com/*",
+			"enabled":           true,
+			"expression":        `http.host eq "example.com"`,
+		}},
+	}
+
+	entrypoints := zoneEntrypoints(cloudflareTestZoneID, pageRulePhaseNames(), phases)
+	assert.Len(t, entrypoints, 1)
+
+	var buf bytes.Buffer
+	writePageRuleRulesets(&buf, cloudflareTestZoneID, phases, entrypoints)
+	assert.Equal(t, `resource "cloudflare_ruleset" "page_rules_http_config_settings" {
+  kind    = "zone"
+  name    = "default"
+  phase   = "http_config_settings"
+  zone_id = "0da42c8d2132a9ddaf714f9e7c920711"
+  rules = [{
+    action = "set_config"
+    action_parameters = {
+      ssl = "full"
+    }
+    description = "Converted from the page rule for example.com/*"
+    enabled     = true
+    expression  = "http.host eq \"example.com\""
+    }, {
+    action = "set_config"
+    action_parameters = {
+      rocket_loader = false
+    }
+    enabled    = true
+    expression = "http.request.uri.path eq \"/app\""
+  }]
+}
+
+import {
+  to = cloudflare_ruleset.page_rules_http_config_settings
+  id = "zones/0da42c8d2132a9ddaf714f9e7c920711/4814384a9e5d4991b9815dcfc25d2f1f"
+}
+
+resource "cloudflare_ruleset" "page_rules_http_request_cache_settings" {
+  kind    = "zone"
+  name    = "Converted from Page Rules"
+  phase   = "http_request_cache_settings"
+  zone_id = "0da42c8d2132a9ddaf714f9e7c920711"
+  rules = [{
+    action = "set_cache_settings"
+    action_parameters = {
+      cache = false
+    }
+    description = "Converted from the page rule for example.com/*"
+    enabled     = true
+    expression  = "http.host eq \"example.com\""
+  }]
+}
+
+`, buf.String())
+
+	buf.Reset()
+	writeMergedEntrypoints(&buf, pageRulePhaseNames(), entrypoints, pageRuleConvertedFirst())
+	assert.Equal(t, "http_config_settings: added before the 1 rules of the existing entrypoint ruleset 4814384a9e5d4991b9815dcfc25d2f1f, check the order of the rules and remove the import block if the ruleset is already managed by Terraform\n", buf.String())
+}
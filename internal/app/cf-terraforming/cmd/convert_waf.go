@@ -0,0 +1,487 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	cfv0 "github.com/cloudflare/cloudflare-go"
+	"github.com/spf13/cobra"
+)
+
+var convertWAFCmd = &cobra.Command{
+	Use:   "waf",
+	Short: "Convert legacy firewall rules, rate limits and WAF overrides into cloudflare_ruleset rules",
+	Long: `Convert the firewall rules and their filters, rate limits and WAF overrides
+of the zone set with --zone into cloudflare_ruleset rules in the
+http_request_firewall_custom, http_ratelimit and http_request_firewall_managed
+phases. Rules are ordered the same way the legacy products evaluate them.
+Anything with no equivalent is reported.`,
+	Args:   cobra.NoArgs,
+	Run:    runConvertWAF(),
+	PreRun: convertPreRun,
+}
+
+func init() {
+	convertCmd.AddCommand(convertWAFCmd)
+}
+
+const (
+	firewallCustomPhase  = "http_request_firewall_custom"
+	firewallManagedPhase = "http_request_firewall_managed"
+	rateLimitPhase       = "http_ratelimit"
+
+	// cloudflareManagedRulesetID and owaspManagedRulesetID are the managed
+	// rulesets that replaced the legacy WAF packages.
+	cloudflareManagedRulesetID = "efb7b8c949ac4650a09736fc376e9aee"
+	owaspManagedRulesetID      = "4814384a9e5d4991b9815dcfc25d2f1f"
+)
+
+// wafPhases are the phases legacy WAF configuration is converted into in the
+// order their rulesets are output.
+var wafPhases = []string{firewallCustomPhase, rateLimitPhase, firewallManagedPhase}
+
+// wafConvertedFirst are the phases where the converted rules are placed before
+// the rules of an existing entrypoint, as the rules skipping or overriding the
+// managed rulesets must run before the rule that deploys them.
+var wafConvertedFirst = []string{firewallManagedPhase}
+
+// rateLimitPeriods and rateLimitMitigationTimeouts are the periods and
+// mitigation timeouts, in seconds, that `http_ratelimit` rules accept.
+var (
+	rateLimitPeriods            = []int{10, 60, 120, 300, 600, 3600}
+	rateLimitMitigationTimeouts = []int{0, 10, 60, 120, 300, 600, 3600, 86400}
+)
+
+// firewallActionPrecedence is the order firewall rules with the same
+// priority are evaluated in based on their action.
+var firewallActionPrecedence = []string{"log", "bypass", "allow", "managed_challenge", "js_challenge", "challenge", "block"}
+
+// firewallRuleActions maps firewall rule actions to the equivalent ruleset
+// action. `allow` and `bypass` are converted to `skip` rules.
+var firewallRuleActions = map[string]string{
+	"block":             "block",
+	"challenge":         "challenge",
+	"js_challenge":      "js_challenge",
+	"managed_challenge": "managed_challenge",
+	"log":               "log",
+}
+
+// rateLimitActions maps the mode of a rate limit to the equivalent ruleset
+// action.
+var rateLimitActions = map[string]string{
+	"ban":               "block",
+	"simulate":          "log",
+	"challenge":         "challenge",
+	"js_challenge":      "js_challenge",
+	"managed_challenge": "managed_challenge",
+}
+
+// wafOverrideActions maps the actions of legacy WAF rules to the equivalent
+// ruleset action.
+var wafOverrideActions = map[string]string{
+	"block":     "block",
+	"challenge": "challenge",
+	"simulate":  "log",
+}
+
+// unconvertedWAFObject is a legacy WAF object, or part of one, that has no
+// equivalent in rulesets.
+type unconvertedWAFObject struct {
+	Object string
+	Reason string
+}
+
+func runConvertWAF() func(cmd *cobra.Command, args []string) {
+	return func(cmd *cobra.Command, args []string) {
+		if zoneID == "" {
+			log.Fatal("converting legacy WAF configuration requires --zone")
+		}
+
+		ctx := context.Background()
+		firewallRules, _, err := apiV0.FirewallRules(ctx, cfv0.ZoneIdentifier(zoneID), cfv0.FirewallRuleListParams{})
+		if err != nil {
+			log.Fatalf("failed to fetch firewall rules: %s", err)
+		}
+		rateLimits, err := apiV0.ListAllRateLimits(ctx, zoneID)
+		if err != nil {
+			log.Fatalf("failed to fetch rate limits: %s", err)
+		}
+		overrides, err := apiV0.ListWAFOverrides(ctx, zoneID)
+		if err != nil {
+			log.Fatalf("failed to fetch WAF overrides: %s", err)
+		}
+
+		phases := make(map[string][]map[string]interface{})
+		var unconverted []unconvertedWAFObject
+		var u []unconvertedWAFObject
+
+		phases[firewallCustomPhase], u = convertFirewallRules(firewallRules)
+		unconverted = append(unconverted, u...)
+		phases[rateLimitPhase], u = convertRateLimits(rateLimits)
+		unconverted = append(unconverted, u...)
+		phases[firewallManagedPhase], u = convertWAFOverrides(overrides)
+		unconverted = append(unconverted, u...)
+
+		entrypoints := zoneEntrypoints(zoneID, wafPhases, phases)
+		writeConvertedRulesets(cmd.OutOrStdout(), zoneID, "legacy_waf", "legacy WAF configuration", wafPhases, phases, entrypoints, wafConvertedFirst)
+		writeWAFSummary(cmd.OutOrStderr(), phases, unconverted)
+		writeMergedEntrypoints(cmd.OutOrStderr(), wafPhases, entrypoints, wafConvertedFirst)
+	}
+}
+
+// sortFirewallRules sorts firewall rules into the order they are evaluated.
+// Rules with a priority are evaluated first, lowest priority first, then by
+// the precedence of their action. The order of the API is kept otherwise.
+func sortFirewallRules(rules []cfv0.FirewallRule) []cfv0.FirewallRule {
+	sorted := make([]cfv0.FirewallRule, len(rules))
+	copy(sorted, rules)
+
+	precedence := func(action string) int {
+		for i, a := range firewallActionPrecedence {
+			if a == action {
+				return i
+			}
+		}
+		return len(firewallActionPrecedence)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		pi, pj := firewallRulePriority(sorted[i]), firewallRulePriority(sorted[j])
+		if pi != pj {
+			return pi < pj
+		}
+		return precedence(sorted[i].Action) < precedence(sorted[j].Action)
+	})
+
+	return sorted
+}
+
+// firewallRulePriority returns the priority of a firewall rule. Rules without
+// one are evaluated after those with one.
+func firewallRulePriority(r cfv0.FirewallRule) float64 {
+	switch p := r.Priority.(type) {
+	case float64:
+		return p
+	case int:
+		return float64(p)
+	case string:
+		if f, err := strconv.ParseFloat(p, 64); err == nil {
+			return f
+		}
+	}
+
+	return math.Inf(1)
+}
+
+// convertFirewallRules joins each firewall rule with the expression of its
+// filter into an `http_request_firewall_custom` rule.
+func convertFirewallRules(rules []cfv0.FirewallRule) ([]map[string]interface{}, []unconvertedWAFObject) {
+	var converted []map[string]interface{}
+	var unconverted []unconvertedWAFObject
+
+	for _, r := range sortFirewallRules(rules) {
+		object := "firewall rule " + r.ID
+		if r.Filter.Expression == "" {
+			unconverted = append(unconverted, unconvertedWAFObject{Object: object, Reason: "filter has no expression"})
+			continue
+		}
+
+		description := r.Description
+		if description == "" {
+			description = "Converted from " + object
+		}
+		rule := map[string]interface{}{
+			"description": description,
+			"enabled":     !r.Paused && !r.Filter.Paused,
+			"expression":  r.Filter.Expression,
+		}
+
+		switch r.Action {
+		case "allow":
+			// allowed requests skip every remaining security feature.
+			rule["action"] = "skip"
+			rule["action_parameters"] = map[string]interface{}{
+				"ruleset":  "current",
+				"phases":   []interface{}{rateLimitPhase, firewallManagedPhase, "http_request_sbfm"},
+				"products": []interface{}{"bic", "hot", "rateLimit", "securityLevel", "uaBlock", "waf", "zoneLockdown"},
+			}
+		case "bypass":
+			if len(r.Products) == 0 {
+				unconverted = append(unconverted, unconvertedWAFObject{Object: object, Reason: "bypass rule has no products"})
+				continue
+			}
+			products := make([]interface{}, 0, len(r.Products))
+			for _, p := range r.Products {
+				products = append(products, p)
+			}
+			rule["action"] = "skip"
+			rule["action_parameters"] = map[string]interface{}{"products": products}
+		default:
+			action, ok := firewallRuleActions[r.Action]
+			if !ok {
+				unconverted = append(unconverted, unconvertedWAFObject{Object: object, Reason: fmt.Sprintf("action %s has no equivalent", r.Action)})
+				continue
+			}
+			rule["action"] = action
+		}
+
+		converted = append(converted, rule)
+	}
+
+	return converted, unconverted
+}
+
+// convertRateLimits converts rate limits into `http_ratelimit` rules. The URL
+// pattern, methods and schemes of the request become the expression and the
+// response statuses and headers the counting expression.
+func convertRateLimits(rateLimits []cfv0.RateLimit) ([]map[string]interface{}, []unconvertedWAFObject) {
+	var converted []map[string]interface{}
+	var unconverted []unconvertedWAFObject
+
+	for _, r := range rateLimits {
+		object := "rate limit " + r.ID
+
+		action, ok := rateLimitActions[r.Action.Mode]
+		if !ok {
+			unconverted = append(unconverted, unconvertedWAFObject{Object: object, Reason: fmt.Sprintf("action %s has no equivalent", r.Action.Mode)})
+			continue
+		}
+		if r.Correlate != nil && r.Correlate.By != "" {
+			unconverted = append(unconverted, unconvertedWAFObject{Object: object, Reason: fmt.Sprintf("correlating by %s has no equivalent", r.Correlate.By)})
+			continue
+		}
+		if !slices.Contains(rateLimitPeriods, r.Period) {
+			unconverted = append(unconverted, unconvertedWAFObject{Object: object, Reason: fmt.Sprintf("a period of %d seconds isn't supported, %s accepts %s", r.Period, rateLimitPhase, joinInts(rateLimitPeriods))})
+			continue
+		}
+		mitigates := action == "block" || action == "log"
+		if mitigates && !slices.Contains(rateLimitMitigationTimeouts, r.Action.Timeout) {
+			unconverted = append(unconverted, unconvertedWAFObject{Object: object, Reason: fmt.Sprintf("a timeout of %d seconds isn't supported, %s accepts %s", r.Action.Timeout, rateLimitPhase, joinInts(rateLimitMitigationTimeouts))})
+			continue
+		}
+
+		conditions := rateLimitRequestConditions(r.Match.Request)
+		for _, b := range r.Bypass {
+			if b.Name != "url" {
+				continue
+			}
+			conditions = append(conditions, "not ("+joinConditions(pageRuleConditions(b.Value))+")")
+		}
+
+		ratelimit := map[string]interface{}{
+			"characteristics":     []interface{}{"cf.colo.id", "ip.src"},
+			"period":              r.Period,
+			"requests_per_period": r.Threshold,
+		}
+		if mitigates {
+			ratelimit["mitigation_timeout"] = r.Action.Timeout
+		}
+		if counting := rateLimitCountingExpression(r.Match.Response); counting != "" {
+			ratelimit["counting_expression"] = counting
+		}
+
+		description := r.Description
+		if description == "" {
+			description = "Converted from " + object
+		}
+		rule := map[string]interface{}{
+			"action":      action,
+			"description": description,
+			"enabled":     !r.Disabled,
+			"expression":  joinConditions(conditions),
+			"ratelimit":   ratelimit,
+		}
+		if action == "block" && r.Action.Response != nil && r.Action.Response.Body != "" {
+			rule["action_parameters"] = map[string]interface{}{
+				"response": map[string]interface{}{
+					"content":      r.Action.Response.Body,
+					"content_type": r.Action.Response.ContentType,
+					"status_code":  429,
+				},
+			}
+		}
+
+		converted = append(converted, rule)
+	}
+
+	return converted, unconverted
+}
+
+// rateLimitRequestConditions returns the conditions a request must match to
+// be counted by a rate limit.
+func rateLimitRequestConditions(request cfv0.RateLimitRequestMatcher) []string {
+	var conditions []string
+	if request.URLPattern != "" {
+		conditions = append(conditions, pageRuleConditions(request.URLPattern)...)
+	}
+
+	if len(request.Methods) > 0 && !contains(request.Methods, "_ALL_") {
+		methods := make([]string, 0, len(request.Methods))
+		for _, m := range request.Methods {
+			methods = append(methods, wirefilterString(strings.ToUpper(m)))
+		}
+		conditions = append(conditions, "http.request.method in {"+strings.Join(methods, " ")+"}")
+	}
+
+	if len(request.Schemes) == 1 && !contains(conditions, "ssl") && !contains(conditions, "not ssl") {
+		switch strings.ToUpper(request.Schemes[0]) {
+		case "HTTPS":
+			conditions = append(conditions, "ssl")
+		case "HTTP":
+			conditions = append(conditions, "not ssl")
+		}
+	}
+
+	return conditions
+}
+
+// rateLimitCountingExpression returns the expression matching the responses
+// counted by a rate limit, if it only counts some responses.
+func rateLimitCountingExpression(response cfv0.RateLimitResponseMatcher) string {
+	var conditions []string
+	if len(response.Statuses) > 0 {
+		codes := make([]string, 0, len(response.Statuses))
+		for _, s := range response.Statuses {
+			codes = append(codes, strconv.Itoa(s))
+		}
+		conditions = append(conditions, "http.response.code in {"+strings.Join(codes, " ")+"}")
+	}
+
+	for _, h := range response.Headers {
+		op := "eq"
+		if h.Op == "ne" {
+			op = "ne"
+		}
+		conditions = append(conditions, fmt.Sprintf("any(http.response.headers[%s][*] %s %s)", wirefilterString(strings.ToLower(h.Name)), op, wirefilterString(h.Value)))
+	}
+
+	if len(conditions) == 0 {
+		return ""
+	}
+
+	return strings.Join(conditions, " and ")
+}
+
+// convertWAFOverrides converts WAF overrides into rules in the
+// `http_request_firewall_managed` phase. Overrides that turn off the whole WAF
+// for their URLs skip the managed rulesets and overrides that rewrite every
+// action to the same one execute the Cloudflare Managed Ruleset with that
+// action. The legacy WAF rule and group IDs don't exist in the managed
+// rulesets so overrides of individual rules and groups are reported.
+// Overrides are ordered by their priority, lowest first.
+func convertWAFOverrides(overrides []cfv0.WAFOverride) ([]map[string]interface{}, []unconvertedWAFObject) {
+	sorted := make([]cfv0.WAFOverride, len(overrides))
+	copy(sorted, overrides)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Priority < sorted[j].Priority })
+
+	var converted []map[string]interface{}
+	var unconverted []unconvertedWAFObject
+
+	for _, o := range sorted {
+		object := "WAF override " + o.ID
+		if len(o.URLs) == 0 {
+			unconverted = append(unconverted, unconvertedWAFObject{Object: object, Reason: "no URLs"})
+			continue
+		}
+
+		var urls []string
+		for _, u := range o.URLs {
+			conditions := pageRuleConditions(u)
+			if len(o.URLs) > 1 && len(conditions) > 1 {
+				urls = append(urls, "("+joinConditions(conditions)+")")
+			} else {
+				urls = append(urls, joinConditions(conditions))
+			}
+		}
+
+		description := o.Description
+		if description == "" {
+			description = "Converted from " + object
+		}
+		rule := map[string]interface{}{
+			"description": description,
+			"enabled":     !o.Paused,
+			"expression":  strings.Join(urls, " or "),
+		}
+
+		switch action, reason := wafOverrideAction(o); {
+		case reason != "":
+			unconverted = append(unconverted, unconvertedWAFObject{Object: object, Reason: reason})
+			continue
+		case action == "":
+			rule["action"] = "skip"
+			rule["action_parameters"] = map[string]interface{}{
+				"rulesets": []interface{}{cloudflareManagedRulesetID, owaspManagedRulesetID},
+			}
+		default:
+			rule["action"] = "execute"
+			rule["action_parameters"] = map[string]interface{}{
+				"id":        cloudflareManagedRulesetID,
+				"overrides": map[string]interface{}{"action": action},
+			}
+		}
+
+		converted = append(converted, rule)
+	}
+
+	return converted, unconverted
+}
+
+// wafOverrideAction returns the action every rule is rewritten to by a WAF
+// override, or an empty action when the override turns off the whole WAF by
+// rewriting every action to `disable`. A reason is returned when it can't be
+// converted, which includes turning off individual groups or rules as the
+// managed rulesets must not be skipped entirely in their place.
+func wafOverrideAction(o cfv0.WAFOverride) (string, string) {
+	if len(o.Rules) > 0 || len(o.Groups) > 0 {
+		return "", "overriding or turning off individual legacy WAF rules or groups has no equivalent"
+	}
+	if len(o.RewriteAction) == 0 {
+		return "", "overrides nothing"
+	}
+
+	var action string
+	for _, a := range o.RewriteAction {
+		if action != "" && a != action {
+			return "", "rewriting actions to different actions has no equivalent"
+		}
+		action = a
+	}
+	if action == "disable" {
+		return "", ""
+	}
+
+	mapped, ok := wafOverrideActions[action]
+	if !ok {
+		return "", fmt.Sprintf("action %s has no equivalent", action)
+	}
+
+	return mapped, ""
+}
+
+// joinInts lists values separated by commas.
+func joinInts(values []int) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = strconv.Itoa(v)
+	}
+
+	return strings.Join(s, ", ")
+}
+
+// writeWAFSummary lists what couldn't be converted and the number of rules in
+// each phase.
+func writeWAFSummary(w io.Writer, phases map[string][]map[string]interface{}, unconverted []unconvertedWAFObject) {
+	for _, u := range unconverted {
+		fmt.Fprintf(w, "unable to convert %s: %s\n", u.Object, u.Reason)
+	}
+
+	for _, phase := range wafPhases {
+		fmt.Fprintf(w, "%s: %d rules\n", phase, len(phases[phase]))
+	}
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	cfv0 "github.com/cloudflare/cloudflare-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertFirewallRules(t *testing.T) {
+	rules, unconverted := convertFirewallRules([]cfv0.FirewallRule{
+		{ID: "block", Action: "block", Description: "block bad bots", Filter: cfv0.Filter{Expression: `(cf.client.bot)`}},
+		{ID: "log", Action: "log", Filter: cfv0.Filter{Expression: `(ip.src eq 192.0.2.1)`}},
+		{ID: "first", Action: "challenge", Priority: float64(1), Filter: cfv0.Filter{Expression: `(http.request.uri.path eq "/login")`, Paused: true}},
+		{ID: "bypass", Action: "bypass", Priority: float64(2), Products: []string{"waf", "rateLimit"}, Filter: cfv0.Filter{Expression: `(ip.src in {192.0.2.0/24})`}},
+		{ID: "allow", Action: "allow", Priority: float64(2), Filter: cfv0.Filter{Expression: `(http.host eq "internal.example.com")`}},
+		{ID: "empty", Action: "block"},
+	})
+
+	assert.Equal(t, []unconvertedWAFObject{{Object: "firewall rule empty", Reason: "filter has no expression"}}, unconverted)
+	assert.Equal(t, []map[string]interface{}{
+		{"action": "challenge", "description": "Converted from firewall rule first", "enabled": false, "expression": `(http.request.uri.path eq "/login")`},
+		{"action": "skip", "description": "Converted from firewall rule bypass", "enabled": true, "expression": `(ip.src in {192.0.2.0/24})`, "action_parameters": map[string]interface{}{
+			"products": []interface{}{"waf", "rateLimit"},
+		}},
+		{"action": "skip", "description": "Converted from firewall rule allow", "enabled": true, "expression": `(http.host eq "internal.example.com")`, "action_parameters": map[string]interface{}{
+			"ruleset":  "current",
+			"phases":   []interface{}{"http_ratelimit", "http_request_firewall_managed", "http_request_sbfm"},
+			"products": []interface{}{"bic", "hot", "rateLimit", "securityLevel", "uaBlock", "waf", "zoneLockdown"},
+		}},
+		{"action": "log", "description": "Converted from firewall rule log", "enabled": true, "expression": `(ip.src eq 192.0.2.1)`},
+		{"action": "block", "description": "block bad bots", "enabled": true, "expression": `(cf.client.bot)`},
+	}, rules)
+}
+
+func TestConvertRateLimits(t *testing.T) {
+	rules, unconverted := convertRateLimits([]cfv0.RateLimit{
+		{
+			ID:        "api",
+			Threshold: 100,
+			Period:    60,
+			Match: cfv0.RateLimitTrafficMatcher{
+				Request:  cfv0.RateLimitRequestMatcher{URLPattern: "example.com/api/*", Methods: []string{"POST", "PUT"}, Schemes: []string{"HTTPS"}},
+				Response: cfv0.RateLimitResponseMatcher{Statuses: []int{401, 403}, Headers: []cfv0.RateLimitResponseMatcherHeader{{Name: "Cf-Cache-Status", Op: "ne", Value: "HIT"}}},
+			},
+			Bypass: []cfv0.RateLimitKeyValue{{Name: "url", Value: "example.com/api/health"}},
+			Action: cfv0.RateLimitAction{Mode: "ban", Timeout: 600, Response: &cfv0.RateLimitActionResponse{ContentType: "application/json", Body: `{"error":"slow down"}`}},
+		},
+		{
+			ID:        "login",
+			Disabled:  true,
+			Threshold: 5,
+			Period:    10,
+			Match:     cfv0.RateLimitTrafficMatcher{Request: cfv0.RateLimitRequestMatcher{URLPattern: "*example.com/login", Methods: []string{"_ALL_"}}},
+			Action:    cfv0.RateLimitAction{Mode: "managed_challenge"},
+		},
+		{ID: "nat", Action: cfv0.RateLimitAction{Mode: "ban"}, Correlate: &cfv0.RateLimitCorrelate{By: "nat"}},
+		{ID: "period", Period: 30, Threshold: 10, Action: cfv0.RateLimitAction{Mode: "challenge"}},
+		{ID: "timeout", Period: 60, Threshold: 10, Action: cfv0.RateLimitAction{Mode: "ban", Timeout: 900}},
+	})
+
+	assert.Equal(t, []unconvertedWAFObject{
+		{Object: "rate limit nat", Reason: "correlating by nat has no equivalent"},
+		{Object: "rate limit period", Reason: "a period of 30 seconds isn't supported, http_ratelimit accepts 10, 60, 120, 300, 600, 3600"},
+		{Object: "rate limit timeout", Reason: "a timeout of 900 seconds isn't supported, http_ratelimit accepts 0, 10, 60, 120, 300, 600, 3600, 86400"},
+	}, unconverted)
+	assert.Equal(t, []map[string]interface{}{
+		{
+			"action":      "block",
+			"description": "Converted from rate limit api",
+			"enabled":     true,
+			"expression":  `http.host eq "example.com" and http.request.uri.path wildcard "/api/*" and http.request.method in {"POST" "PUT"} and ssl and not (http.host eq "example.com" and http.request.uri.path eq "/api/health")`,
+			"ratelimit": map[string]interface{}{
+				"characteristics":     []interface{}{"cf.colo.id", "ip.src"},
+				"counting_expression": `http.response.code in {401 403} and any(http.response.headers["cf-cache-status"][*] ne "HIT")`,
+				"mitigation_timeout":  600,
+				"period":              60,
+				"requests_per_period": 100,
+			},
+			"action_parameters": map[string]interface{}{
+				"response": map[string]interface{}{"content": `{"error":"slow down"}`, "content_type": "application/json", "status_code": 429},
+			},
+		},
+		{
+			"action":      "managed_challenge",
+			"description": "Converted from rate limit login",
+			"enabled":     false,
+			"expression":  `http.host wildcard "*example.com" and http.request.uri.path eq "/login"`,
+			"ratelimit": map[string]interface{}{
+				"characteristics":     []interface{}{"cf.colo.id", "ip.src"},
+				"period":              10,
+				"requests_per_period": 5,
+			},
+		},
+	}, rules)
+}
+
+func TestConvertWAFOverrides(t *testing.T) {
+	rules, unconverted := convertWAFOverrides([]cfv0.WAFOverride{
+		{ID: "simulate", Priority: 2, URLs: []string{"example.com/beta/*"}, RewriteAction: map[string]string{"default": "simulate", "challenge": "simulate", "block": "simulate"}},
+		{ID: "off", Priority: 1, Paused: true, Description: "no WAF for uploads", URLs: []string{"example.com/upload/*", "uploads.example.com"}, RewriteAction: map[string]string{"default": "disable", "challenge": "disable", "block": "disable"}},
+		{ID: "group", Priority: 4, URLs: []string{"example.com/*"}, Groups: map[string]string{"de677e5818985db1285d0e80225f06e5": "off"}},
+		{ID: "rule", Priority: 3, URLs: []string{"example.com/*"}, Rules: map[string]string{"100015": "disable"}},
+	})
+
+	assert.Equal(t, []unconvertedWAFObject{
+		{Object: "WAF override rule", Reason: "overriding or turning off individual legacy WAF rules or groups has no equivalent"},
+		{Object: "WAF override group", Reason: "overriding or turning off individual legacy WAF rules or groups has no equivalent"},
+	}, unconverted)
+	assert.Equal(t, []map[string]interface{}{
+		{
+			"action":      "skip",
+			"description": "no WAF for uploads",
+			"enabled":     false,
+			"expression":  `(http.host eq "example.com" and http.request.uri.path wildcard "/upload/*") or (http.host eq "uploads.example.com" and http.request.uri.path eq "/")`,
+			"action_parameters": map[string]interface{}{
+				"rulesets": []interface{}{"efb7b8c949ac4650a09736fc376e9aee", "4814384a9e5d4991b9815dcfc25d2f1f"},
+			},
+		},
+		{
+			"action":      "execute",
+			"description": "Converted from WAF override simulate",
+			"enabled":     true,
+			"expression":  `http.host eq "example.com" and http.request.uri.path wildcard "/beta/*"`,
+			"action_parameters": map[string]interface{}{
+				"id":        "efb7b8c949ac4650a09736fc376e9aee",
+				"overrides": map[string]interface{}{"action": "log"},
+			},
+		},
+	}, rules)
+}
+
+func TestWriteWAFRulesets(t *testing.T) {
+	rules, unconverted := convertFirewallRules([]cfv0.FirewallRule{
+		{ID: "block", Action: "block", Description: "block bad bots", Filter: cfv0.Filter{Expression: `(cf.client.bot)`}},
+		{ID: "deny", Action: "deny", Filter: cfv0.Filter{Expression: `(cf.client.bot)`}},
+	})
+	phases := map[string][]map[string]interface{}{firewallCustomPhase: rules}
+
+	var buf bytes.Buffer
+	writeConvertedRulesets(&buf, "0da42c8d2132a9ddaf714f9e7c920711", "legacy_waf", "legacy WAF configuration", wafPhases, phases, nil, wafConvertedFirst)
+	assert.Equal(t, `resource "cloudflare_ruleset" "legacy_waf_http_request_firewall_custom" {
+  kind    = "zone"
+  name    = "Converted from legacy WAF configuration"
+  phase   = "http_request_firewall_custom"
+  zone_id = "0da42c8d2132a9ddaf714f9e7c920711"
+  rules = [{
+    action      = "block"
+    description = "block bad bots"
+    enabled     = true
+    expression  = "(cf.client.bot)"
+  }]
+}
+
+`, buf.String())
+
+	buf.Reset()
+	writeWAFSummary(&buf, phases, unconverted)
+	assert.Equal(t, `unable to convert firewall rule deny: action deny has no equivalent
+http_request_firewall_custom: 1 rules
+http_ratelimit: 0 rules
+http_request_firewall_managed: 0 rules
+`, buf.String())
+}
@@ -0,0 +1,344 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/spf13/cobra"
+	"github.com/zclconf/go-cty/cty"
+)
+
+var convertZoneSettingsCmd = &cobra.Command{
+	Use:   "zone-settings [configuration file or directory]",
+	Short: "Convert cloudflare_zone_settings_override into cloudflare_zone_setting resources and import blocks",
+	Long: `Convert the settings of a zone into one cloudflare_zone_setting resource per
+setting along with their import blocks. Settings are read from the
+cloudflare_zone_settings_override blocks in the given configuration or, when
+no configuration is given, from the zone set with --zone. Read-only settings
+and settings with their default value are skipped.`,
+	Args:   cobra.MaximumNArgs(1),
+	Run:    runConvertZoneSettings(),
+	PreRun: convertPreRun,
+}
+
+func init() {
+	convertCmd.AddCommand(convertZoneSettingsCmd)
+
+	convertZoneSettingsCmd.Flags().BoolVar(&useStateRm, "state-rm", false, "Output terraform state rm commands instead of removed blocks for the replaced cloudflare_zone_settings_override resources. Removed blocks are only compatible with Terraform 1.7+")
+}
+
+// zoneSettingsOverrideIDs maps the attributes of the v4 `settings` block that
+// aren't named after the setting ID.
+var zoneSettingsOverrideIDs = map[string]string{
+	"zero_rtt": "0rtt",
+}
+
+// unsupportedZoneSettings are the attributes of the v4 `settings` block that
+// can't be managed with `cloudflare_zone_setting`.
+var unsupportedZoneSettings = map[string]string{
+	"tls_1_2_only":  "deprecated in favour of min_tls_version",
+	"universal_ssl": "not a zone setting",
+}
+
+// readOnlyZoneSettings are the settings that can't be changed. Live settings
+// are also skipped when the API reports them as not editable.
+var readOnlyZoneSettings = map[string]bool{
+	"advanced_ddos": true,
+}
+
+// zoneSettingDefaults are the values settings have until they are changed.
+var zoneSettingDefaults = map[string]cty.Value{
+	"0rtt":                        cty.StringVal("off"),
+	"always_online":               cty.StringVal("off"),
+	"always_use_https":            cty.StringVal("off"),
+	"automatic_https_rewrites":    cty.StringVal("off"),
+	"browser_cache_ttl":           cty.NumberIntVal(14400),
+	"browser_check":               cty.StringVal("on"),
+	"cache_level":                 cty.StringVal("aggressive"),
+	"challenge_ttl":               cty.NumberIntVal(1800),
+	"development_mode":            cty.StringVal("off"),
+	"early_hints":                 cty.StringVal("off"),
+	"email_obfuscation":           cty.StringVal("on"),
+	"hotlink_protection":          cty.StringVal("off"),
+	"ip_geolocation":              cty.StringVal("on"),
+	"ipv6":                        cty.StringVal("on"),
+	"max_upload":                  cty.NumberIntVal(100),
+	"min_tls_version":             cty.StringVal("1.0"),
+	"mirage":                      cty.StringVal("off"),
+	"opportunistic_encryption":    cty.StringVal("on"),
+	"opportunistic_onion":         cty.StringVal("on"),
+	"origin_error_page_pass_thru": cty.StringVal("off"),
+	"polish":                      cty.StringVal("off"),
+	"prefetch_preload":            cty.StringVal("off"),
+	"pseudo_ipv4":                 cty.StringVal("off"),
+	"response_buffering":          cty.StringVal("off"),
+	"rocket_loader":               cty.StringVal("off"),
+	"security_level":              cty.StringVal("medium"),
+	"server_side_exclude":         cty.StringVal("on"),
+	"sort_query_string_for_cache": cty.StringVal("off"),
+	"true_client_ip_header":       cty.StringVal("off"),
+	"waf":                         cty.StringVal("off"),
+	"webp":                        cty.StringVal("off"),
+	"websockets":                  cty.StringVal("on"),
+}
+
+// zoneSetting is a single setting of a zone.
+type zoneSetting struct {
+	ID    string
+	Value hclwrite.Tokens
+}
+
+// zoneSettingsConversion is the settings of a zone being converted into
+// `cloudflare_zone_setting` resources.
+type zoneSettingsConversion struct {
+	// Source is the address of the `cloudflare_zone_settings_override` being
+	// replaced, if any.
+	Source string
+	// Name prefixes the name of each resource.
+	Name string
+	// ZoneID is the expression the resources reference the zone with and
+	// ImportZoneID is the zone ID used in the import blocks.
+	ZoneID       hclwrite.Tokens
+	ImportZoneID string
+	Settings     []zoneSetting
+}
+
+// ResourceName is the name of the resource for a setting.
+func (c zoneSettingsConversion) ResourceName(settingID string) string {
+	if c.Name == "" {
+		return terraformResourceName(settingID)
+	}
+
+	return c.Name + "_" + invalidResourceNameChars.ReplaceAllString(settingID, "_")
+}
+
+// skippedZoneSetting is a setting that wasn't converted.
+type skippedZoneSetting struct {
+	Source  string
+	Setting string
+	Reason  string
+}
+
+func runConvertZoneSettings() func(cmd *cobra.Command, args []string) {
+	return func(cmd *cobra.Command, args []string) {
+		var conversions []zoneSettingsConversion
+		var skipped []skippedZoneSetting
+
+		if len(args) > 0 {
+			sources, err := parseConvertSources(args[0], "cloudflare_zone_settings_override")
+			if err != nil {
+				log.Fatalf("failed to parse Terraform configuration: %s", err)
+			}
+			if len(sources) == 0 {
+				log.Fatalf("no cloudflare_zone_settings_override resources found in %s", args[0])
+			}
+
+			for _, src := range sources {
+				c, s := overrideZoneSettings(src, zoneID)
+				if c.ImportZoneID == "" {
+					log.Fatalf("the zone_id of %s isn't a literal value, set the zone to import into with --zone", src.Address())
+				}
+				conversions = append(conversions, c)
+				skipped = append(skipped, s...)
+			}
+		} else {
+			if zoneID == "" {
+				log.Fatal("either a configuration file or directory or --zone must be set")
+			}
+
+			data, err := fetchEndpoint("cloudflare_zone_setting", "/zones/"+zoneID+"/settings", "")
+			if err != nil {
+				log.Fatalf("failed to fetch zone settings: %s", err)
+			}
+
+			c, s := liveZoneSettings(zoneID, data)
+			conversions = append(conversions, c)
+			skipped = append(skipped, s...)
+		}
+
+		writeZoneSettings(cmd.OutOrStdout(), conversions)
+		writeZoneSettingsSummary(cmd.OutOrStderr(), conversions, skipped)
+	}
+}
+
+// overrideZoneSettings reads the settings of a `cloudflare_zone_settings_override`
+// block. Nested blocks, such as `minify`, become object values and the
+// `security_header` block is moved under `strict_transport_security` to match
+// the API. fallbackZoneID is imported into when `zone_id` isn't a literal.
+func overrideZoneSettings(src convertSource, fallbackZoneID string) (zoneSettingsConversion, []skippedZoneSetting) {
+	c := zoneSettingsConversion{Source: src.Address(), Name: src.Name, ImportZoneID: fallbackZoneID}
+	if attr := src.Body.GetAttribute("zone_id"); attr != nil {
+		c.ZoneID = attr.Expr().BuildTokens(nil)
+		if v, ok := evaluateTokens(c.ZoneID); ok && v.Type() == cty.String && !v.IsNull() {
+			c.ImportZoneID = v.AsString()
+		}
+	}
+
+	settings := make(map[string]hclwrite.Tokens)
+	if block := src.Body.FirstMatchingBlock("settings", nil); block != nil {
+		attrs := block.Body().Attributes()
+		for name, attr := range attrs {
+			settings[name] = attr.Expr().BuildTokens(nil)
+		}
+		for _, nested := range block.Body().Blocks() {
+			settings[nested.Type()] = bodyObjectTokens(nested.Body())
+		}
+	}
+
+	if tokens, ok := settings["security_header"]; ok {
+		settings["security_header"] = hclwrite.TokensForObject([]hclwrite.ObjectAttrTokens{{
+			Name:  hclwrite.TokensForIdentifier("strict_transport_security"),
+			Value: tokens,
+		}})
+	}
+
+	var skipped []skippedZoneSetting
+	for name, tokens := range settings {
+		id := name
+		if mapped, ok := zoneSettingsOverrideIDs[name]; ok {
+			id = mapped
+		}
+
+		reason, ok := unsupportedZoneSettings[name]
+		if !ok {
+			reason = skipZoneSetting(id, tokens)
+		}
+		if reason != "" {
+			skipped = append(skipped, skippedZoneSetting{Source: c.Source, Setting: id, Reason: reason})
+			continue
+		}
+
+		c.Settings = append(c.Settings, zoneSetting{ID: id, Value: tokens})
+	}
+	sortZoneSettings(c.Settings, skipped)
+
+	return c, skipped
+}
+
+// liveZoneSettings converts the settings returned by the API for a zone.
+func liveZoneSettings(zone string, data []interface{}) (zoneSettingsConversion, []skippedZoneSetting) {
+	c := zoneSettingsConversion{
+		ZoneID:       hclwrite.TokensForValue(cty.StringVal(zone)),
+		ImportZoneID: zone,
+	}
+	source := "zone " + zone
+
+	var skipped []skippedZoneSetting
+	for _, d := range data {
+		setting, ok := d.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id, _ := setting["id"].(string)
+		if id == "" {
+			continue
+		}
+
+		if editable, ok := setting["editable"].(bool); ok && !editable {
+			skipped = append(skipped, skippedZoneSetting{Source: source, Setting: id, Reason: "read-only"})
+			continue
+		}
+		if setting["value"] == nil {
+			skipped = append(skipped, skippedZoneSetting{Source: source, Setting: id, Reason: "no value"})
+			continue
+		}
+
+		tokens := hclwrite.TokensForValue(processExpression(setting["value"]))
+		if reason := skipZoneSetting(id, tokens); reason != "" {
+			skipped = append(skipped, skippedZoneSetting{Source: source, Setting: id, Reason: reason})
+			continue
+		}
+
+		c.Settings = append(c.Settings, zoneSetting{ID: id, Value: tokens})
+	}
+	sortZoneSettings(c.Settings, skipped)
+
+	return c, skipped
+}
+
+// skipZoneSetting returns why a setting shouldn't be converted, if it
+// shouldn't be. Values that aren't literals are always kept.
+func skipZoneSetting(id string, value hclwrite.Tokens) string {
+	if readOnlyZoneSettings[id] {
+		return "read-only"
+	}
+
+	def, ok := zoneSettingDefaults[id]
+	if !ok {
+		return ""
+	}
+	v, ok := evaluateTokens(value)
+	if ok && !v.IsNull() && v.Type().Equals(def.Type()) && v.Equals(def).True() {
+		return "default value"
+	}
+
+	return ""
+}
+
+// sortZoneSettings sorts converted and skipped settings by their ID.
+func sortZoneSettings(settings []zoneSetting, skipped []skippedZoneSetting) {
+	sort.Slice(settings, func(i, j int) bool { return settings[i].ID < settings[j].ID })
+	sort.Slice(skipped, func(i, j int) bool { return skipped[i].Setting < skipped[j].Setting })
+}
+
+// bodyObjectTokens converts the attributes of a block into an object.
+func bodyObjectTokens(body *hclwrite.Body) hclwrite.Tokens {
+	attrs := body.Attributes()
+	items := make([]hclwrite.ObjectAttrTokens, 0, len(attrs))
+	for _, name := range sortedAttributeNames(attrs) {
+		items = append(items, hclwrite.ObjectAttrTokens{
+			Name:  hclwrite.TokensForIdentifier(name),
+			Value: attrs[name].Expr().BuildTokens(nil),
+		})
+	}
+
+	return hclwrite.TokensForObject(items)
+}
+
+// writeZoneSettings outputs a `cloudflare_zone_setting` resource and import
+// block for each setting. The `cloudflare_zone_settings_override` being
+// replaced is removed from state without being destroyed as destroying it
+// would reset the settings of the zone.
+func writeZoneSettings(w io.Writer, conversions []zoneSettingsConversion) {
+	f := hclwrite.NewEmptyFile()
+	body := f.Body()
+
+	for _, c := range conversions {
+		for _, s := range c.Settings {
+			name := c.ResourceName(s.ID)
+
+			resource := body.AppendNewBlock("resource", []string{"cloudflare_zone_setting", name}).Body()
+			resource.SetAttributeValue("setting_id", cty.StringVal(s.ID))
+			resource.SetAttributeRaw("zone_id", c.ZoneID)
+			resource.SetAttributeRaw("value", s.Value)
+			body.AppendNewline()
+
+			imp := body.AppendNewBlock("import", nil).Body()
+			imp.SetAttributeTraversal("to", addressTraversal("cloudflare_zone_setting."+name))
+			imp.SetAttributeValue("id", cty.StringVal(c.ImportZoneID+"/"+s.ID))
+			body.AppendNewline()
+		}
+
+		if c.Source != "" {
+			appendRemovedBlock(body, c.Source, false)
+		}
+	}
+
+	fmt.Fprint(w, string(hclwrite.Format(f.Bytes())))
+}
+
+// writeZoneSettingsSummary lists the skipped settings and the number of
+// settings converted.
+func writeZoneSettingsSummary(w io.Writer, conversions []zoneSettingsConversion, skipped []skippedZoneSetting) {
+	for _, s := range skipped {
+		fmt.Fprintf(w, "%s: skipped %s (%s)\n", s.Source, s.Setting, s.Reason)
+	}
+
+	converted := 0
+	for _, c := range conversions {
+		converted += len(c.Settings)
+	}
+	fmt.Fprintf(w, "%d settings converted\n", converted)
+}
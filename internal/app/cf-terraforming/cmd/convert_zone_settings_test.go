@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const zoneSettingsOverrideConfig = `resource "cloudflare_zone" "example" {
+  zone = "example.com"
+}
+
+resource "cloudflare_zone_settings_override" "example" {
+  zone_id = cloudflare_zone.example.id
+  settings {
+    always_online     = "on"
+    browser_cache_ttl = 14400
+    security_level    = var.security_level
+    tls_1_2_only      = "off"
+    zero_rtt          = "on"
+    minify {
+      css  = "on"
+      html = "off"
+      js   = "on"
+    }
+    security_header {
+      enabled = true
+      max_age = 86400
+    }
+  }
+}
+`
+
+func TestParseConvertSources(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte(zoneSettingsOverrideConfig), 0o644))
+
+	sources, err := parseConvertSources(dir, "cloudflare_zone_settings_override")
+	require.NoError(t, err)
+	require.Len(t, sources, 1)
+	assert.Equal(t, "cloudflare_zone_settings_override.example", sources[0].Address())
+
+	sources, err = parseConvertSources(filepath.Join(dir, "main.tf"), "cloudflare_zone")
+	require.NoError(t, err)
+	require.Len(t, sources, 1)
+	assert.Equal(t, "cloudflare_zone.example", sources[0].Address())
+}
+
+func TestConvertZoneSettings_Override(t *testing.T) {
+	defer func(v bool) { useStateRm = v }(useStateRm)
+	useStateRm = false
+
+	f := parseTestHCL(t, zoneSettingsOverrideConfig)
+	src := convertSource{Type: "cloudflare_zone_settings_override", Name: "example", Body: f.Body().Blocks()[1].Body()}
+
+	c, skipped := overrideZoneSettings(src, "0da42c8d2132a9ddaf714f9e7c920711")
+	assert.Equal(t, "0da42c8d2132a9ddaf714f9e7c920711", c.ImportZoneID)
+	assert.Equal(t, []skippedZoneSetting{
+		{Source: "cloudflare_zone_settings_override.example", Setting: "browser_cache_ttl", Reason: "default value"},
+		{Source: "cloudflare_zone_settings_override.example", Setting: "tls_1_2_only", Reason: "deprecated in favour of min_tls_version"},
+	}, skipped)
+
+	var buf bytes.Buffer
+	writeZoneSettings(&buf, []zoneSettingsConversion{c})
+	assert.Equal(t, `resource "cloudflare_zone_setting" "example_0rtt" {
+  setting_id = "0rtt"
+  zone_id    = cloudflare_zone.example.id
+  value      = "on"
+}
+
+import {
+  to = cloudflare_zone_setting.example_0rtt
+  id = "0da42c8d2132a9ddaf714f9e7c920711/0rtt"
+}
+
+resource "cloudflare_zone_setting" "example_always_online" {
+  setting_id = "always_online"
+  zone_id    = cloudflare_zone.example.id
+  value      = "on"
+}
+
+import {
+  to = cloudflare_zone_setting.example_always_online
+  id = "0da42c8d2132a9ddaf714f9e7c920711/always_online"
+}
+
+resource "cloudflare_zone_setting" "example_minify" {
+  setting_id = "minify"
+  zone_id    = cloudflare_zone.example.id
+  value = {
+    css  = "on"
+    html = "off"
+    js   = "on"
+  }
+}
+
+import {
+  to = cloudflare_zone_setting.example_minify
+  id = "0da42c8d2132a9ddaf714f9e7c920711/minify"
+}
+
+resource "cloudflare_zone_setting" "example_security_header" {
+  setting_id = "security_header"
+  zone_id    = cloudflare_zone.example.id
+  value = {
+    strict_transport_security = {
+      enabled = true
+      max_age = 86400
+    }
+  }
+}
+
+import {
+  to = cloudflare_zone_setting.example_security_header
+  id = "0da42c8d2132a9ddaf714f9e7c920711/security_header"
+}
+
+resource "cloudflare_zone_setting" "example_security_level" {
+  setting_id = "security_level"
+  zone_id    = cloudflare_zone.example.id
+  value      = var.security_level
+}
+
+import {
+  to = cloudflare_zone_setting.example_security_level
+  id = "0da42c8d2132a9ddaf714f9e7c920711/security_level"
+}
+
+removed {
+  from = cloudflare_zone_settings_override.example
+  lifecycle {
+    destroy = false
+  }
+}
+
+`, buf.String())
+}
+
+func TestConvertZoneSettings_OverrideLiteralZone(t *testing.T) {
+	f := parseTestHCL(t, `resource "cloudflare_zone_settings_override" "example" {
+  zone_id = "0da42c8d2132a9ddaf714f9e7c920711"
+}
+`)
+	src := convertSource{Type: "cloudflare_zone_settings_override", Name: "example", Body: f.Body().Blocks()[0].Body()}
+
+	c, _ := overrideZoneSettings(src, "")
+	assert.Equal(t, "0da42c8d2132a9ddaf714f9e7c920711", c.ImportZoneID)
+	assert.Empty(t, c.Settings)
+}
+
+func TestConvertZoneSettings_Live(t *testing.T) {
+	c, skipped := liveZoneSettings("0da42c8d2132a9ddaf714f9e7c920711", []interface{}{
+		map[string]interface{}{"id": "always_online", "setting_id": "always_online", "value": "off", "editable": true},
+		map[string]interface{}{"id": "advanced_ddos", "setting_id": "advanced_ddos", "value": "on", "editable": false},
+		map[string]interface{}{"id": "cache_level", "setting_id": "cache_level", "value": "basic", "editable": true},
+		map[string]interface{}{"id": "browser_cache_ttl", "setting_id": "browser_cache_ttl", "value": float64(7200), "editable": true},
+	})
+
+	assert.Equal(t, []skippedZoneSetting{
+		{Source: "zone 0da42c8d2132a9ddaf714f9e7c920711", Setting: "advanced_ddos", Reason: "read-only"},
+		{Source: "zone 0da42c8d2132a9ddaf714f9e7c920711", Setting: "always_online", Reason: "default value"},
+	}, skipped)
+
+	var buf bytes.Buffer
+	writeZoneSettings(&buf, []zoneSettingsConversion{c})
+	assert.Equal(t, `resource "cloudflare_zone_setting" "terraform_managed_resource_browser_cache_ttl" {
+  setting_id = "browser_cache_ttl"
+  zone_id    = "0da42c8d2132a9ddaf714f9e7c920711"
+  value      = 7200
+}
+
+import {
+  to = cloudflare_zone_setting.terraform_managed_resource_browser_cache_ttl
+  id = "0da42c8d2132a9ddaf714f9e7c920711/browser_cache_ttl"
+}
+
+resource "cloudflare_zone_setting" "terraform_managed_resource_cache_level" {
+  setting_id = "cache_level"
+  zone_id    = "0da42c8d2132a9ddaf714f9e7c920711"
+  value      = "basic"
+}
+
+import {
+  to = cloudflare_zone_setting.terraform_managed_resource_cache_level
+  id = "0da42c8d2132a9ddaf714f9e7c920711/cache_level"
+}
+
+`, buf.String())
+
+	buf.Reset()
+	writeZoneSettingsSummary(&buf, []zoneSettingsConversion{c}, skipped)
+	assert.Equal(t, `zone 0da42c8d2132a9ddaf714f9e7c920711: skipped advanced_ddos (read-only)
+zone 0da42c8d2132a9ddaf714f9e7c920711: skipped always_online (default value)
+2 settings converted
+`, buf.String())
+}
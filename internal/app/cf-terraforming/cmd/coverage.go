@@ -0,0 +1,279 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cloudflare/cloudflare-go/v4"
+	"github.com/cloudflare/cloudflare-go/v4/zones"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	coverageScopeAccount = "account"
+	coverageScopeZone    = "zone"
+)
+
+var coverageCmd = &cobra.Command{
+	Use:    "coverage",
+	Short:  "Report how many Cloudflare resources in an account are managed by Terraform",
+	Run:    runCoverage(),
+	PreRun: sharedPreRun,
+}
+
+func init() {
+	rootCmd.AddCommand(coverageCmd)
+
+	coverageCmd.Flags().StringVar(&outputFormat, "format", "text", "Output format of the coverage report: text or json")
+}
+
+// coverageType is the number of managed and unmanaged objects of a single
+// resource type.
+type coverageType struct {
+	Type      string   `json:"type"`
+	Managed   int      `json:"managed"`
+	Unmanaged int      `json:"unmanaged"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// Total is the number of objects found in the API.
+func (c coverageType) Total() int {
+	return c.Managed + c.Unmanaged
+}
+
+// coverageReport is the managed and unmanaged counts for every resource type.
+type coverageReport struct {
+	Resources []coverageType `json:"resources"`
+	Managed   int            `json:"managed"`
+	Unmanaged int            `json:"unmanaged"`
+	Total     int            `json:"total"`
+	Coverage  float64        `json:"coverage"`
+}
+
+func runCoverage() func(cmd *cobra.Command, args []string) {
+	return func(cmd *cobra.Command, args []string) {
+		zoneID = viper.GetString("zone")
+		accountID = viper.GetString("account")
+
+		if accountID == "" {
+			log.Fatal("you must provide an account ID to report coverage for")
+		}
+
+		tf, _, cleanup := initTerraform()
+		defer cleanup()
+
+		if !strings.HasPrefix(providerVersionString, "5") {
+			log.Fatalf("coverage requires v5 of the provider, found %s", providerVersionString)
+		}
+
+		state, err := tf.Show(context.Background())
+		if err != nil {
+			log.Fatalf("failed to read Terraform state: %s", err)
+		}
+		managed := managedIDs(stateResources(state))
+
+		zoneIDs := []string{zoneID}
+		if zoneID == "" {
+			zoneIDs = accountZoneIDs(accountID)
+		}
+
+		report := buildCoverageReport(collectCoverage(accountID, zoneIDs, managed))
+		if err := writeCoverageReport(cmd.OutOrStdout(), outputFormat, report); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// accountZoneIDs lists the IDs of every zone within the account.
+func accountZoneIDs(account string) []string {
+	var ids []string
+	iter := api.Zones.ListAutoPaging(context.Background(), zones.ZoneListParams{
+		Account: cloudflare.F(zones.ZoneListParamsAccount{ID: cloudflare.F(account)}),
+	})
+	for iter.Next() {
+		ids = append(ids, iter.Current().ID)
+	}
+	if err := iter.Err(); err != nil {
+		log.Fatalf("failed to list zones: %s", err)
+	}
+
+	return ids
+}
+
+// coverageScopes returns the scopes a resource type can be listed at using
+// only the account or zone ID. Resources that need any other parameters, such
+// as a parent resource ID, can't be listed and have no scopes.
+func coverageScopes(resourceType string) []string {
+	endpoint := resourceEndpoint(resourceType)
+	if endpoint == "" {
+		return nil
+	}
+
+	if strings.Contains(endpoint, "{accounts_or_zones}/{account_or_zone_id}") {
+		endpoint = strings.Replace(endpoint, "{accounts_or_zones}/{account_or_zone_id}", "", 1)
+		if endpointPlaceholder.MatchString(endpoint) {
+			return nil
+		}
+		return []string{coverageScopeAccount, coverageScopeZone}
+	}
+
+	placeholders := endpointPlaceholder.FindAllString(endpoint, -1)
+	switch {
+	case len(placeholders) == 0:
+		return []string{coverageScopeAccount}
+	case len(placeholders) == 1 && placeholders[0] == "{account_id}":
+		return []string{coverageScopeAccount}
+	case len(placeholders) == 1 && placeholders[0] == "{zone_id}":
+		return []string{coverageScopeZone}
+	default:
+		return nil
+	}
+}
+
+// collectCoverage fetches every resource type that can be listed for the
+// account and each of the zones and counts how many are in state.
+func collectCoverage(account string, zoneIDs []string, managed map[string]map[string]bool) []coverageType {
+	defer func(a, z string) {
+		accountID, zoneID = a, z
+	}(accountID, zoneID)
+
+	types := make([]string, 0, len(resourceToEndpoint))
+	for t := range resourceToEndpoint {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	var results []coverageType
+	for _, resourceType := range types {
+		result := coverageType{Type: resourceType}
+
+		scopes := coverageScopes(resourceType)
+		if len(scopes) == 0 {
+			log.WithFields(logrus.Fields{
+				"resource": resourceType,
+			}).Debug("skipping resource that can't be listed by account or zone")
+			continue
+		}
+
+		for _, scope := range scopes {
+			targets := []string{account}
+			if scope == coverageScopeZone {
+				targets = zoneIDs
+			}
+
+			for _, target := range targets {
+				if scope == coverageScopeZone {
+					accountID, zoneID = "", target
+				} else {
+					accountID, zoneID = target, ""
+				}
+
+				live, err := fetchEndpoint(resourceType, resolveEndpointScope(resourceEndpoint(resourceType)), "")
+				if err != nil {
+					if !isNotFound(err) && !errors.Is(err, errNoResult) {
+						result.Errors = append(result.Errors, fmt.Sprintf("%s %s: %s", scope, target, err))
+					}
+					continue
+				}
+
+				m, u := countCoverage(live, managed[resourceType])
+				result.Managed += m
+				result.Unmanaged += u
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// countCoverage counts how many of the live objects are managed.
+func countCoverage(live []interface{}, managed map[string]bool) (int, int) {
+	m, u := 0, 0
+	for _, l := range live {
+		data, ok := l.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if managed[resourceIDFromData(data)] {
+			m++
+		} else {
+			u++
+		}
+	}
+
+	return m, u
+}
+
+// buildCoverageReport totals the per type counts, omitting types without any
+// objects or errors.
+func buildCoverageReport(results []coverageType) coverageReport {
+	report := coverageReport{Resources: []coverageType{}}
+	for _, r := range results {
+		if r.Total() == 0 && len(r.Errors) == 0 {
+			continue
+		}
+		report.Resources = append(report.Resources, r)
+		report.Managed += r.Managed
+		report.Unmanaged += r.Unmanaged
+	}
+
+	report.Total = report.Managed + report.Unmanaged
+	report.Coverage = coveragePercentage(report.Managed, report.Total)
+
+	return report
+}
+
+// coveragePercentage is the percentage of managed objects, rounded to a
+// single decimal place.
+func coveragePercentage(managed, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return float64(int(float64(managed)/float64(total)*1000+0.5)) / 10
+}
+
+// writeCoverageReport outputs the coverage report as a table or JSON.
+func writeCoverageReport(w io.Writer, format string, report coverageReport) error {
+	switch format {
+	case "", outputFormatText:
+	case outputFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RESOURCE TYPE\tMANAGED\tUNMANAGED\tTOTAL\tCOVERAGE")
+	for _, r := range report.Resources {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.1f%%\n", r.Type, r.Managed, r.Unmanaged, r.Total(), coveragePercentage(r.Managed, r.Total()))
+	}
+	fmt.Fprintf(tw, "TOTAL\t%d\t%d\t%d\t%.1f%%\n", report.Managed, report.Unmanaged, report.Total, report.Coverage)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, r := range report.Resources {
+		for _, e := range r.Errors {
+			errs = append(errs, fmt.Sprintf("unable to check %s for %s", r.Type, e))
+		}
+	}
+	if len(errs) > 0 {
+		fmt.Fprintf(w, "\n%s\n", strings.Join(errs, "\n"))
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoverageScopes(t *testing.T) {
+	tests := map[string]struct {
+		resourceType string
+		want         []string
+	}{
+		"account":        {resourceType: "cloudflare_account_member", want: []string{coverageScopeAccount}},
+		"zone":           {resourceType: "cloudflare_dns_record", want: []string{coverageScopeZone}},
+		"combined":       {resourceType: "cloudflare_zero_trust_access_identity_provider", want: []string{coverageScopeAccount, coverageScopeZone}},
+		"parent":         {resourceType: "cloudflare_waiting_room_event"},
+		"path parameter": {resourceType: "cloudflare_zone_setting"},
+		"unknown":        {resourceType: "cloudflare_record"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, coverageScopes(tc.resourceType))
+		})
+	}
+}
+
+func TestCountCoverage(t *testing.T) {
+	live := []interface{}{
+		map[string]interface{}{"id": "a"},
+		map[string]interface{}{"id": "b"},
+		map[string]interface{}{"id": "c"},
+	}
+
+	managed, unmanaged := countCoverage(live, map[string]bool{"a": true, "z": true})
+
+	assert.Equal(t, 1, managed)
+	assert.Equal(t, 2, unmanaged)
+}
+
+func TestWriteCoverageReport(t *testing.T) {
+	report := buildCoverageReport([]coverageType{
+		{Type: "cloudflare_dns_record", Managed: 2, Unmanaged: 1},
+		{Type: "cloudflare_ruleset", Managed: 0, Unmanaged: 0},
+		{Type: "cloudflare_waiting_room", Errors: []string{"zone abc: 403 Forbidden"}},
+	})
+
+	assert.Equal(t, 3, report.Total)
+	assert.Equal(t, 66.7, report.Coverage)
+	assert.Len(t, report.Resources, 2)
+
+	var out bytes.Buffer
+	require.NoError(t, writeCoverageReport(&out, outputFormatText, report))
+	assert.Equal(t, `RESOURCE TYPE            MANAGED  UNMANAGED  TOTAL  COVERAGE
+cloudflare_dns_record    2        1          3      66.7%
+cloudflare_waiting_room  0        0          0      0.0%
+TOTAL                    2        1          3      66.7%
+
+unable to check cloudflare_waiting_room for zone abc: 403 Forbidden
+`, out.String())
+
+	out.Reset()
+	require.NoError(t, writeCoverageReport(&out, outputFormatJSON, report))
+	assert.Contains(t, out.String(), `"coverage": 66.7`)
+}
@@ -0,0 +1,549 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+const (
+	driftStatusAdded     = "added"
+	driftStatusRemoved   = "removed"
+	driftStatusChanged   = "changed"
+	driftStatusUnchanged = "unchanged"
+	driftStatusUnchecked = "unchecked"
+)
+
+var driftCmd = &cobra.Command{
+	Use:    "drift",
+	Short:  "Compare the Cloudflare resources in existing Terraform configuration with the Cloudflare API",
+	Run:    runDrift(),
+	PreRun: sharedPreRun,
+}
+
+func init() {
+	rootCmd.AddCommand(driftCmd)
+
+	driftCmd.Flags().StringVar(&outputFormat, "format", "text", "Output format of the drift report: text, json, junit or sarif")
+}
+
+// configResource is a `resource` block parsed from existing configuration.
+type configResource struct {
+	Type string
+	Name string
+	File string
+	Line int
+	Body *hclsyntax.Body
+}
+
+// Address is the Terraform address of the resource.
+func (c configResource) Address() string {
+	return c.Type + "." + c.Name
+}
+
+// driftResource is the outcome of comparing a single resource.
+type driftResource struct {
+	Address    string           `json:"address"`
+	Type       string           `json:"type"`
+	Status     string           `json:"status"`
+	ID         string           `json:"id,omitempty"`
+	File       string           `json:"file,omitempty"`
+	Line       int              `json:"line,omitempty"`
+	Attributes []driftAttribute `json:"attributes,omitempty"`
+}
+
+// driftAttribute is a single attribute whose configured value differs from
+// the value in the Cloudflare API.
+type driftAttribute struct {
+	Name   string `json:"name"`
+	Config string `json:"config,omitempty"`
+	Live   string `json:"live,omitempty"`
+}
+
+// driftEvalContext provides the functions `generate` emits so that those
+// expressions can be compared by value.
+var driftEvalContext = &hcl.EvalContext{
+	Functions: map[string]function.Function{
+		"jsonencode": stdlib.JSONEncodeFunc,
+		"jsondecode": stdlib.JSONDecodeFunc,
+	},
+}
+
+// driftMetaArguments are the resource block arguments that are handled by
+// Terraform rather than the provider.
+var driftMetaArguments = map[string]bool{
+	"count":      true,
+	"depends_on": true,
+	"for_each":   true,
+	"lifecycle":  true,
+	"provider":   true,
+}
+
+func runDrift() func(cmd *cobra.Command, args []string) {
+	return func(cmd *cobra.Command, args []string) {
+		zoneID = viper.GetString("zone")
+		accountID = viper.GetString("account")
+
+		if !slices.Contains(driftOutputFormats, outputFormat) {
+			log.Fatalf("unsupported output format %q, must be text, json, junit or sarif", outputFormat)
+		}
+
+		tf, registryPath, cleanup := initTerraform()
+		defer cleanup()
+
+		if !strings.HasPrefix(providerVersionString, "5") {
+			log.Fatalf("drift detection requires v5 of the provider, found %s", providerVersionString)
+		}
+
+		resources, err := parseConfigResources(tf.WorkingDir())
+		if err != nil {
+			log.Fatalf("failed to parse Terraform configuration: %s", err)
+		}
+
+		ps, err := tf.ProvidersSchema(context.Background())
+		if err != nil {
+			log.Fatal("failed to read provider schema", err)
+		}
+
+		s := ps.Schemas[registryPath]
+		if s == nil {
+			log.Fatal("failed to detect provider installation")
+		}
+
+		state, err := tf.Show(context.Background())
+		if err != nil {
+			log.Fatalf("failed to read Terraform state: %s", err)
+		}
+
+		managed := stateResources(state)
+		report := detectDrift(scopedConfigResources(resources, managed), s, stateIDs(managed))
+		if err := writeDriftReport(cmd.OutOrStdout(), outputFormat, report); err != nil {
+			log.Fatal(err)
+		}
+
+		if drifted := countDrift(report); drifted > 0 {
+			log.Fatalf("%d resources have drifted", drifted)
+		}
+	}
+}
+
+// scopedConfigResources returns the configured resources that belong to the
+// targeted zone or account, as only those are fetched from the API. The scope
+// of a resource is read from its `zone_id` or `account_id`, falling back to
+// the value in state when the attribute isn't a literal. Resources whose scope
+// can't be determined are kept.
+func scopedConfigResources(resources []configResource, managed map[string]*tfjson.StateResource) []configResource {
+	scoped := make([]configResource, 0, len(resources))
+	for _, c := range resources {
+		inScope := true
+		if id, ok := configScopeValue(c, managed, "zone_id"); ok {
+			inScope = id == zoneID
+		} else if id, ok := configScopeValue(c, managed, "account_id"); ok {
+			inScope = id == accountID
+		}
+
+		if !inScope {
+			log.WithFields(logrus.Fields{
+				"address": c.Address(),
+			}).Debug("skipping resource outside of the targeted zone or account")
+			continue
+		}
+		scoped = append(scoped, c)
+	}
+
+	return scoped
+}
+
+// configScopeValue returns the value of the `zone_id` or `account_id`
+// attribute of a configured resource.
+func configScopeValue(c configResource, managed map[string]*tfjson.StateResource, name string) (string, bool) {
+	if attr, ok := c.Body.Attributes[name]; ok {
+		v, diags := attr.Expr.Value(nil)
+		if !diags.HasErrors() && v.Type() == cty.String && v.IsKnown() && !v.IsNull() {
+			return v.AsString(), true
+		}
+	}
+
+	if r, ok := managed[c.Address()]; ok {
+		if id, ok := r.AttributeValues[name].(string); ok && id != "" {
+			return id, true
+		}
+	}
+
+	return "", false
+}
+
+// detectDrift fetches the live resources for every type in the configuration
+// and compares each of them to the configured resources.
+func detectDrift(resources []configResource, s *tfjson.ProviderSchema, ids map[string]string) []driftResource {
+	byType := make(map[string][]configResource)
+	var types []string
+	for _, c := range resources {
+		if _, ok := byType[c.Type]; !ok {
+			types = append(types, c.Type)
+		}
+		byType[c.Type] = append(byType[c.Type], c)
+	}
+	sort.Strings(types)
+
+	var report []driftResource
+	for _, resourceType := range types {
+		r := s.ResourceSchemas[resourceType]
+		if r == nil || (resourceToEndpoint[resourceType]["list"] == "" && resourceToEndpoint[resourceType]["get"] == "") {
+			log.WithFields(logrus.Fields{
+				"resource": resourceType,
+			}).Warn("unable to detect drift for unsupported resource type")
+			continue
+		}
+
+		live, err := fetchLiveResources(resourceType, configPathParams(resourceType, byType[resourceType]))
+		if err != nil {
+			// without the live resources every configured resource would be
+			// reported as removed, so only a missing result means there are none.
+			if !isNotFound(err) && !errors.Is(err, errNoResult) {
+				log.WithFields(logrus.Fields{
+					"resource": resourceType,
+				}).Warnf("unable to fetch live resources, skipping drift detection: %s", err)
+				report = append(report, uncheckedResources(resourceType, byType[resourceType])...)
+				continue
+			}
+			log.WithFields(logrus.Fields{
+				"resource": resourceType,
+			}).Debugf("no live resources found: %s", err)
+		}
+		live = expandResourcesV5(resourceType, live)
+
+		report = append(report, compareResources(resourceType, r, byType[resourceType], live, ids)...)
+	}
+
+	return report
+}
+
+// fetchLiveResources retrieves every resource of resourceType in the same way
+// as fetchResourcesV5 but returns any error so that a type which can't be
+// fetched is reported rather than ending the run.
+func fetchLiveResources(resourceType string, pathParams []string) ([]interface{}, error) {
+	endpoints, err := resourceEndpointsV5(resourceType, pathParams)
+	if err != nil {
+		return nil, err
+	}
+
+	var live []interface{}
+	for i, endpoint := range endpoints {
+		param := ""
+		if len(pathParams) > 0 {
+			param = pathParams[i]
+		}
+
+		data, err := fetchEndpoint(resourceType, endpoint, param)
+		if err != nil {
+			return nil, err
+		}
+		live = append(live, data...)
+	}
+
+	return live, nil
+}
+
+// uncheckedResources reports each configured resource of a type whose live
+// resources couldn't be fetched.
+func uncheckedResources(resourceType string, resources []configResource) []driftResource {
+	report := make([]driftResource, 0, len(resources))
+	for _, c := range resources {
+		report = append(report, driftResource{Address: c.Address(), Type: resourceType, Status: driftStatusUnchecked, File: c.File, Line: c.Line})
+	}
+
+	return report
+}
+
+// compareResources matches the configured resources of a single type to the
+// live objects, first by the ID tracked in state and then by the resource name
+// `generate` would have used.
+func compareResources(resourceType string, r *tfjson.Schema, resources []configResource, live []interface{}, ids map[string]string) []driftResource {
+	liveByID := make(map[string]map[string]interface{})
+	liveByName := make(map[string]string)
+	var liveIDs []string
+	for _, l := range live {
+		data, ok := l.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id := resourceIDFromData(data)
+		liveByID[id] = data
+		liveByName[terraformResourceName(id)] = id
+		liveIDs = append(liveIDs, id)
+	}
+
+	matched := make(map[string]bool)
+	var report []driftResource
+	for _, c := range resources {
+		result := driftResource{Address: c.Address(), Type: resourceType, File: c.File, Line: c.Line}
+
+		id, ok := ids[c.Address()]
+		if !ok {
+			id = liveByName[c.Name]
+		}
+
+		data, found := liveByID[id]
+		if !found {
+			result.Status = driftStatusRemoved
+			report = append(report, result)
+			continue
+		}
+		matched[id] = true
+		result.ID = id
+
+		liveBody, err := renderLiveResource(r, resourceType, data)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"address": c.Address(),
+			}).Warnf("failed to render live resource: %s", err)
+			continue
+		}
+
+		result.Attributes = diffValues(r.Block, bodyValues(c.Body), bodyValues(liveBody))
+		result.Status = driftStatusUnchanged
+		if len(result.Attributes) > 0 {
+			result.Status = driftStatusChanged
+		}
+		report = append(report, result)
+	}
+
+	for _, id := range liveIDs {
+		if matched[id] {
+			continue
+		}
+		report = append(report, driftResource{
+			Address: resourceType + "." + terraformResourceName(id),
+			Type:    resourceType,
+			Status:  driftStatusAdded,
+			ID:      id,
+		})
+	}
+
+	return report
+}
+
+// configPathParams returns the settings referenced by the configuration for
+// resources that are fetched per setting.
+func configPathParams(resourceType string, resources []configResource) []string {
+	if resourceType != "cloudflare_zone_setting" && resourceType != "cloudflare_hostname_tls_setting" {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var params []string
+	for _, c := range resources {
+		attr, ok := c.Body.Attributes["setting_id"]
+		if !ok {
+			continue
+		}
+		v, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() || v.Type() != cty.String || v.IsNull() {
+			continue
+		}
+		if setting := v.AsString(); !seen[setting] {
+			seen[setting] = true
+			params = append(params, setting)
+		}
+	}
+
+	return params
+}
+
+// renderLiveResource renders an API result in the same way as `generate` and
+// parses it back so it can be compared to existing configuration.
+func renderLiveResource(r *tfjson.Schema, resourceType string, data map[string]interface{}) (*hclsyntax.Body, error) {
+	f := hclwrite.NewEmptyFile()
+	resource := f.Body().AppendNewBlock("resource", []string{resourceType, "live"}).Body()
+	writeResourceBody(r, data, resource)
+	postProcess(f, resourceType)
+
+	file, diags := hclsyntax.ParseConfig(hclwrite.Format(f.Bytes()), resourceType+".tf", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	return file.Body.(*hclsyntax.Body).Blocks[0].Body, nil
+}
+
+// parseConfigResources reads all Cloudflare `resource` blocks from the `.tf`
+// files within dir.
+func parseConfigResources(dir string) ([]configResource, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var resources []configResource
+	for _, filename := range files {
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		file, diags := hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+
+		rel, err := filepath.Rel(dir, filename)
+		if err != nil {
+			rel = filename
+		}
+
+		for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+			if block.Type != "resource" || len(block.Labels) != 2 || !strings.HasPrefix(block.Labels[0], "cloudflare_") {
+				continue
+			}
+
+			if _, ok := block.Body.Attributes["count"]; ok {
+				log.Debugf("skipping %s.%s as it uses count", block.Labels[0], block.Labels[1])
+				continue
+			}
+			if _, ok := block.Body.Attributes["for_each"]; ok {
+				log.Debugf("skipping %s.%s as it uses for_each", block.Labels[0], block.Labels[1])
+				continue
+			}
+
+			resources = append(resources, configResource{
+				Type: block.Labels[0],
+				Name: block.Labels[1],
+				File: rel,
+				Line: block.DefRange().Start.Line,
+				Body: block.Body,
+			})
+		}
+	}
+
+	return resources, nil
+}
+
+// bodyValues evaluates the attributes and nested blocks of a body. Any
+// expression that can't be evaluated statically (such as a reference to a
+// variable) is unknown as there is nothing to compare it to.
+func bodyValues(body *hclsyntax.Body) map[string]cty.Value {
+	values := make(map[string]cty.Value)
+	for name, attr := range body.Attributes {
+		if driftMetaArguments[name] {
+			continue
+		}
+
+		v, diags := attr.Expr.Value(driftEvalContext)
+		if diags.HasErrors() {
+			v = cty.DynamicVal
+		}
+		values[name] = v
+	}
+
+	blocks := make(map[string][]cty.Value)
+	for _, block := range body.Blocks {
+		if driftMetaArguments[block.Type] {
+			continue
+		}
+		blocks[block.Type] = append(blocks[block.Type], cty.ObjectVal(bodyValues(block.Body)))
+	}
+	for name, v := range blocks {
+		values[name] = cty.TupleVal(v)
+	}
+
+	return values
+}
+
+// diffValues compares the configured and live values. Attributes only set in
+// the API are reported when they can be configured and aren't also computed
+// by the provider, otherwise every resource would report provider defaults.
+func diffValues(schema *tfjson.SchemaBlock, config, live map[string]cty.Value) []driftAttribute {
+	names := make(map[string]struct{})
+	for name := range config {
+		names[name] = struct{}{}
+	}
+	for name := range live {
+		names[name] = struct{}{}
+	}
+
+	var attributes []driftAttribute
+	for name := range names {
+		if attr, ok := schema.Attributes[name]; ok && attr.Sensitive {
+			continue
+		}
+
+		c, inConfig := config[name]
+		l, inLive := live[name]
+
+		if inConfig && !c.IsWhollyKnown() {
+			continue
+		}
+
+		if !inConfig {
+			if attr, ok := schema.Attributes[name]; ok && attr.Computed {
+				continue
+			}
+		}
+
+		if inConfig && inLive && c.RawEquals(l) {
+			continue
+		}
+
+		a := driftAttribute{Name: name}
+		if inConfig {
+			a.Config = driftValueString(c)
+		}
+		if inLive {
+			a.Live = driftValueString(l)
+		}
+		attributes = append(attributes, a)
+	}
+	sort.Slice(attributes, func(i, j int) bool { return attributes[i].Name < attributes[j].Name })
+
+	return attributes
+}
+
+// driftValueString renders a value as HCL for display.
+func driftValueString(v cty.Value) string {
+	return strings.TrimSpace(string(hclwrite.Format(hclwrite.TokensForValue(v).Bytes())))
+}
+
+// stateIDs maps each resource address in state to the ID of the object.
+func stateIDs(resources map[string]*tfjson.StateResource) map[string]string {
+	ids := make(map[string]string, len(resources))
+	for address, r := range resources {
+		switch id := r.AttributeValues["id"].(type) {
+		case string:
+			ids[address] = id
+		case float64:
+			ids[address] = fmt.Sprintf("%f", id)
+		}
+	}
+
+	return ids
+}
+
+// countDrift returns how many resources were added, removed or changed.
+func countDrift(report []driftResource) int {
+	drifted := 0
+	for _, r := range report {
+		if r.Status != driftStatusUnchanged && r.Status != driftStatusUnchecked {
+			drifted++
+		}
+	}
+
+	return drifted
+}
@@ -0,0 +1,274 @@
+package cmd
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	outputFormatText  = "text"
+	outputFormatJSON  = "json"
+	outputFormatJUnit = "junit"
+	outputFormatSARIF = "sarif"
+)
+
+// driftOutputFormats are the formats the drift report can be written in.
+var driftOutputFormats = []string{"", outputFormatText, outputFormatJSON, outputFormatJUnit, outputFormatSARIF}
+
+// driftStatusSymbols are the prefixes used for each status in text output.
+var driftStatusSymbols = map[string]string{
+	driftStatusAdded:     "+",
+	driftStatusRemoved:   "-",
+	driftStatusChanged:   "~",
+	driftStatusUnchecked: "?",
+}
+
+// driftStatusDescriptions describe each status for JUnit and SARIF output.
+var driftStatusDescriptions = map[string]string{
+	driftStatusAdded:     "Resource exists in Cloudflare but not in the Terraform configuration",
+	driftStatusRemoved:   "Resource is in the Terraform configuration but no longer exists in Cloudflare",
+	driftStatusChanged:   "Resource attributes in Cloudflare differ from the Terraform configuration",
+	driftStatusUnchecked: "Resource couldn't be compared as it couldn't be fetched from Cloudflare",
+}
+
+// writeDriftReport outputs the drift report in the requested format.
+func writeDriftReport(w io.Writer, format string, report []driftResource) error {
+	switch format {
+	case "", outputFormatText:
+		writeDriftText(w, report)
+		return nil
+	case outputFormatJSON:
+		if report == nil {
+			report = []driftResource{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case outputFormatJUnit:
+		return writeDriftJUnit(w, report)
+	case outputFormatSARIF:
+		return writeDriftSARIF(w, report)
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+// driftMessage summarises a single drifted resource.
+func driftMessage(r driftResource) string {
+	var b strings.Builder
+	b.WriteString(driftStatusDescriptions[r.Status])
+	for _, a := range r.Attributes {
+		fmt.Fprintf(&b, "\n%s: %s => %s", a.Name, driftDisplayValue(a.Config), driftDisplayValue(a.Live))
+	}
+
+	return b.String()
+}
+
+// driftDisplayValue shows an absent value as `null`.
+func driftDisplayValue(s string) string {
+	if s == "" {
+		return "null"
+	}
+	return s
+}
+
+func writeDriftText(w io.Writer, report []driftResource) {
+	counts := make(map[string]int)
+	for _, r := range report {
+		counts[r.Status]++
+		if r.Status == driftStatusUnchanged {
+			continue
+		}
+
+		location := ""
+		if r.File != "" {
+			location = fmt.Sprintf(" (%s:%d)", r.File, r.Line)
+		}
+		fmt.Fprintf(w, "%s %s%s\n", driftStatusSymbols[r.Status], r.Address, location)
+
+		for _, a := range r.Attributes {
+			fmt.Fprintf(w, "    %s: %s => %s\n", a.Name, driftDisplayValue(a.Config), driftDisplayValue(a.Live))
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d changed, %d removed, %d added, %d unchanged", counts[driftStatusChanged], counts[driftStatusRemoved], counts[driftStatusAdded], counts[driftStatusUnchanged])
+	if counts[driftStatusUnchecked] > 0 {
+		fmt.Fprintf(w, ", %d unchecked", counts[driftStatusUnchecked])
+	}
+	fmt.Fprintln(w)
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr,omitempty"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeDriftJUnit outputs a test case per resource with drift reported as a
+// failure and resources that couldn't be checked as skipped.
+func writeDriftJUnit(w io.Writer, report []driftResource) error {
+	suite := junitTestSuite{Name: "cf-terraforming drift", Tests: len(report)}
+	for _, r := range report {
+		tc := junitTestCase{ClassName: r.Type, Name: r.Address, File: r.File, Line: r.Line}
+		switch r.Status {
+		case driftStatusUnchanged:
+		case driftStatusUnchecked:
+			suite.Skipped++
+			tc.Skipped = &junitSkipped{Message: driftStatusDescriptions[r.Status]}
+		default:
+			suite.Failures++
+			tc.Failure = &junitFailure{
+				Message: driftStatusDescriptions[r.Status],
+				Type:    r.Status,
+				Text:    driftMessage(r),
+			}
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Version        string      `json:"version,omitempty"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// writeDriftSARIF outputs drifted resources as SARIF results so they can be
+// surfaced as annotations against the configuration. Resources that couldn't
+// be checked are included as notes.
+func writeDriftSARIF(w io.Writer, report []driftResource) error {
+	driver := sarifDriver{
+		Name:           "cf-terraforming",
+		InformationURI: "https://github.com/cloudflare/cf-terraforming",
+		Version:        versionString,
+	}
+	for _, status := range []string{driftStatusAdded, driftStatusChanged, driftStatusRemoved, driftStatusUnchecked} {
+		driver.Rules = append(driver.Rules, sarifRule{
+			ID:               "drift/" + status,
+			ShortDescription: sarifMessage{Text: driftStatusDescriptions[status]},
+		})
+	}
+
+	run := sarifRun{Tool: sarifTool{Driver: driver}, Results: []sarifResult{}}
+	for _, r := range report {
+		if r.Status == driftStatusUnchanged {
+			continue
+		}
+
+		result := sarifResult{
+			RuleID:  "drift/" + r.Status,
+			Level:   "warning",
+			Message: sarifMessage{Text: r.Address + ": " + driftMessage(r)},
+		}
+		if r.Status == driftStatusUnchecked {
+			result.Level = "note"
+		}
+		if r.File != "" {
+			result.Locations = []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: r.File},
+					Region:           sarifRegion{StartLine: r.Line},
+				},
+			}}
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
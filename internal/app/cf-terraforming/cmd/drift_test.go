@@ -0,0 +1,256 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go/v4"
+	"github.com/cloudflare/cloudflare-go/v4/option"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+var driftTestSchema = &tfjson.Schema{
+	Block: &tfjson.SchemaBlock{
+		Attributes: map[string]*tfjson.SchemaAttribute{
+			"id":      {AttributeType: cty.String, Computed: true},
+			"name":    {AttributeType: cty.String, Required: true},
+			"content": {AttributeType: cty.String, Optional: true},
+			"ttl":     {AttributeType: cty.Number, Optional: true},
+			"proxied": {AttributeType: cty.Bool, Optional: true, Computed: true},
+			"zone_id": {AttributeType: cty.String, Required: true},
+		},
+	},
+}
+
+const driftTestConfig = `
+variable "zone_id" {}
+
+resource "cloudflare_dns_record" "www" {
+  zone_id = var.zone_id
+  name    = "www"
+  content = "192.0.2.1"
+  ttl     = 300
+}
+
+resource "cloudflare_dns_record" "terraform_managed_resource_api" {
+  zone_id = var.zone_id
+  name    = "api"
+  content = "192.0.2.2"
+}
+
+resource "cloudflare_dns_record" "old" {
+  zone_id = var.zone_id
+  name    = "old"
+}
+
+resource "cloudflare_dns_record" "counted" {
+  count = 2
+  name  = "counted"
+}
+`
+
+func writeDriftTestConfig(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "dns.tf"), []byte(driftTestConfig), 0o644))
+
+	return dir
+}
+
+func TestParseConfigResources(t *testing.T) {
+	resources, err := parseConfigResources(writeDriftTestConfig(t))
+	require.NoError(t, err)
+
+	var addresses []string
+	for _, r := range resources {
+		addresses = append(addresses, r.Address())
+	}
+
+	assert.Equal(t, []string{
+		"cloudflare_dns_record.www",
+		"cloudflare_dns_record.terraform_managed_resource_api",
+		"cloudflare_dns_record.old",
+	}, addresses)
+	assert.Equal(t, "dns.tf", resources[0].File)
+	assert.Equal(t, 4, resources[0].Line)
+}
+
+func TestCompareResources(t *testing.T) {
+	zoneID = cloudflareTestZoneID
+	defer func() { zoneID = "" }()
+
+	resources, err := parseConfigResources(writeDriftTestConfig(t))
+	require.NoError(t, err)
+
+	live := []interface{}{
+		map[string]interface{}{"id": "www", "name": "www", "content": "192.0.2.10", "ttl": float64(300), "proxied": true},
+		map[string]interface{}{"id": "api", "name": "api", "content": "192.0.2.2", "proxied": false},
+		map[string]interface{}{"id": "new", "name": "new", "content": "192.0.2.3"},
+	}
+	ids := map[string]string{"cloudflare_dns_record.www": "www"}
+
+	report := compareResources("cloudflare_dns_record", driftTestSchema, resources, live, ids)
+
+	assert.Equal(t, []driftResource{
+		{
+			Address: "cloudflare_dns_record.www", Type: "cloudflare_dns_record", Status: driftStatusChanged, ID: "www", File: "dns.tf", Line: 4,
+			Attributes: []driftAttribute{{Name: "content", Config: `"192.0.2.1"`, Live: `"192.0.2.10"`}},
+		},
+		{Address: "cloudflare_dns_record.terraform_managed_resource_api", Type: "cloudflare_dns_record", Status: driftStatusUnchanged, ID: "api", File: "dns.tf", Line: 11},
+		{Address: "cloudflare_dns_record.old", Type: "cloudflare_dns_record", Status: driftStatusRemoved, File: "dns.tf", Line: 17},
+		{Address: "cloudflare_dns_record.terraform_managed_resource_new", Type: "cloudflare_dns_record", Status: driftStatusAdded, ID: "new"},
+	}, report)
+	assert.Equal(t, 3, countDrift(report))
+}
+
+func TestDetectDrift_FetchErrors(t *testing.T) {
+	zoneID, accountID = cloudflareTestZoneID, ""
+	defer func() { zoneID = "" }()
+
+	resources, err := parseConfigResources(writeDriftTestConfig(t))
+	require.NoError(t, err)
+	s := &tfjson.ProviderSchema{ResourceSchemas: map[string]*tfjson.Schema{"cloudflare_dns_record": driftTestSchema}}
+
+	status := http.StatusForbidden
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"success": false, "errors": [{"code": 10000, "message": "Authentication error"}], "messages": [], "result": null}`))
+	}))
+	defer server.Close()
+
+	defer func(c *cloudflare.Client) { api = c }(api)
+	api = cloudflare.NewClient(option.WithBaseURL(server.URL), option.WithAPIToken("token"), option.WithMaxRetries(0))
+
+	report := detectDrift(resources, s, nil)
+	assert.Equal(t, []driftResource{
+		{Address: "cloudflare_dns_record.www", Type: "cloudflare_dns_record", Status: driftStatusUnchecked, File: "dns.tf", Line: 4},
+		{Address: "cloudflare_dns_record.terraform_managed_resource_api", Type: "cloudflare_dns_record", Status: driftStatusUnchecked, File: "dns.tf", Line: 11},
+		{Address: "cloudflare_dns_record.old", Type: "cloudflare_dns_record", Status: driftStatusUnchecked, File: "dns.tf", Line: 17},
+	}, report)
+	assert.Equal(t, 0, countDrift(report))
+
+	status = http.StatusNotFound
+	report = detectDrift(resources, s, nil)
+	assert.Len(t, report, 3)
+	for _, r := range report {
+		assert.Equal(t, driftStatusRemoved, r.Status)
+	}
+}
+
+func TestScopedConfigResources(t *testing.T) {
+	zoneID = cloudflareTestZoneID
+	defer func() { zoneID = "" }()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "scope.tf"), []byte(`
+resource "cloudflare_dns_record" "literal" {
+  zone_id = "`+cloudflareTestZoneID+`"
+  name    = "literal"
+}
+
+resource "cloudflare_dns_record" "other_zone" {
+  zone_id = "023e105f4ecef8ad9ca31a8372d0c353"
+  name    = "other"
+}
+
+resource "cloudflare_dns_record" "from_state" {
+  zone_id = var.other_zone_id
+  name    = "state"
+}
+
+resource "cloudflare_list" "account" {
+  account_id = "`+cloudflareTestAccountID+`"
+  name       = "list"
+}
+
+resource "cloudflare_dns_record" "unknown" {
+  zone_id = var.zone_id
+  name    = "unknown"
+}
+`), 0o644))
+
+	resources, err := parseConfigResources(dir)
+	require.NoError(t, err)
+
+	managed := map[string]*tfjson.StateResource{
+		"cloudflare_dns_record.from_state": {AttributeValues: map[string]interface{}{"zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}},
+	}
+
+	var addresses []string
+	for _, r := range scopedConfigResources(resources, managed) {
+		addresses = append(addresses, r.Address())
+	}
+	assert.Equal(t, []string{"cloudflare_dns_record.literal", "cloudflare_dns_record.unknown"}, addresses)
+}
+
+func TestWriteDriftReport(t *testing.T) {
+	report := []driftResource{
+		{Address: "cloudflare_dns_record.www", Type: "cloudflare_dns_record", Status: driftStatusChanged, File: "dns.tf", Line: 4, Attributes: []driftAttribute{{Name: "ttl", Config: "300", Live: "1"}}},
+		{Address: "cloudflare_dns_record.api", Type: "cloudflare_dns_record", Status: driftStatusUnchanged, File: "dns.tf", Line: 11},
+		{Address: "cloudflare_dns_record.terraform_managed_resource_new", Type: "cloudflare_dns_record", Status: driftStatusAdded, ID: "new"},
+	}
+
+	t.Run("text", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, writeDriftReport(&out, outputFormatText, report))
+		assert.Equal(t, `~ cloudflare_dns_record.www (dns.tf:4)
+    ttl: 300 => 1
++ cloudflare_dns_record.terraform_managed_resource_new
+
+1 changed, 0 removed, 1 added, 1 unchanged
+`, out.String())
+	})
+
+	t.Run("unchecked", func(t *testing.T) {
+		unchecked := append(report, driftResource{Address: "cloudflare_dns_record.old", Type: "cloudflare_dns_record", Status: driftStatusUnchecked, File: "dns.tf", Line: 17})
+
+		var out bytes.Buffer
+		require.NoError(t, writeDriftReport(&out, outputFormatText, unchecked))
+		assert.Equal(t, `~ cloudflare_dns_record.www (dns.tf:4)
+    ttl: 300 => 1
++ cloudflare_dns_record.terraform_managed_resource_new
+? cloudflare_dns_record.old (dns.tf:17)
+
+1 changed, 0 removed, 1 added, 1 unchanged, 1 unchecked
+`, out.String())
+
+		out.Reset()
+		require.NoError(t, writeDriftReport(&out, outputFormatJUnit, unchecked))
+		assert.Contains(t, out.String(), `<testsuite name="cf-terraforming drift" tests="4" failures="2" skipped="1">`)
+		assert.Contains(t, out.String(), `<skipped message="Resource couldn&#39;t be compared as it couldn&#39;t be fetched from Cloudflare"></skipped>`)
+	})
+
+	t.Run("junit", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, writeDriftReport(&out, outputFormatJUnit, report))
+		assert.Contains(t, out.String(), `<testsuite name="cf-terraforming drift" tests="3" failures="2">`)
+		assert.Contains(t, out.String(), `<testcase classname="cloudflare_dns_record" name="cloudflare_dns_record.api" file="dns.tf" line="11"></testcase>`)
+	})
+
+	t.Run("sarif", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, writeDriftReport(&out, outputFormatSARIF, report))
+
+		var sarif sarifLog
+		require.NoError(t, json.Unmarshal(out.Bytes(), &sarif))
+		assert.Equal(t, "2.1.0", sarif.Version)
+		assert.Len(t, sarif.Runs[0].Results, 2)
+		assert.Equal(t, "drift/changed", sarif.Runs[0].Results[0].RuleID)
+		assert.Equal(t, "dns.tf", sarif.Runs[0].Results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+		assert.Empty(t, sarif.Runs[0].Results[1].Locations)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		assert.Error(t, writeDriftReport(&bytes.Buffer{}, "yaml", report))
+	})
+}
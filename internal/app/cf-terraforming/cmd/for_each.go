@@ -0,0 +1,442 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/sirupsen/logrus"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// forEachKeyAttributes are the attributes that make up the readable key of a
+// resource collapsed by `--for-each`, in order. Types that aren't listed are
+// keyed by the first of forEachDefaultKeyAttributes that is set.
+var forEachKeyAttributes = map[string][]string{
+	"cloudflare_dns_record":      {"name", "type"},
+	"cloudflare_custom_hostname": {"hostname"},
+	"cloudflare_zone_setting":    {"setting_id"},
+}
+
+var forEachDefaultKeyAttributes = []string{"name", "hostname", "title", "pattern", "description"}
+
+// collapsedResource is a resource block that is collapsed into an instance
+// of a single `for_each` resource. Resources being imported have no block and
+// are keyed from their API result instead, which the block would be generated
+// from.
+type collapsedResource struct {
+	name       string
+	key        string
+	block      *hclwrite.Block
+	attributes map[string]interface{}
+}
+
+// literal returns the value of the string attribute name.
+func (r *collapsedResource) literal(name string) string {
+	if r.block == nil {
+		s, _ := r.attributes[name].(string)
+		return s
+	}
+
+	s, _ := literalString(r.block.Body().GetAttribute(name))
+	return s
+}
+
+// collapseForEach replaces the resources of resourceType in f with a single
+// resource using `for_each`. Attributes that are the same for every resource
+// are set on the resource directly and the others are read from `each.value`.
+// The values are kept in a `locals` block, or returned as JSON to be written
+// alongside the configuration when dataFile is set, and the import blocks
+// target the instance keys. f is returned as is, and the reason logged, if the
+// resources can't be collapsed.
+func collapseForEach(f *hclwrite.File, resourceType, dataFile string) (*hclwrite.File, []byte) {
+	var resources []*collapsedResource
+	for _, block := range f.Body().Blocks() {
+		if block.Type() != "resource" || len(block.Labels()) != 2 || block.Labels()[0] != resourceType {
+			continue
+		}
+		if nested := block.Body().Blocks(); len(nested) > 0 {
+			log.WithFields(logrus.Fields{
+				"resource": resourceType,
+			}).Warnf("not collapsing %s with --for-each as %s.%s has a nested %s block, which for_each can't vary by instance", resourceType, resourceType, block.Labels()[1], nested[0].Type())
+			return f, nil
+		}
+		resources = append(resources, &collapsedResource{name: block.Labels()[1], block: block})
+	}
+	if len(resources) < 2 {
+		if len(resources) == 1 {
+			log.WithFields(logrus.Fields{
+				"resource": resourceType,
+			}).Infof("not collapsing %s with --for-each as there is only one resource", resourceType)
+		}
+		return f, nil
+	}
+	assignForEachKeys(resourceType, resources)
+
+	// Attributes with the same value for every resource stay on the
+	// resource, the rest vary by instance.
+	var shared, varying []string
+	for _, name := range forEachAttributeNames(resources) {
+		if sharedAttribute(resources, name) {
+			shared = append(shared, name)
+		} else {
+			varying = append(varying, name)
+		}
+	}
+
+	collapsed := hclwrite.NewEmptyFile()
+	body := collapsed.Body()
+	var data []byte
+	local := strings.TrimPrefix(resourceType, "cloudflare_")
+	if dataFile == "" {
+		body.AppendNewBlock("locals", nil).Body().SetAttributeRaw(local, forEachLocalTokens(resources, varying))
+		body.AppendNewline()
+	} else {
+		var err error
+		data, err = forEachJSON(resources, varying)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"resource": resourceType,
+			}).Warnf("not collapsing %s with --for-each-data-dir as %s", resourceType, err)
+			return f, nil
+		}
+	}
+
+	resource := body.AppendNewBlock("resource", []string{resourceType, terraformResourceNamePrefix}).Body()
+	if dataFile == "" {
+		resource.SetAttributeTraversal("for_each", hcl.Traversal{
+			hcl.TraverseRoot{Name: "local"},
+			hcl.TraverseAttr{Name: local},
+		})
+	} else {
+		resource.SetAttributeRaw("for_each", hclwrite.Tokens{{
+			Type:  hclsyntax.TokenIdent,
+			Bytes: []byte(fmt.Sprintf(`jsondecode(file(%q))`, dataFile)),
+		}})
+	}
+	for _, name := range forEachAttributeNames(resources) {
+		if contains(shared, name) {
+			resource.SetAttributeRaw(name, resources[0].block.Body().GetAttribute(name).Expr().BuildTokens(nil))
+			continue
+		}
+		resource.SetAttributeTraversal(name, hcl.Traversal{
+			hcl.TraverseRoot{Name: "each"},
+			hcl.TraverseAttr{Name: "value"},
+			hcl.TraverseAttr{Name: name},
+		})
+	}
+	body.AppendNewline()
+
+	appendForEachImports(body, f, resourceType, resources)
+
+	for _, block := range f.Body().Blocks() {
+		if block.Type() == "resource" && len(block.Labels()) == 2 && block.Labels()[0] == resourceType {
+			continue
+		}
+		if block.Type() == "import" && importTarget(block, resourceType, resources) != nil {
+			continue
+		}
+		body.AppendBlock(block)
+		body.AppendNewline()
+	}
+
+	return collapsed, data
+}
+
+// assignForEachKeys keys each resource by its identifying attributes. When
+// they aren't set or the key isn't unique, the resource ID is used instead.
+func assignForEachKeys(resourceType string, resources []*collapsedResource) {
+	counts := make(map[string]int)
+	for _, r := range resources {
+		r.key = forEachKey(resourceType, r)
+		counts[r.key]++
+	}
+
+	for _, r := range resources {
+		id := strings.TrimPrefix(r.name, terraformResourceNamePrefix+"_")
+		switch {
+		case r.key == "":
+			r.key = id
+		case counts[r.key] > 1:
+			r.key = r.key + "_" + id
+		}
+	}
+
+	sort.SliceStable(resources, func(i, j int) bool { return resources[i].key < resources[j].key })
+}
+
+// forEachKey builds the readable key of a resource from its identifying
+// attributes, returning an empty string if they aren't set.
+func forEachKey(resourceType string, r *collapsedResource) string {
+	if attributes, ok := forEachKeyAttributes[resourceType]; ok {
+		var parts []string
+		for _, name := range attributes {
+			if s := r.literal(name); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, "_")
+	}
+
+	for _, name := range forEachDefaultKeyAttributes {
+		if s := r.literal(name); s != "" {
+			return s
+		}
+	}
+
+	return ""
+}
+
+// forEachAttributeNames returns the attributes set on any of the resources.
+func forEachAttributeNames(resources []*collapsedResource) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, r := range resources {
+		for name := range r.block.Body().Attributes() {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// sharedAttribute reports whether every resource sets name to the same value.
+func sharedAttribute(resources []*collapsedResource, name string) bool {
+	first := resources[0].block.Body().GetAttribute(name)
+	if first == nil {
+		return false
+	}
+	for _, r := range resources[1:] {
+		attr := r.block.Body().GetAttribute(name)
+		if attr == nil || !expressionsEqual(first.Expr().BuildTokens(nil), attr.Expr().BuildTokens(nil)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// forEachLocalTokens builds the map of values that vary by instance, keeping
+// any references as is. Attributes a resource doesn't set are null.
+func forEachLocalTokens(resources []*collapsedResource, varying []string) hclwrite.Tokens {
+	instances := make([]hclwrite.ObjectAttrTokens, 0, len(resources))
+	for _, r := range resources {
+		attrs := make([]hclwrite.ObjectAttrTokens, 0, len(varying))
+		for _, name := range varying {
+			value := hclwrite.TokensForValue(cty.NullVal(cty.String))
+			if attr := r.block.Body().GetAttribute(name); attr != nil {
+				value = attr.Expr().BuildTokens(nil)
+			}
+			attrs = append(attrs, hclwrite.ObjectAttrTokens{
+				Name:  hclwrite.TokensForIdentifier(name),
+				Value: value,
+			})
+		}
+		instances = append(instances, hclwrite.ObjectAttrTokens{
+			Name:  hclwrite.TokensForValue(cty.StringVal(r.key)),
+			Value: hclwrite.TokensForObject(attrs),
+		})
+	}
+
+	return hclwrite.TokensForObject(instances)
+}
+
+// forEachJSON encodes the values that vary by instance as JSON. Unlike a
+// `locals` block, JSON can only hold literal values.
+func forEachJSON(resources []*collapsedResource, varying []string) ([]byte, error) {
+	instances := make(map[string]cty.Value, len(resources))
+	for _, r := range resources {
+		values := make(map[string]cty.Value, len(varying))
+		for _, name := range varying {
+			attr := r.block.Body().GetAttribute(name)
+			if attr == nil {
+				values[name] = cty.NullVal(cty.String)
+				continue
+			}
+			v, ok := evaluateTokens(attr.Expr().BuildTokens(nil))
+			if !ok {
+				return nil, fmt.Errorf("%s of %s isn't a literal value", name, r.name)
+			}
+			values[name] = v
+		}
+		instances[r.key] = cty.ObjectVal(values)
+	}
+
+	v := cty.ObjectVal(instances)
+	b, err := ctyjson.Marshal(v, v.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, b, "", "  "); err != nil {
+		return nil, err
+	}
+	out.WriteString("\n")
+
+	return out.Bytes(), nil
+}
+
+// appendForEachImports rewrites the import blocks of the collapsed resources
+// to target their instance. With `--import-for-each` they're combined into a
+// single block when every ID is a literal.
+func appendForEachImports(body *hclwrite.Body, f *hclwrite.File, resourceType string, resources []*collapsedResource) {
+	type collapsedImport struct {
+		key   string
+		attrs map[string]*hclwrite.Attribute
+	}
+
+	var imports []collapsedImport
+	for _, block := range f.Body().Blocks() {
+		if block.Type() != "import" {
+			continue
+		}
+		if r := importTarget(block, resourceType, resources); r != nil {
+			imports = append(imports, collapsedImport{key: r.key, attrs: block.Body().Attributes()})
+		}
+	}
+	if len(imports) == 0 {
+		return
+	}
+	sort.SliceStable(imports, func(i, j int) bool { return imports[i].key < imports[j].key })
+
+	if useImportForEach {
+		values := make(map[string]cty.Value, len(imports))
+		useIdentity := imports[0].attrs["identity"] != nil
+		for _, imp := range imports {
+			attr := imp.attrs["id"]
+			if useIdentity {
+				attr = imp.attrs["identity"]
+			}
+			if attr == nil {
+				break
+			}
+			v, ok := evaluateTokens(attr.Expr().BuildTokens(nil))
+			if !ok {
+				break
+			}
+			values[imp.key] = v
+		}
+		if len(values) == len(imports) {
+			appendForEachImport(body, resourceType, terraformResourceNamePrefix, values, useIdentity)
+			body.AppendNewline()
+			return
+		}
+	}
+
+	for _, imp := range imports {
+		block := body.AppendNewBlock("import", nil).Body()
+		block.SetAttributeTraversal("to", hcl.Traversal{
+			hcl.TraverseRoot{Name: resourceType},
+			hcl.TraverseAttr{Name: terraformResourceNamePrefix},
+			hcl.TraverseIndex{Key: cty.StringVal(imp.key)},
+		})
+		for _, name := range sortedAttributeNames(imp.attrs) {
+			if name != "to" {
+				block.SetAttributeRaw(name, imp.attrs[name].Expr().BuildTokens(nil))
+			}
+		}
+		body.AppendNewline()
+	}
+}
+
+// importTarget returns the collapsed resource an import block targets.
+func importTarget(block *hclwrite.Block, resourceType string, resources []*collapsedResource) *collapsedResource {
+	to := block.Body().GetAttribute("to")
+	if to == nil {
+		return nil
+	}
+
+	address := tokensString(to.Expr().BuildTokens(nil))
+	for _, r := range resources {
+		if address == resourceType+"."+r.name {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// forEachDataFile returns the path of the JSON file holding the values of
+// resourceType when using `--for-each-data-dir`, as referenced from the
+// configuration, and where it's written to.
+func forEachDataFile(resourceType string) (reference, path string) {
+	if forEachDataDir == "" {
+		return "", ""
+	}
+
+	return dataFileLocation(forEachDataDir, strings.TrimPrefix(resourceType, "cloudflare_")+".json")
+}
+
+// dataFileLocation returns how a data file named name within dir is
+// referenced from the configuration and where it's written to.
+func dataFileLocation(dir, name string) (reference, path string) {
+	reference, path = dataFileDir(dir)
+	return reference + "/" + name, filepath.Join(path, name)
+}
+
+// dataFileDir returns how dir is referenced from the configuration and where
+// it is. The configuration is expected to be written to the working directory,
+// or the directory it's merged into with `--merge-into`, unless it's a module,
+// in which case data files are written alongside it.
+func dataFileDir(dir string) (reference, path string) {
+	if moduleDir != "" {
+		return "${path.module}", filepath.Join(moduleDir, "modules", generatedModuleName)
+	}
+
+	path = filepath.Clean(dir)
+	if filepath.IsAbs(path) {
+		return filepath.ToSlash(path), path
+	}
+
+	reference = "${path.module}"
+	if path != "." {
+		reference += "/" + filepath.ToSlash(path)
+	}
+	if mergeIntoDir != "" {
+		path = filepath.Join(mergeIntoDir, path)
+	}
+
+	return reference, path
+}
+
+// generatedDataFile is a data file written alongside generated configuration.
+type generatedDataFile struct {
+	// reference is how the configuration refers to the file, such as
+	// `${path.module}/cloudflare_list_example_items.json`.
+	reference string
+	data      []byte
+}
+
+// modulePath returns the path of the file relative to the configuration
+// referencing it. It is false for files referenced by an absolute path.
+func (d generatedDataFile) modulePath() (string, bool) {
+	if !strings.HasPrefix(d.reference, "${path.module}/") {
+		return "", false
+	}
+
+	return filepath.FromSlash(strings.TrimPrefix(d.reference, "${path.module}/")), true
+}
+
+// writeDataFile writes a data file referenced by generated configuration to
+// path.
+func writeDataFile(path string, data []byte) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Fatalf("failed to write %s: %s", path, err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		log.Fatalf("failed to write %s: %s", path, err)
+	}
+}
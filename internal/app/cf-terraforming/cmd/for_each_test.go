@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const forEachTestConfig = `resource "cloudflare_dns_record" "terraform_managed_resource_b2" {
+  content = "192.0.2.2"
+  name    = "www.example.com"
+  proxied = true
+  ttl     = 1
+  type    = "A"
+  zone_id = var.zone_id
+}
+
+import {
+  to = cloudflare_dns_record.terraform_managed_resource_b2
+  id = "0da42c8d2132a9ddaf714f9e7c920711/b2"
+}
+
+resource "cloudflare_dns_record" "terraform_managed_resource_a1" {
+  comment = "mail"
+  content = "mx.example.com"
+  name    = "example.com"
+  proxied = true
+  ttl     = 1
+  type    = "MX"
+  zone_id = var.zone_id
+}
+
+import {
+  to = cloudflare_dns_record.terraform_managed_resource_a1
+  id = "0da42c8d2132a9ddaf714f9e7c920711/a1"
+}
+`
+
+func TestCollapseForEach_Locals(t *testing.T) {
+	f, data := collapseForEach(parseTestHCL(t, forEachTestConfig), "cloudflare_dns_record", "")
+
+	assert.Nil(t, data)
+	assert.Equal(t, `locals {
+  dns_record = {
+    "example.com_MX" = {
+      comment = "mail"
+      content = "mx.example.com"
+      name    = "example.com"
+      type    = "MX"
+    }
+    "www.example.com_A" = {
+      comment = null
+      content = "192.0.2.2"
+      name    = "www.example.com"
+      type    = "A"
+    }
+  }
+}
+
+resource "cloudflare_dns_record" "terraform_managed_resource" {
+  for_each = local.dns_record
+  comment  = each.value.comment
+  content  = each.value.content
+  name     = each.value.name
+  proxied  = true
+  ttl      = 1
+  type     = each.value.type
+  zone_id  = var.zone_id
+}
+
+import {
+  to = cloudflare_dns_record.terraform_managed_resource["example.com_MX"]
+  id = "0da42c8d2132a9ddaf714f9e7c920711/a1"
+}
+
+import {
+  to = cloudflare_dns_record.terraform_managed_resource["www.example.com_A"]
+  id = "0da42c8d2132a9ddaf714f9e7c920711/b2"
+}
+
+`, string(hclwrite.Format(f.Bytes())))
+}
+
+func TestCollapseForEach_JSONWithImportForEach(t *testing.T) {
+	useImportForEach = true
+	defer func() { useImportForEach = false }()
+
+	f, data := collapseForEach(parseTestHCL(t, forEachTestConfig), "cloudflare_dns_record", "${path.module}/dns_record.json")
+
+	assert.Equal(t, `{
+  "example.com_MX": {
+    "comment": "mail",
+    "content": "mx.example.com",
+    "name": "example.com",
+    "type": "MX"
+  },
+  "www.example.com_A": {
+    "comment": null,
+    "content": "192.0.2.2",
+    "name": "www.example.com",
+    "type": "A"
+  }
+}
+`, string(data))
+	assert.Equal(t, `resource "cloudflare_dns_record" "terraform_managed_resource" {
+  for_each = jsondecode(file("${path.module}/dns_record.json"))
+  comment  = each.value.comment
+  content  = each.value.content
+  name     = each.value.name
+  proxied  = true
+  ttl      = 1
+  type     = each.value.type
+  zone_id  = var.zone_id
+}
+
+import {
+  for_each = {
+    "example.com_MX"    = "0da42c8d2132a9ddaf714f9e7c920711/a1"
+    "www.example.com_A" = "0da42c8d2132a9ddaf714f9e7c920711/b2"
+  }
+  to = cloudflare_dns_record.terraform_managed_resource[each.key]
+  id = each.value
+}
+
+`, string(hclwrite.Format(f.Bytes())))
+}
+
+func TestCollapseForEach_DuplicateKeys(t *testing.T) {
+	f, _ := collapseForEach(parseTestHCL(t, `resource "cloudflare_dns_record" "terraform_managed_resource_a1" {
+  content = "192.0.2.1"
+  name    = "www.example.com"
+  type    = "A"
+}
+
+resource "cloudflare_dns_record" "terraform_managed_resource_b2" {
+  content = "192.0.2.2"
+  name    = "www.example.com"
+  type    = "A"
+}
+`), "cloudflare_dns_record", "")
+
+	out := string(hclwrite.Format(f.Bytes()))
+	assert.Contains(t, out, `"www.example.com_A_a1" = {`)
+	assert.Contains(t, out, `"www.example.com_A_b2" = {`)
+}
+
+func TestCollapseForEach_NonLiteralJSONSkipped(t *testing.T) {
+	src := `resource "cloudflare_workers_secret" "terraform_managed_resource_a1" {
+  name = "a"
+  text = var.workers_secret_a1_text
+}
+
+resource "cloudflare_workers_secret" "terraform_managed_resource_b2" {
+  name = "b"
+  text = var.workers_secret_b2_text
+}
+`
+	hook := test.NewLocal(log)
+	defer hook.Reset()
+
+	f, data := collapseForEach(parseTestHCL(t, src), "cloudflare_workers_secret", "workers_secret.json")
+
+	assert.Nil(t, data)
+	assert.Equal(t, src, string(hclwrite.Format(f.Bytes())))
+	require.NotNil(t, hook.LastEntry())
+	assert.Equal(t, "not collapsing cloudflare_workers_secret with --for-each-data-dir as text of terraform_managed_resource_a1 isn't a literal value", hook.LastEntry().Message)
+}
+
+func TestCollapseForEach_SingleResource(t *testing.T) {
+	src := `resource "cloudflare_dns_record" "terraform_managed_resource_a1" {
+  name = "www.example.com"
+}
+`
+	f, data := collapseForEach(parseTestHCL(t, src), "cloudflare_dns_record", "")
+
+	assert.Nil(t, data)
+	assert.Equal(t, src, string(hclwrite.Format(f.Bytes())))
+}
+
+func TestCollapseForEach_NestedBlocksSkipped(t *testing.T) {
+	src := `resource "cloudflare_load_balancer_pool" "terraform_managed_resource_a1" {
+  name = "a"
+  origins {
+    address = "192.0.2.1"
+  }
+}
+
+resource "cloudflare_load_balancer_pool" "terraform_managed_resource_b2" {
+  name = "b"
+}
+`
+	hook := test.NewLocal(log)
+	defer hook.Reset()
+
+	f, data := collapseForEach(parseTestHCL(t, src), "cloudflare_load_balancer_pool", "")
+
+	assert.Nil(t, data)
+	assert.Equal(t, src, string(hclwrite.Format(f.Bytes())))
+	require.NotNil(t, hook.LastEntry())
+	assert.Equal(t, logrus.WarnLevel, hook.LastEntry().Level)
+	assert.Equal(t, "not collapsing cloudflare_load_balancer_pool with --for-each as cloudflare_load_balancer_pool.terraform_managed_resource_a1 has a nested origins block, which for_each can't vary by instance", hook.LastEntry().Message)
+}
@@ -1,25 +1,23 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"slices"
 	"sort"
 	"strings"
 
 	cfv0 "github.com/cloudflare/cloudflare-go"
-	"github.com/cloudflare/cloudflare-go/v4"
-	"github.com/hashicorp/go-version"
-	"github.com/hashicorp/hc-install/product"
-	"github.com/hashicorp/hc-install/releases"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/hashicorp/hcl/v2/hclwrite"
-	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -41,88 +39,100 @@ var (
 
 func init() {
 	rootCmd.AddCommand(generateCmd)
+
+	generateCmd.Flags().BoolVar(&useForEach, "for-each", false, "Collapse the generated resources of each type into a single resource using for_each over a locals map keyed by a readable name such as the DNS record name and type")
+	generateCmd.Flags().StringVar(&forEachDataDir, "for-each-data-dir", "", "Write the values of resources collapsed by --for-each to a JSON file per resource type in the provided directory instead of a locals block")
+	generateCmd.Flags().IntVar(&collectionThreshold, "collection-threshold", 0, "Write list attributes with more items than this, such as the items of large lists, to a data file referenced with jsondecode or csvdecode instead of inline. 0 disables this")
+	generateCmd.Flags().StringVar(&collectionFormat, "collection-format", collectionFormatJSON, "Format of the data files written by --collection-threshold: json or csv. Collections that can't be represented as CSV are written as JSON")
+	generateCmd.Flags().StringVar(&collectionDir, "collection-dir", ".", "Directory to write the data files of --collection-threshold to")
+	generateCmd.Flags().BoolVar(&excludeManaged, "exclude-managed", false, "Skip resources that are already tracked in the Terraform state of the working directory")
+	generateCmd.Flags().StringVar(&generateFromStatePath, "from-state", "", "Generate configuration from a state file, the JSON output of terraform show or a Terraform working directory instead of the Cloudflare API")
+	generateCmd.Flags().StringVar(&mergeIntoDir, "merge-into", "", "Merge the generated configuration into the .tf files in the provided directory, updating existing resources in place instead of outputting it")
+	generateCmd.Flags().StringVar(&moduleDir, "module", "", "Write the generated configuration as a module in the provided directory, with variables for account and zone IDs and secrets, outputs for resource IDs and a root main.tf calling it")
+	generateCmd.Flags().BoolVar(&explainGenerated, "explain", false, "Report which API fields were mapped to attributes, dropped as computed or had no matching attribute, and which required attributes had no value for each generated resource")
+	generateCmd.Flags().BoolVar(&parameterizeIDs, "parameterize", false, "Reference account and zone IDs through variables, or a local map keyed by zone name when generating for multiple zones, instead of repeating them as literals")
+	generateCmd.Flags().BoolVar(&useSensitiveVariables, "sensitive-variables", false, "Replace sensitive and redacted values with references to variables declared in variables.tf")
+	generateCmd.Flags().StringVar(&variablesDir, "variables-dir", ".", "Directory to write variables.tf and secrets.auto.tfvars to when using --sensitive-variables")
+	generateCmd.Flags().BoolVar(&writeSecrets, "write-secrets", false, "Write the sensitive values to a git-ignored secrets.auto.tfvars alongside variables.tf when using --sensitive-variables")
+	generateCmd.Flags().BoolVar(&verifyGenerated, "verify", false, "Run terraform validate and plan against the generated configuration in a scratch copy of the working directory and report any resources that don't plan cleanly")
+	generateCmd.Flags().StringSliceVar(&rulesetPhases, "ruleset-phase", []string{}, "Only generate rulesets in the provided phases. Example: `http_request_firewall_custom,http_request_cache_settings`")
+	generateCmd.Flags().StringSliceVar(&rulesetKinds, "ruleset-kind", []string{}, "Only generate rulesets of the provided kinds: root, zone or custom")
 }
 
 func generateResources() func(cmd *cobra.Command, args []string) {
 	return func(cmd *cobra.Command, args []string) {
-		if resourceType == "" {
+		if resourceType == "" && generateFromStatePath == "" {
 			log.Fatal("you must define a resource type to generate")
 		}
 
 		zoneID = viper.GetString("zone")
 		accountID = viper.GetString("account")
-		workingDir := viper.GetString("terraform-install-path")
-		execPath := viper.GetString("terraform-binary-path")
-
-		// Download terraform if no existing binary was provided
-		if execPath == "" {
-			tmpDir, err := os.MkdirTemp("", "tfinstall")
-			if err != nil {
-				log.Fatal(err)
-			}
-			defer os.RemoveAll(tmpDir)
+		tf, registryPath, cleanup := initTerraform()
+		defer cleanup()
 
-			installConstraints, err := version.NewConstraint("~> 1.0")
-			if err != nil {
-				log.Fatal("failed to parse version constraints for installation version")
-			}
+		log.Debug("reading Terraform schema")
+		ps, err := tf.ProvidersSchema(context.Background())
+		if err != nil {
+			log.Fatal("failed to read provider schema", err)
+		}
 
-			installer := &releases.LatestVersion{
-				Product:     product.Terraform,
-				Constraints: installConstraints,
-			}
+		s := ps.Schemas[registryPath]
+		if s == nil {
+			log.Fatal("failed to detect provider installation")
+		}
 
-			execPath, err = installer.Install(context.Background())
-			if err != nil {
-				log.Fatalf("error installing Terraform: %s", err)
+		// modules reference account and zone IDs and secrets through their
+		// variables, which are written alongside the module.
+		if moduleDir != "" {
+			if mergeIntoDir != "" {
+				log.Fatal("--module can't be used with --merge-into")
 			}
+			parameterizeIDs = true
+			useSensitiveVariables = true
 		}
-
-		// Setup and configure Terraform to operate in the temporary directory where
-		// the provider is already configured.
-		log.WithFields(logrus.Fields{
-			"directory": workingDir,
-		}).Debug("initializing Terraform")
-		tf, err := tfexec.NewTerraform(workingDir, execPath)
-		if err != nil {
-			log.Fatal(err)
+		toStdout := mergeIntoDir == "" && moduleDir == ""
+		if collectionFormat != collectionFormatJSON && collectionFormat != collectionFormatCSV {
+			log.Fatalf("unsupported --collection-format %q, must be json or csv", collectionFormat)
 		}
-
-		_, providerVersion, err := tf.Version(context.Background(), true)
-		if err != nil {
-			log.Fatalf("failed to retrieve terraform and provider version information: %s", err)
+		if useForEach && (generateFromStatePath != "" || verifyGenerated) {
+			log.Fatal("--for-each can't be used with --from-state or --verify as the resources are renamed")
+		}
+		if verifyGenerated && (generateFromStatePath != "" || moduleDir != "") {
+			log.Fatal("--verify can't be used with --from-state or --module")
 		}
 
-		var registryPath string
-		for provider := range providerVersion {
-			if strings.Contains(provider, "/cloudflare/cloudflare") {
-				registryPath = provider
-				continue
+		if generateFromStatePath != "" {
+			state, err := readStateFrom(tf, generateFromStatePath)
+			if err != nil {
+				log.Fatalf("failed to read Terraform state from %s: %s", generateFromStatePath, err)
 			}
-		}
 
-		detectedVersion, ok := providerVersion[registryPath]
-		if !ok {
-			log.WithFields(logrus.Fields{
-				"available_registries": providerVersion,
-			}).Fatal("failed to find registry")
-		}
+			var types []string
+			if resourceType != "" {
+				types = strings.Split(resourceType, ",")
+			}
+			var params *idParameters
+			if parameterizeIDs {
+				params = newIDParameters(accountID, zoneID, stateZoneNames(stateResources(state)))
+				params.asModule = moduleDir != ""
+			}
 
-		providerVersionString := detectedVersion.String()
-		log.WithFields(logrus.Fields{
-			"version":  providerVersionString,
-			"registry": registryPath,
-		}).Debug("detected provider")
+			var generated bytes.Buffer
+			variables := generateFromState(&generated, s, stateResources(state), types, params)
+			if moduleDir != "" {
+				writeGeneratedModule(cmd, generated.Bytes(), variables, params, registryPath)
+				return
+			}
 
-		log.Debug("reading Terraform schema")
-		ps, err := tf.ProvidersSchema(context.Background())
-		if err != nil {
-			log.Fatal("failed to read provider schema", err)
-		}
+			writeGeneratedVariables(cmd.OutOrStderr(), variables)
+			writeIDDefinitions(&generated, params)
+			if toStdout {
+				fmt.Fprint(cmd.OutOrStdout(), generated.String())
+				return
+			}
 
-		s := ps.Schemas[registryPath]
-		if s == nil {
-			log.Fatal("failed to detect provider installation")
+			mergeInto(cmd, generated.Bytes())
+			return
 		}
 
 		resources := strings.Split(resourceType, ",")
@@ -131,6 +141,32 @@ func generateResources() func(cmd *cobra.Command, args []string) {
 		if slices.Contains(resources, "cloudflare_zone_setting") || slices.Contains(resources, "cloudflare_hostname_tls_setting") {
 			resourceIDsMap = getResourceMappings()
 		}
+		// resources already tracked in state are skipped when adopting
+		// resources in stages.
+		var managed map[string]*tfjson.StateResource
+		if excludeManaged {
+			state, err := tf.Show(context.Background())
+			if err != nil {
+				log.Fatalf("failed to read Terraform state: %s", err)
+			}
+			managed = stateResources(state)
+		}
+
+		// the generated output, import entries and data files are retained
+		// for `--verify` once all resources have been generated.
+		var generated strings.Builder
+		var generatedEntries []importEntry
+		var dataFiles []generatedDataFile
+		// required attributes the API didn't return are listed once all
+		// resources have been generated.
+		var placeholders []generatedVariable
+
+		var params *idParameters
+		if parameterizeIDs {
+			params = newIDParameters(accountID, zoneID, lookupZoneName)
+			params.asModule = moduleDir != ""
+		}
+
 		for _, resourceType := range resources {
 			r := s.ResourceSchemas[resourceType]
 			log.WithFields(logrus.Fields{
@@ -143,12 +179,7 @@ func generateResources() func(cmd *cobra.Command, args []string) {
 			resourceCount := 0
 			var jsonStructData []interface{}
 
-			// The ruleset API has many gotchas that are accounted for in how we build
-			// the 'response' object that feeds into the HCL generation, and it's difficult
-			// to ensure the same compatability using the generated SDK.
-			useOldSDK := resourceType == "cloudflare_ruleset"
-
-			if strings.HasPrefix(providerVersionString, "5") && !useOldSDK {
+			if strings.HasPrefix(providerVersionString, "5") {
 				if resourceToEndpoint[resourceType]["list"] == "" && resourceToEndpoint[resourceType]["get"] == "" {
 					log.WithFields(logrus.Fields{
 						"resource": resourceType,
@@ -156,50 +187,13 @@ func generateResources() func(cmd *cobra.Command, args []string) {
 					continue
 				}
 
-				var result *http.Response
-
-				// by default, we want to use the `list` operation however, there are times
-				// when resources exist only as `get` operations but contain multiple
-				// resources.
-				endpoint := resourceToEndpoint[resourceType]["list"]
-				if endpoint == "" {
-					endpoint = resourceToEndpoint[resourceType]["get"]
-				}
-
-				// if we encounter a combined endpoint, we need to rewrite to use the correct
-				// endpoint depending on what parameters are being provided.
-				if strings.Contains(endpoint, "{accounts_or_zones}") {
-					if accountID != "" {
-						endpoint = strings.Replace(endpoint, "/{accounts_or_zones}/{account_or_zone_id}/", "/accounts/{account_id}/", 1)
-					} else {
-						endpoint = strings.Replace(endpoint, "/{accounts_or_zones}/{account_or_zone_id}/", "/zones/{zone_id}/", 1)
-					}
-				}
-
-				// replace the URL placeholders with the actual values we have.
-				placeholderReplacer := strings.NewReplacer("{account_id}", accountID, "{zone_id}", zoneID)
-				endpoint = placeholderReplacer.Replace(endpoint)
-
-				pathParams, ok := resourceIDsMap[resourceType]
-				if ok && len(pathParams) > 0 {
-					endpoints := make([]string, 0)
-					for _, id := range pathParams {
-						endpoints = append(endpoints, strings.Clone(strings.NewReplacer("{setting_id}", id).Replace(endpoint)))
-					}
-					jsonStructData, err = GetAPIResponse(result, pathParams, endpoints...)
-					if err != nil {
-						log.Infof("error getting API response for resource %s: %s", resourceType, err)
-						continue
-					}
-					resourceCount = len(jsonStructData)
-				} else {
-					jsonStructData, err = GetAPIResponse(result, pathParams, endpoint)
-					if err != nil {
-						log.Infof("error getting API response for resource %s: %s", resourceType, err)
-						continue
-					}
-					resourceCount = len(jsonStructData)
+				jsonStructData, err = fetchResourcesV5(resourceType, resourceIDsMap[resourceType])
+				if err != nil {
+					log.Infof("error getting API response for resource %s: %s", resourceType, err)
+					continue
 				}
+				jsonStructData = expandResourcesV5(resourceType, jsonStructData)
+				resourceCount = len(jsonStructData)
 			} else {
 				var identifier *cfv0.ResourceContainer
 				if accountID != "" {
@@ -912,10 +906,10 @@ func generateResources() func(cmd *cobra.Command, args []string) {
 					var nonManagedRules []cfv0.Ruleset
 
 					// A little annoying but makes more sense doing it this way. Only append
-					// the non-managed rules to the usable nonManagedRules variable instead
+					// the selected non-managed rules to the usable nonManagedRules variable instead
 					// of attempting to delete from an existing slice and just reassign.
 					for _, r := range jsonPayload {
-						if r.Kind != string(cfv0.RulesetKindManaged) {
+						if rulesetSelected(r.Kind, r.Phase) {
 							nonManagedRules = append(nonManagedRules, r)
 						}
 					}
@@ -963,18 +957,6 @@ func generateResources() func(cmd *cobra.Command, args []string) {
 						log.Fatal(err)
 					}
 
-					if strings.HasPrefix(providerVersionString, "5") {
-						for i := 0; i < resourceCount; i++ {
-							rules := jsonStructData[i].(map[string]interface{})["rules"]
-							if rules != nil {
-								for ruleCounter := range rules.([]interface{}) {
-									rules.([]interface{})[ruleCounter].(map[string]interface{})["id"] = nil
-								}
-							}
-						}
-						continue
-					}
-
 					// Make the rules have the correct header structure
 					for i, ruleset := range jsonStructData {
 						if ruleset.(map[string]interface{})["rules"] != nil {
@@ -1254,29 +1236,7 @@ func generateResources() func(cmd *cobra.Command, args []string) {
 						}
 					}
 				case "cloudflare_waiting_room_event":
-					waitingRooms, err := apiV0.ListWaitingRooms(context.Background(), zoneID)
-					if err != nil {
-						log.Fatal(err)
-					}
-					for i := 0; i < len(waitingRooms); i++ {
-						roomEvents, err := apiV0.ListWaitingRoomEvents(context.Background(), zoneID, waitingRooms[i].ID)
-						if err != nil {
-							log.Fatal(err)
-						}
-						m, err := json.Marshal(roomEvents)
-						if err != nil {
-							log.Fatal(err)
-						}
-						jsonRoomEvents := []interface{}{}
-						err = json.Unmarshal(m, &jsonRoomEvents)
-						if err != nil {
-							log.Fatal(err)
-						}
-						for i := 0; i < len(jsonRoomEvents); i++ {
-							jsonRoomEvents[i].(map[string]interface{})["waiting_room_id"] = waitingRooms[i].ID
-						}
-						jsonStructData = append(jsonStructData, jsonRoomEvents...)
-					}
+					jsonStructData = waitingRoomEvents(zoneID)
 					resourceCount = len(jsonStructData)
 				case "cloudflare_waiting_room_rules":
 					waitingRooms, err := apiV0.ListWaitingRooms(context.Background(), zoneID)
@@ -1458,8 +1418,17 @@ func generateResources() func(cmd *cobra.Command, args []string) {
 					jsonStructData[0].(map[string]interface{})["id"] = zoneID
 					jsonStructData[0].(map[string]interface{})["cache_type"] = tieredCache.Type.String()
 				default:
-					fmt.Fprintf(cmd.OutOrStderr(), "%q is not yet supported for automatic generation", resourceType)
-					return
+					fmt.Fprintf(cmd.OutOrStderr(), "%q is not yet supported for automatic generation\n", resourceType)
+					continue
+				}
+			}
+
+			if excludeManaged {
+				jsonStructData = excludeManagedResources(resourceType, jsonStructData, managed)
+				resourceCount = len(jsonStructData)
+				if resourceCount == 0 {
+					fmt.Fprintf(cmd.OutOrStderr(), "all resources of type %q are already managed\n", resourceType)
+					continue
 				}
 			}
 
@@ -1468,14 +1437,17 @@ func generateResources() func(cmd *cobra.Command, args []string) {
 				"resource": resourceType,
 			}).Debug("generating resource output")
 
-			// If we don't have any resources to generate, just bail out early.
+			// If we don't have any resources of this type to generate, move on to
+			// the next one so the others are still merged, verified or written
+			// as a module.
 			if resourceCount == 0 {
-				fmt.Fprintf(cmd.OutOrStderr(), "no resources of type %q found to generate", resourceType)
-				return
+				fmt.Fprintf(cmd.OutOrStderr(), "no resources of type %q found to generate\n", resourceType)
+				continue
 			}
 
 			f := hclwrite.NewEmptyFile()
 			rootBody := f.Body()
+			var typePlaceholders []generatedVariable
 			for i := 0; i < resourceCount; i++ {
 				structData := jsonStructData[i].(map[string]interface{})
 
@@ -1487,97 +1459,181 @@ func generateResources() func(cmd *cobra.Command, args []string) {
 						resourceID = fmt.Sprintf("terraform_managed_resource_%d", i)
 					}
 				} else {
-					id := ""
-					switch structData["id"].(type) {
-					case float64:
-						id = fmt.Sprintf("%f", structData["id"].(float64))
-					default:
-						if structData["id"] == nil {
-							if accountID != "" {
-								id = accountID
-							}
-
-							if zoneID != "" {
-								id = zoneID
-							}
-						} else {
-							id = structData["id"].(string)
-						}
+					resourceID = terraformResourceName(resourceIDFromData(structData))
+				}
+				// Build the import entry ahead of the attributes being written as
+				// that consumes `structData`.
+				var entry importEntry
+				if useModernImportBlock || verifyGenerated {
+					id := resourceIDFromData(structData)
+					attributes := make(map[string]interface{}, len(structData))
+					for k, v := range structData {
+						attributes[k] = v
+					}
+					entry = importEntry{
+						Address:      resourceType + "." + resourceID,
+						ID:           buildRawImportAddress(resourceType, id, resourceToEndpoint[resourceType]["get"], attributes),
+						resourceType: resourceType,
+						resourceID:   id,
+						attributes:   attributes,
 					}
-
-					resourceID = fmt.Sprintf("terraform_managed_resource_%s", id)
 				}
+
 				resource := rootBody.AppendNewBlock("resource", []string{resourceType, resourceID}).Body()
 
 				if r == nil {
 					log.Fatalf("failed to find %q in the initialized provider schema", resourceType)
 				}
 
-				sortedBlockAttributes := make([]string, 0, len(r.Block.Attributes))
-				for k := range r.Block.Attributes {
-					sortedBlockAttributes = append(sortedBlockAttributes, k)
+				if explainGenerated {
+					writeExplanation(cmd.OutOrStderr(), explainResource(resourceType+"."+resourceID, r.Block, structData))
 				}
-				sort.Strings(sortedBlockAttributes)
-
-				// Block attributes are for any attributes where assignment is involved.
-				for _, attrName := range sortedBlockAttributes {
-					// Don't bother outputting the ID for the resource as that is only for
-					// internal use (such as importing state).
-					if attrName == "id" {
-						continue
-					}
-
-					// No need to output computed attributes that are also not
-					// optional.
-					if r.Block.Attributes[attrName].Computed && !r.Block.Attributes[attrName].Optional {
-						continue
-					}
-					if attrName == "account_id" && accountID != "" {
-						writeAttrLine(attrName, accountID, "", resource)
-						continue
-					}
 
-					if attrName == "zone_id" && zoneID != "" && accountID == "" {
-						writeAttrLine(attrName, zoneID, "", resource)
-						continue
-					}
+				typePlaceholders = append(typePlaceholders, renderResource(r, resourceType, resourceID, structData, resource)...)
+				f.Body().AppendNewline()
 
-					ty := r.Block.Attributes[attrName].AttributeType
-					switch {
-					case ty.IsPrimitiveType():
-						switch ty {
-						case cty.String, cty.Bool, cty.Number:
-							writeAttrLine(attrName, structData[attrName], "", resource)
-							delete(structData, attrName)
-						default:
-							log.Debugf("unexpected primitive type %q", ty.FriendlyName())
-						}
-					case ty.IsCollectionType():
-						switch {
-						case ty.IsListType(), ty.IsSetType(), ty.IsMapType():
-							writeAttrLine(attrName, structData[attrName], "", resource)
-							delete(structData, attrName)
-						default:
-							log.Debugf("unexpected collection type %q", ty.FriendlyName())
-						}
-					case ty.IsTupleType():
-						fmt.Printf("tuple found. attrName %s\n", attrName)
-					case ty.IsObjectType():
-						fmt.Printf("object found. attrName %s\n", attrName)
-					default:
-						log.Debugf("attribute %q has not been generated", attrName)
-					}
+				// Keep the import block alongside the resource it imports.
+				if useModernImportBlock {
+					appendImportBlock(rootBody, entry, identitySchemaFor(s, resourceType))
+					f.Body().AppendNewline()
 				}
+				if verifyGenerated {
+					generatedEntries = append(generatedEntries, entry)
+				}
+			}
 
-				processBlocks(r.Block, jsonStructData[i].(map[string]interface{}), resource, "")
-				f.Body().AppendNewline()
+			// variables are declared in variables.tf instead when using
+			// `--sensitive-variables`.
+			if !useSensitiveVariables {
+				for _, v := range typePlaceholders {
+					appendVariableBlock(rootBody, v)
+					rootBody.AppendNewline()
+				}
 			}
+			placeholders = append(placeholders, typePlaceholders...)
 
 			postProcess(f, resourceType)
+			var files []generatedDataFile
+			f, files = finishGeneratedFile(f, resourceType, params)
+			dataFiles = append(dataFiles, files...)
 			tfOutput := string(hclwrite.Format(f.Bytes()))
-			fmt.Fprint(cmd.OutOrStdout(), tfOutput)
+			if toStdout {
+				fmt.Fprint(cmd.OutOrStdout(), tfOutput)
+			}
+			generated.WriteString(tfOutput)
+		}
+
+		if generated.Len() == 0 {
+			return
+		}
+
+		if moduleDir != "" {
+			writeGeneratedModule(cmd, []byte(generated.String()), placeholders, params, registryPath)
+			return
+		}
+
+		var definitions strings.Builder
+		writeIDDefinitions(&definitions, params)
+		if toStdout {
+			fmt.Fprint(cmd.OutOrStdout(), definitions.String())
+		}
+		generated.WriteString(definitions.String())
+
+		writeGeneratedVariables(cmd.OutOrStderr(), placeholders)
+
+		if mergeIntoDir != "" && generated.Len() > 0 {
+			mergeInto(cmd, []byte(generated.String()))
+		}
+
+		if verifyGenerated && len(generatedEntries) > 0 {
+			verifyGeneratedConfig(cmd, tf.WorkingDir(), tf.ExecPath(), generated.String(), generatedEntries, placeholders, dataFiles)
+		}
+	}
+}
+
+// finishGeneratedFile parameterizes IDs, collapses resources with
+// `--for-each` and externalizes large collections, writing any data files. The
+// resources are collapsed first as the values they're collapsed into must
+// still be literals.
+func finishGeneratedFile(f *hclwrite.File, resourceType string, params *idParameters) (*hclwrite.File, []generatedDataFile) {
+	if params != nil {
+		params.parameterizeFile(f)
+	}
+
+	var dataFiles []generatedDataFile
+	if useForEach {
+		reference, path := forEachDataFile(resourceType)
+		var data []byte
+		if f, data = collapseForEach(f, resourceType, reference); data != nil {
+			writeDataFile(path, data)
+			dataFiles = append(dataFiles, generatedDataFile{reference: reference, data: data})
+		}
+	}
+
+	return f, append(dataFiles, externalizeGeneratedCollections(f)...)
+}
+
+// writeResourceBody writes the attributes and blocks of a single resource
+// from the API result using the provider schema. Computed only attributes are
+// omitted as they can't be set in configuration.
+func writeResourceBody(r *tfjson.Schema, structData map[string]interface{}, resource *hclwrite.Body) {
+	sortedBlockAttributes := make([]string, 0, len(r.Block.Attributes))
+	for k := range r.Block.Attributes {
+		sortedBlockAttributes = append(sortedBlockAttributes, k)
+	}
+	sort.Strings(sortedBlockAttributes)
+
+	// Block attributes are for any attributes where assignment is involved.
+	for _, attrName := range sortedBlockAttributes {
+		// Don't bother outputting the ID for the resource as that is only for
+		// internal use (such as importing state).
+		if attrName == "id" {
+			continue
+		}
+
+		// No need to output computed attributes that are also not
+		// optional.
+		if r.Block.Attributes[attrName].Computed && !r.Block.Attributes[attrName].Optional {
+			continue
+		}
+		if attrName == "account_id" && accountID != "" {
+			writeAttrLine(attrName, accountID, "", resource)
+			continue
+		}
+
+		if attrName == "zone_id" && zoneID != "" && accountID == "" {
+			writeAttrLine(attrName, zoneID, "", resource)
+			continue
+		}
+
+		ty := r.Block.Attributes[attrName].AttributeType
+		switch {
+		case ty.IsPrimitiveType():
+			switch ty {
+			case cty.String, cty.Bool, cty.Number:
+				writeAttrLine(attrName, structData[attrName], "", resource)
+				delete(structData, attrName)
+			default:
+				log.Debugf("unexpected primitive type %q", ty.FriendlyName())
+			}
+		case ty.IsCollectionType():
+			switch {
+			case ty.IsListType(), ty.IsSetType(), ty.IsMapType():
+				writeAttrLine(attrName, structData[attrName], "", resource)
+				delete(structData, attrName)
+			default:
+				log.Debugf("unexpected collection type %q", ty.FriendlyName())
+			}
+		case ty.IsTupleType():
+			fmt.Printf("tuple found. attrName %s\n", attrName)
+		case ty.IsObjectType():
+			fmt.Printf("object found. attrName %s\n", attrName)
+		default:
+			log.Debugf("attribute %q has not been generated", attrName)
 		}
 	}
+
+	processBlocks(r.Block, structData, resource, "")
 }
 
 func processCustomCasesV5(response *[]interface{}, resourceType string, pathParam string) {
@@ -1593,6 +1649,10 @@ func processCustomCasesV5(response *[]interface{}, resourceType string, pathPara
 				delete((*response)[i].(map[string]interface{})["managed_response_headers"].([]interface{})[j].(map[string]interface{}), "has_conflict")
 			}
 		}
+	case "cloudflare_ruleset":
+		// the list endpoint doesn't include the rules, which
+		// expandResourcesV5 fetches where they are needed.
+		*response = selectRulesets(*response)
 	case "cloudflare_r2_bucket":
 		finalResponse := make([]interface{}, 0)
 		r := *response
@@ -1718,8 +1778,13 @@ func processCustomCasesV5(response *[]interface{}, resourceType string, pathPara
 			(*response)[i].(map[string]interface{})["setting_id"] = (*response)[i].(map[string]interface{})["id"]
 		}
 	case "cloudflare_hostname_tls_setting":
+		// settings are returned for every hostname so combine the setting and
+		// hostname to uniquely identify each one.
 		for i := 0; i < resourceCount; i++ {
 			(*response)[i].(map[string]interface{})["setting_id"] = pathParam
+			if h, ok := (*response)[i].(map[string]interface{})["hostname"].(string); ok {
+				(*response)[i].(map[string]interface{})["id"] = pathParam + "/" + h
+			}
 		}
 	}
 }
@@ -1736,6 +1801,38 @@ func unMarshallJSONStructData(modifiedJSONString string) ([]interface{}, error)
 	return []interface{}{data}, nil
 }
 
+// waitingRoomEvents fetches the events of every waiting room in zoneID and
+// sets the waiting_room_id of each event to the room it belongs to.
+func waitingRoomEvents(zoneID string) []interface{} {
+	waitingRooms, err := apiV0.ListWaitingRooms(context.Background(), zoneID)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	events := []interface{}{}
+	for _, waitingRoom := range waitingRooms {
+		roomEvents, err := apiV0.ListWaitingRoomEvents(context.Background(), zoneID, waitingRoom.ID)
+		if err != nil {
+			log.Fatal(err)
+		}
+		m, err := json.Marshal(roomEvents)
+		if err != nil {
+			log.Fatal(err)
+		}
+		jsonRoomEvents := []interface{}{}
+		err = json.Unmarshal(m, &jsonRoomEvents)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, event := range jsonRoomEvents {
+			event.(map[string]interface{})["waiting_room_id"] = waitingRoom.ID
+		}
+		events = append(events, jsonRoomEvents...)
+	}
+
+	return events
+}
+
 // postProcess allows you to perform additional actions on the generated hcl.
 func postProcess(f *hclwrite.File, resourceType string) {
 	switch resourceType {
@@ -1785,49 +1882,128 @@ func addJSONEncode(f *hclwrite.File, attributeName string) {
 	}
 }
 
-func GetAPIResponse(result *http.Response, pathParams []string, endpoints ...string) ([]interface{}, error) {
-	var jsonStructData, results []interface{}
-	for i, endpoint := range endpoints {
-		err := api.Get(context.Background(), endpoint, nil, &result)
-		if err != nil {
-			var apierr *cloudflare.Error
-			if errors.As(err, &apierr) {
-				if apierr.StatusCode == http.StatusNotFound {
-					log.WithFields(logrus.Fields{
-						"resource": resourceType,
-						"endpoint": endpoint,
-					}).Debug("no resources found")
-					return nil, err
-				}
-			}
-			log.Fatalf("failed to fetch API endpoint: %s", err)
-		}
+// fetchResourcesV5 retrieves every resource of resourceType using the endpoint
+// mapping. Parameterised singletons (such as `cloudflare_zone_setting`) are
+// fetched once for each of the provided path parameters.
+func fetchResourcesV5(resourceType string, pathParams []string) ([]interface{}, error) {
+	endpoints, err := resourceEndpointsV5(resourceType, pathParams)
+	if err != nil {
+		return nil, err
+	}
 
-		body, err := io.ReadAll(result.Body)
-		if err != nil {
-			log.Fatalln(err)
+	return GetAPIResponse(resourceType, pathParams, endpoints...)
+}
+
+// resourceEndpointsV5 returns the endpoints fetchResourcesV5 requests, one for
+// each of the path parameters when they are provided. It returns
+// errUnresolvedPlaceholders if an endpoint needs an identifier, such as that
+// of a parent resource, which isn't known.
+func resourceEndpointsV5(resourceType string, pathParams []string) ([]string, error) {
+	// rewrite combined endpoints to the scope being targeted and replace
+	// the URL placeholders with the actual values we have.
+	endpoint := resolveEndpointScope(resourceEndpoint(resourceType))
+
+	endpoints := []string{endpoint}
+	if len(pathParams) > 0 {
+		endpoints = make([]string, 0, len(pathParams))
+		for _, id := range pathParams {
+			endpoints = append(endpoints, strings.NewReplacer("{setting_id}", url.PathEscape(id)).Replace(endpoint))
 		}
-		value := gjson.Get(string(body), "result")
-		if value.Type == gjson.Null {
+	}
+
+	for _, endpoint := range endpoints {
+		if strings.Contains(endpoint, "{") {
 			log.WithFields(logrus.Fields{
 				"resource": resourceType,
 				"endpoint": endpoint,
-			}).Debug("no result found")
-			return nil, errors.New("no result found")
+			}).Debug("failed to substitute all path placeholders due to unknown parameters")
+			return nil, fmt.Errorf("%w in %s", errUnresolvedPlaceholders, endpoint)
 		}
+	}
 
-		modifiedJSON := modifyResponsePayload(resourceType, value)
-		jsonStructData, err = unMarshallJSONStructData(modifiedJSON)
-		if err != nil {
-			log.Fatalf("failed to unmarshal result: %s", err)
-		}
+	return endpoints, nil
+}
 
+func GetAPIResponse(resourceType string, pathParams []string, endpoints ...string) ([]interface{}, error) {
+	var results []interface{}
+	for i, endpoint := range endpoints {
 		param := ""
 		if len(pathParams) > 0 {
 			param = pathParams[i]
 		}
-		processCustomCasesV5(&jsonStructData, resourceType, param)
+
+		jsonStructData, err := fetchEndpoint(resourceType, endpoint, param)
+		if err != nil {
+			if isNotFound(err) {
+				log.WithFields(logrus.Fields{
+					"resource": resourceType,
+					"endpoint": endpoint,
+				}).Debug("no resources found")
+				return nil, err
+			}
+			if errors.Is(err, errNoResult) {
+				log.WithFields(logrus.Fields{
+					"resource": resourceType,
+					"endpoint": endpoint,
+				}).Debug("no result found")
+				return nil, err
+			}
+			log.Fatalf("failed to fetch API endpoint: %s", err)
+		}
+
 		results = append(results, jsonStructData...)
 	}
 	return results, nil
 }
+
+// expandResourcesV5 fetches what the list endpoint of resourceType leaves
+// out, such as the rules of each ruleset, and prepares it for generation. It
+// makes a request per resource so it's only used where the full resource is
+// needed rather than just its ID.
+func expandResourcesV5(resourceType string, resources []interface{}) []interface{} {
+	switch resourceType {
+	case "cloudflare_ruleset":
+		resources = expandRulesets(resources)
+		processRulesetsV5(resources)
+	}
+
+	return resources
+}
+
+var (
+	// errNoResult is returned when an API response doesn't include a `result`.
+	errNoResult = errors.New("no result found")
+
+	// errUnresolvedPlaceholders is returned when an endpoint still has path
+	// placeholders, such as a parent ID, once the scope has been filled in.
+	errUnresolvedPlaceholders = errors.New("unable to substitute all path placeholders")
+)
+
+// fetchEndpoint retrieves a single endpoint and prepares the result for
+// generation. Unlike GetAPIResponse, all errors are returned to the caller.
+func fetchEndpoint(resourceType, endpoint, pathParam string) ([]interface{}, error) {
+	var result *http.Response
+	if err := api.Get(context.Background(), endpoint, nil, &result); err != nil {
+		return nil, err
+	}
+	defer result.Body.Close()
+
+	body, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	value := gjson.Get(string(body), "result")
+	if value.Type == gjson.Null {
+		return nil, errNoResult
+	}
+
+	modifiedJSON := modifyResponsePayload(resourceType, value)
+	jsonStructData, err := unMarshallJSONStructData(modifiedJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	processCustomCasesV5(&jsonStructData, resourceType, pathParam)
+	return jsonStructData, nil
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// generateExplanation describes how the fields of a single API result were
+// used when generating a resource.
+type generateExplanation struct {
+	Address string
+	// Mapped are the fields written to an attribute or block of the same name.
+	Mapped []string
+	// Computed are the fields dropped as the attribute can't be configured.
+	Computed []string
+	// Unmapped are the fields without a matching attribute or block.
+	Unmapped []string
+	// Missing are the required attributes the API didn't return a value for.
+	Missing []string
+}
+
+// explainResource classifies every field of an API result against the
+// resource schema the same way writeResourceBody and processBlocks use them.
+// The top level `id` is left out as it's only used for importing.
+func explainResource(address string, block *tfjson.SchemaBlock, data map[string]interface{}) generateExplanation {
+	sets := map[string]map[string]bool{
+		"mapped":   {},
+		"computed": {},
+		"unmapped": {},
+		"missing":  {},
+	}
+	explainBlock(sets, block, data, "")
+
+	return generateExplanation{
+		Address:  address,
+		Mapped:   sortedSetKeys(sets["mapped"]),
+		Computed: sortedSetKeys(sets["computed"]),
+		Unmapped: sortedSetKeys(sets["unmapped"]),
+		Missing:  sortedSetKeys(sets["missing"]),
+	}
+}
+
+func explainBlock(sets map[string]map[string]bool, block *tfjson.SchemaBlock, data map[string]interface{}, prefix string) {
+	explainAttributes(sets, block.Attributes, block.NestedBlocks, data, prefix)
+}
+
+// explainAttributes classifies the fields of data against the attributes and
+// nested blocks of a block or nested attribute, recursing into both.
+func explainAttributes(sets map[string]map[string]bool, attributes map[string]*tfjson.SchemaAttribute, nestedBlocks map[string]*tfjson.SchemaBlockType, data map[string]interface{}, prefix string) {
+	for key, value := range data {
+		if prefix == "" && key == "id" {
+			continue
+		}
+		path := prefix + key
+
+		if nested, ok := nestedBlocks[key]; ok {
+			sets["mapped"][path] = true
+			for _, item := range nestedBlockItems(value) {
+				explainBlock(sets, nested.Block, item, path+".")
+			}
+			continue
+		}
+
+		attr, ok := attributes[key]
+		switch {
+		case !ok:
+			sets["unmapped"][path] = true
+		case attr.Computed && !attr.Optional:
+			sets["computed"][path] = true
+		case value != nil:
+			sets["mapped"][path] = true
+			if attr.AttributeNestedType != nil {
+				explainNestedAttribute(sets, attr.AttributeNestedType, value, path)
+			}
+		}
+	}
+
+	for name, attr := range attributes {
+		if !attr.Required || data[name] != nil {
+			continue
+		}
+		// Account and zone IDs are filled in from the flags.
+		if prefix == "" && ((name == "account_id" && accountID != "") || (name == "zone_id" && zoneID != "")) {
+			continue
+		}
+		sets["missing"][prefix+name] = true
+	}
+}
+
+// explainNestedAttribute classifies each object of a nested attribute. The
+// objects of map attributes are prefixed with their key.
+func explainNestedAttribute(sets map[string]map[string]bool, nested *tfjson.SchemaNestedAttributeType, value interface{}, path string) {
+	if nested.NestingMode == tfjson.SchemaNestingModeMap {
+		items, _ := value.(map[string]interface{})
+		for key, item := range items {
+			if m, ok := item.(map[string]interface{}); ok {
+				explainAttributes(sets, nested.Attributes, nil, m, path+"."+key+".")
+			}
+		}
+		return
+	}
+
+	for _, item := range nestedBlockItems(value) {
+		explainAttributes(sets, nested.Attributes, nil, item, path+".")
+	}
+}
+
+// nestedBlockItems returns each object within a nested block value.
+func nestedBlockItems(value interface{}) []map[string]interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return []map[string]interface{}{v}
+	case []map[string]interface{}:
+		return v
+	case []interface{}:
+		items := make([]map[string]interface{}, 0, len(v))
+		for _, item := range v {
+			if m, ok := item.(map[string]interface{}); ok {
+				items = append(items, m)
+			}
+		}
+		return items
+	default:
+		return nil
+	}
+}
+
+func sortedSetKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// writeExplanation outputs the explanation for a single resource, omitting
+// any empty categories.
+func writeExplanation(w io.Writer, e generateExplanation) {
+	fmt.Fprintln(w, e.Address)
+	for _, c := range []struct {
+		label  string
+		fields []string
+	}{
+		{"mapped", e.Mapped},
+		{"computed", e.Computed},
+		{"unmapped", e.Unmapped},
+		{"missing", e.Missing},
+	} {
+		if len(c.fields) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "  %-9s %s\n", c.label+":", strings.Join(c.fields, ", "))
+	}
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestExplainResource(t *testing.T) {
+	zoneID, accountID = "0da42c8d2132a9ddaf714f9e7c920711", ""
+	defer func() { zoneID = "" }()
+
+	block := &tfjson.SchemaBlock{
+		Attributes: map[string]*tfjson.SchemaAttribute{
+			"id":          {AttributeType: cty.String, Computed: true},
+			"zone_id":     {AttributeType: cty.String, Required: true},
+			"name":        {AttributeType: cty.String, Required: true},
+			"description": {AttributeType: cty.String, Optional: true},
+			"secret":      {AttributeType: cty.String, Required: true},
+			"created_on":  {AttributeType: cty.String, Computed: true},
+			"tags":        {AttributeType: cty.List(cty.String), Optional: true, Computed: true},
+		},
+		NestedBlocks: map[string]*tfjson.SchemaBlockType{
+			"rules": {
+				NestingMode: tfjson.SchemaNestingModeList,
+				Block: &tfjson.SchemaBlock{
+					Attributes: map[string]*tfjson.SchemaAttribute{
+						"action":  {AttributeType: cty.String, Required: true},
+						"version": {AttributeType: cty.String, Computed: true},
+					},
+				},
+			},
+		},
+	}
+
+	data := map[string]interface{}{
+		"id":          "abc",
+		"name":        "example",
+		"description": nil,
+		"created_on":  "2024-01-01T00:00:00Z",
+		"tags":        []interface{}{"a"},
+		"meta":        map[string]interface{}{"auto_added": false},
+		"rules": []interface{}{
+			map[string]interface{}{"action": "block", "version": "1", "ref": "x"},
+			map[string]interface{}{"version": "2"},
+		},
+	}
+
+	e := explainResource("cloudflare_example.a", block, data)
+	assert.Equal(t, generateExplanation{
+		Address:  "cloudflare_example.a",
+		Mapped:   []string{"name", "rules", "rules.action", "tags"},
+		Computed: []string{"created_on", "rules.version"},
+		Unmapped: []string{"meta", "rules.ref"},
+		Missing:  []string{"rules.action", "secret"},
+	}, e)
+
+	var buf bytes.Buffer
+	writeExplanation(&buf, e)
+	assert.Equal(t, `cloudflare_example.a
+  mapped:   name, rules, rules.action, tags
+  computed: created_on, rules.version
+  unmapped: meta, rules.ref
+  missing:  rules.action, secret
+`, buf.String())
+}
+
+func TestExplainResource_NestedAttributes(t *testing.T) {
+	zoneID, accountID = "", "f037e56e89293a057740de681ac9abbe"
+	defer func() { accountID = "" }()
+
+	block := &tfjson.SchemaBlock{
+		Attributes: map[string]*tfjson.SchemaAttribute{
+			"account_id": {AttributeType: cty.String, Required: true},
+			"name":       {AttributeType: cty.String, Required: true},
+			"config": {
+				Required: true,
+				AttributeNestedType: &tfjson.SchemaNestedAttributeType{
+					NestingMode: tfjson.SchemaNestingModeSingle,
+					Attributes: map[string]*tfjson.SchemaAttribute{
+						"client_id":     {AttributeType: cty.String, Optional: true},
+						"client_secret": {AttributeType: cty.String, Required: true, Sensitive: true},
+						"redirect_url":  {AttributeType: cty.String, Computed: true},
+					},
+				},
+			},
+			"headers": {
+				Optional: true,
+				AttributeNestedType: &tfjson.SchemaNestedAttributeType{
+					NestingMode: tfjson.SchemaNestingModeMap,
+					Attributes: map[string]*tfjson.SchemaAttribute{
+						"operation": {AttributeType: cty.String, Required: true},
+					},
+				},
+			},
+		},
+	}
+
+	data := map[string]interface{}{
+		"name": "example",
+		"config": map[string]interface{}{
+			"client_id":    "abc",
+			"redirect_url": "https://example.com/callback",
+			"pkce":         true,
+		},
+		"headers": map[string]interface{}{
+			"x-source": map[string]interface{}{"value": "cloudflare"},
+		},
+	}
+
+	assert.Equal(t, generateExplanation{
+		Address:  "cloudflare_example.a",
+		Mapped:   []string{"config", "config.client_id", "headers", "name"},
+		Computed: []string{"config.redirect_url"},
+		Unmapped: []string{"config.pkce", "headers.x-source.value"},
+		Missing:  []string{"config.client_secret", "headers.x-source.operation"},
+	}, explainResource("cloudflare_example.a", block, data))
+}
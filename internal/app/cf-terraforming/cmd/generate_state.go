@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/sirupsen/logrus"
+)
+
+// readStateFrom loads the state used by `--from-state`. The path can be a
+// Terraform working directory, the output of `terraform show -json` or a raw
+// state file, which Terraform converts using the provider schema.
+func readStateFrom(tf *tfexec.Terraform, path string) (*tfjson.State, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		dirTF, err := tfexec.NewTerraform(path, tf.ExecPath())
+		if err != nil {
+			return nil, err
+		}
+		return dirTF.Show(context.Background())
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the JSON output has a format version, raw state files have a
+	// `version` instead.
+	var probe struct {
+		FormatVersion string `json:"format_version"`
+	}
+	if err := json.Unmarshal(b, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if probe.FormatVersion == "" {
+		return tf.ShowStateFile(context.Background(), path)
+	}
+
+	var state tfjson.State
+	if err := state.UnmarshalJSON(b); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return &state, nil
+}
+
+// generateFromState writes configuration for the Cloudflare resources in state
+// using the same schema driven rendering as resources fetched from the API.
+// When types is empty, every Cloudflare resource type in state is generated.
+// Required attributes without a value in state are returned as variables and
+// account and zone IDs are lifted out when params is set.
+func generateFromState(w io.Writer, s *tfjson.ProviderSchema, resources map[string]*tfjson.StateResource, types []string, params *idParameters) []generatedVariable {
+	byType := make(map[string][]*tfjson.StateResource)
+	for _, r := range resources {
+		if !strings.HasPrefix(r.Type, "cloudflare_") {
+			continue
+		}
+		if len(types) > 0 && !contains(types, r.Type) {
+			continue
+		}
+		byType[r.Type] = append(byType[r.Type], r)
+	}
+
+	sortedTypes := make([]string, 0, len(byType))
+	for t := range byType {
+		sortedTypes = append(sortedTypes, t)
+	}
+	sort.Strings(sortedTypes)
+
+	var placeholders []generatedVariable
+	for _, resourceType := range sortedTypes {
+		r := s.ResourceSchemas[resourceType]
+		if r == nil {
+			log.WithFields(logrus.Fields{
+				"resource": resourceType,
+			}).Warn("resource type is not in the provider schema, skipping")
+			continue
+		}
+
+		stateResources := byType[resourceType]
+		sort.Slice(stateResources, func(i, j int) bool { return stateResources[i].Address < stateResources[j].Address })
+
+		f := hclwrite.NewEmptyFile()
+		rootBody := f.Body()
+		names := make(map[string]bool, len(stateResources))
+		var typePlaceholders []generatedVariable
+		for _, sr := range stateResources {
+			// State values are copied as rendering consumes them.
+			structData := make(map[string]interface{}, len(sr.AttributeValues))
+			attributes := make(map[string]interface{}, len(sr.AttributeValues))
+			for k, v := range sr.AttributeValues {
+				structData[k] = v
+				attributes[k] = v
+			}
+
+			id := resourceIDFromData(attributes)
+			name := stateResourceName(sr)
+			if names[name] {
+				name = terraformResourceName(id)
+			}
+			names[name] = true
+
+			resource := rootBody.AppendNewBlock("resource", []string{resourceType, name}).Body()
+			typePlaceholders = append(typePlaceholders, renderResource(r, resourceType, name, structData, resource)...)
+			rootBody.AppendNewline()
+
+			if useModernImportBlock {
+				appendImportBlock(rootBody, importEntry{
+					Address:      resourceType + "." + name,
+					ID:           buildRawImportAddress(resourceType, id, resourceToEndpoint[resourceType]["get"], attributes),
+					resourceType: resourceType,
+					resourceID:   id,
+					attributes:   attributes,
+				}, identitySchemaFor(s, resourceType))
+				rootBody.AppendNewline()
+			}
+		}
+
+		// variables are declared in variables.tf instead when using
+		// `--sensitive-variables`.
+		if !useSensitiveVariables {
+			for _, v := range typePlaceholders {
+				appendVariableBlock(rootBody, v)
+				rootBody.AppendNewline()
+			}
+		}
+		placeholders = append(placeholders, typePlaceholders...)
+
+		postProcess(f, resourceType)
+		externalizeGeneratedCollections(f)
+		if params != nil {
+			params.parameterizeFile(f)
+		}
+		fmt.Fprint(w, string(hclwrite.Format(f.Bytes())))
+	}
+
+	return placeholders
+}
+
+// stateResourceName keeps the name a resource has in state so that the
+// generated configuration lines up with it. Instances of resources using
+// `count` or `for_each` are given their own resource named after the key.
+func stateResourceName(r *tfjson.StateResource) string {
+	if r.Index == nil {
+		return r.Name
+	}
+
+	return r.Name + "_" + invalidResourceNameChars.ReplaceAllString(fmt.Sprint(r.Index), "_")
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const generateStateTestJSON = `{
+  "format_version": "1.0",
+  "terraform_version": "1.9.0",
+  "values": {
+    "root_module": {
+      "resources": [
+        {
+          "address": "cloudflare_dns_record.www",
+          "mode": "managed",
+          "type": "cloudflare_dns_record",
+          "name": "www",
+          "provider_name": "registry.terraform.io/cloudflare/cloudflare",
+          "values": {
+            "id": "abc123",
+            "zone_id": "0da42c8d2132a9ddaf714f9e7c920711",
+            "name": "www.example.com",
+            "content": "192.0.2.1",
+            "ttl": 300,
+            "modified_on": "2024-01-01T00:00:00Z"
+          }
+        },
+        {
+          "address": "cloudflare_dns_record.mx[0]",
+          "mode": "managed",
+          "type": "cloudflare_dns_record",
+          "name": "mx",
+          "index": 0,
+          "provider_name": "registry.terraform.io/cloudflare/cloudflare",
+          "values": {
+            "id": "def456",
+            "zone_id": "0da42c8d2132a9ddaf714f9e7c920711",
+            "name": "example.com",
+            "content": "mx.example.com",
+            "ttl": 1,
+            "modified_on": null
+          }
+        },
+        {
+          "address": "random_id.suffix",
+          "mode": "managed",
+          "type": "random_id",
+          "name": "suffix",
+          "provider_name": "registry.terraform.io/hashicorp/random",
+          "values": {"id": "xyz"}
+        }
+      ]
+    }
+  }
+}`
+
+var generateStateTestSchema = &tfjson.ProviderSchema{
+	ResourceSchemas: map[string]*tfjson.Schema{
+		"cloudflare_dns_record": {
+			Block: &tfjson.SchemaBlock{
+				Attributes: map[string]*tfjson.SchemaAttribute{
+					"id":          {AttributeType: cty.String, Computed: true},
+					"zone_id":     {AttributeType: cty.String, Required: true},
+					"name":        {AttributeType: cty.String, Required: true},
+					"content":     {AttributeType: cty.String, Optional: true},
+					"ttl":         {AttributeType: cty.Number, Required: true},
+					"modified_on": {AttributeType: cty.String, Computed: true},
+				},
+			},
+		},
+	},
+}
+
+func TestGenerateFromState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	require.NoError(t, os.WriteFile(path, []byte(generateStateTestJSON), 0o644))
+
+	state, err := readStateFrom(nil, path)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	generateFromState(&buf, generateStateTestSchema, stateResources(state), nil, nil)
+
+	assert.Equal(t, `resource "cloudflare_dns_record" "mx_0" {
+  content = "mx.example.com"
+  name    = "example.com"
+  ttl     = 1
+  zone_id = "0da42c8d2132a9ddaf714f9e7c920711"
+}
+
+resource "cloudflare_dns_record" "www" {
+  content = "192.0.2.1"
+  name    = "www.example.com"
+  ttl     = 300
+  zone_id = "0da42c8d2132a9ddaf714f9e7c920711"
+}
+
+`, buf.String())
+}
+
+func TestGenerateFromState_FilteredTypes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	require.NoError(t, os.WriteFile(path, []byte(generateStateTestJSON), 0o644))
+
+	state, err := readStateFrom(nil, path)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	generateFromState(&buf, generateStateTestSchema, stateResources(state), []string{"cloudflare_list"}, nil)
+
+	assert.Empty(t, buf.String())
+}
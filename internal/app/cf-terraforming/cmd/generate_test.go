@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
@@ -82,7 +83,7 @@ func TestGenerate_writeAttrLine(t *testing.T) {
 func TestGenerate_ResourceNotSupported(t *testing.T) {
 	output, err := executeCommandC(rootCmd, "generate", "--resource-type", "notreal")
 	assert.Nil(t, err)
-	assert.Equal(t, output, `"notreal" is not yet supported for automatic generation`)
+	assert.Equal(t, output, "\"notreal\" is not yet supported for automatic generation\n")
 }
 
 func TestResourceGeneration(t *testing.T) {
@@ -516,3 +517,61 @@ func TestResourceGenerationV5(t *testing.T) {
 		})
 	}
 }
+
+func TestWaitingRoomEvents_MultipleRooms(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/zones/" + cloudflareTestZoneID + "/waiting_rooms":
+			w.Write([]byte(`{"success": true, "errors": [], "messages": [], "result": [
+  {"id": "699d98642c564d2e855e9661899b7252", "name": "production_webinar"},
+  {"id": "5c3b1e2a0a8f4f0c9a2e4d1b7f6e3a21", "name": "checkout"}
+]}`))
+		case "/zones/" + cloudflareTestZoneID + "/waiting_rooms/699d98642c564d2e855e9661899b7252/events":
+			w.Write([]byte(`{"success": true, "errors": [], "messages": [], "result": [
+  {"id": "25756b2dfe6e378a06b033b670413757", "name": "production_webinar_event"}
+]}`))
+		case "/zones/" + cloudflareTestZoneID + "/waiting_rooms/5c3b1e2a0a8f4f0c9a2e4d1b7f6e3a21/events":
+			w.Write([]byte(`{"success": true, "errors": [], "messages": [], "result": [
+  {"id": "8f6b1a0c3d2e4f5a9b7c6d5e4f3a2b1c", "name": "black_friday"},
+  {"id": "1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d", "name": "cyber_monday"}
+]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	defer func(c *cfv0.API) { apiV0 = c }(apiV0)
+	apiV0, _ = cfv0.NewWithAPIToken("token", cfv0.BaseURL(server.URL))
+
+	roomIDs := map[string]string{}
+	for _, event := range waitingRoomEvents(cloudflareTestZoneID) {
+		e := event.(map[string]interface{})
+		roomIDs[e["id"].(string)] = e["waiting_room_id"].(string)
+	}
+
+	assert.Equal(t, map[string]string{
+		"25756b2dfe6e378a06b033b670413757": "699d98642c564d2e855e9661899b7252",
+		"8f6b1a0c3d2e4f5a9b7c6d5e4f3a2b1c": "5c3b1e2a0a8f4f0c9a2e4d1b7f6e3a21",
+		"1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d": "5c3b1e2a0a8f4f0c9a2e4d1b7f6e3a21",
+	}, roomIDs)
+}
+
+func TestFetchResourcesV5_UnresolvedPlaceholders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	defer func(c *cloudflare.Client) { api = c }(api)
+	api = cloudflare.NewClient(option.WithBaseURL(server.URL), option.WithAPIToken("token"), option.WithMaxRetries(0))
+
+	zoneID, accountID = cloudflareTestZoneID, ""
+	defer func() { zoneID = "" }()
+
+	_, err := fetchResourcesV5("cloudflare_waiting_room_event", nil)
+	assert.ErrorIs(t, err, errUnresolvedPlaceholders)
+	assert.ErrorContains(t, err, "/zones/"+cloudflareTestZoneID+"/waiting_rooms/{waiting_room_id}/events")
+}
@@ -0,0 +1,492 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// verifyConfigFilename is the file the generated configuration is written
+	// to inside of the scratch working directory.
+	verifyConfigFilename = "cf-terraforming-generated.tf"
+
+	// verifyPlanFilename is the saved plan inspected for attribute changes.
+	verifyPlanFilename = "cf-terraforming.tfplan"
+
+	// verifyVariablesFilename and verifyValuesFilename declare and set the
+	// generated variables that have a known value inside of the scratch
+	// working directory.
+	verifyVariablesFilename = "cf-terraforming-variables.tf"
+	verifyValuesFilename    = "cf-terraforming.auto.tfvars"
+)
+
+// verifyResult is the planned outcome for a single generated resource.
+type verifyResult struct {
+	Address    string
+	Action     string
+	Attributes []string
+}
+
+// clean reports whether the plan for the resource is an import with no
+// further changes.
+func (r verifyResult) clean() bool {
+	return r.Action == string(tfjson.ActionNoop)
+}
+
+// planMessage is a single line of the `terraform plan -json` output.
+type planMessage struct {
+	Type       string             `json:"type"`
+	Diagnostic *tfjson.Diagnostic `json:"diagnostic,omitempty"`
+}
+
+// verifyGeneratedConfig writes the generated configuration and import blocks
+// into a scratch copy of the working directory and runs `terraform validate`
+// and `terraform plan` against it. A line is written for every generated
+// resource with the planned action and the attributes that differ. Resources
+// referencing a variable without a known value can't be planned and are
+// reported as skipped. The scratch copy, which includes the state, is always
+// removed before exiting.
+func verifyGeneratedConfig(cmd *cobra.Command, workingDir, execPath, config string, entries []importEntry, variables []generatedVariable, dataFiles []generatedDataFile) {
+	scratchDir, err := os.MkdirTemp("", "cf-terraforming-verify")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = verifyInScratchDir(cmd.ErrOrStderr(), scratchDir, workingDir, execPath, config, entries, variables, dataFiles)
+	if removeErr := os.RemoveAll(scratchDir); removeErr != nil {
+		log.Warnf("failed to remove %s: %s", scratchDir, removeErr)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// verifyInScratchDir runs the verification of verifyGeneratedConfig in
+// scratchDir.
+func verifyInScratchDir(out io.Writer, scratchDir, workingDir, execPath, config string, entries []importEntry, variables []generatedVariable, dataFiles []generatedDataFile) error {
+	ctx := context.Background()
+
+	if err := copyWorkingDir(workingDir, scratchDir); err != nil {
+		return fmt.Errorf("failed to copy the Terraform working directory: %w", err)
+	}
+
+	skipped := unresolvedVariables(variables)
+	if len(skipped) > 0 {
+		var err error
+		if config, err = excludeResources(config, skipped); err != nil {
+			return err
+		}
+
+		kept := make([]importEntry, 0, len(entries))
+		for _, entry := range entries {
+			if _, ok := skipped[entry.Address]; !ok {
+				kept = append(kept, entry)
+			}
+		}
+		entries = kept
+	}
+
+	if !useModernImportBlock {
+		f := hclwrite.NewEmptyFile()
+		for _, entry := range entries {
+			appendImportBlock(f.Body(), entry, nil)
+			f.Body().AppendNewline()
+		}
+		config += "\n" + string(hclwrite.Format(f.Bytes()))
+	}
+
+	if err := os.WriteFile(filepath.Join(scratchDir, verifyConfigFilename), []byte(config), 0o644); err != nil {
+		return fmt.Errorf("failed to write generated configuration: %w", err)
+	}
+
+	if err := writeVerifyVariables(scratchDir, variables); err != nil {
+		return fmt.Errorf("failed to write generated variables: %w", err)
+	}
+
+	if err := writeVerifyDataFiles(scratchDir, dataFiles); err != nil {
+		return fmt.Errorf("failed to write generated data files: %w", err)
+	}
+
+	log.WithFields(logrus.Fields{
+		"directory": scratchDir,
+	}).Debug("verifying generated configuration")
+
+	tf, err := tfexec.NewTerraform(scratchDir, execPath)
+	if err != nil {
+		return err
+	}
+
+	validation, err := tf.Validate(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to validate generated configuration: %w", err)
+	}
+	if !validation.Valid {
+		writeDiagnostics(out, validation.Diagnostics)
+		return fmt.Errorf("generated configuration is invalid: %d error(s)", validation.ErrorCount)
+	}
+
+	planPath := filepath.Join(scratchDir, verifyPlanFilename)
+	var planOutput bytes.Buffer
+	_, err = tf.PlanJSON(ctx, &planOutput, tfexec.Out(planPath), tfexec.Lock(false))
+	if err != nil {
+		writeDiagnostics(out, planDiagnostics(&planOutput))
+		return fmt.Errorf("failed to plan generated configuration: %w", err)
+	}
+
+	plan, err := tf.ShowPlanFile(ctx, planPath)
+	if err != nil {
+		return fmt.Errorf("failed to read plan: %w", err)
+	}
+
+	addresses := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		addresses = append(addresses, entry.Address)
+	}
+
+	results := planResults(plan, addresses)
+	changed := 0
+	for _, r := range results {
+		if !r.clean() {
+			changed++
+		}
+
+		if len(r.Attributes) > 0 {
+			fmt.Fprintf(out, "%-8s %s (%s)\n", r.Action, r.Address, strings.Join(r.Attributes, ", "))
+		} else {
+			fmt.Fprintf(out, "%-8s %s\n", r.Action, r.Address)
+		}
+	}
+
+	addresses = make([]string, 0, len(skipped))
+	for address := range skipped {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		fmt.Fprintf(out, "%-8s %s (requires var.%s)\n", "skipped", address, strings.Join(skipped[address], ", var."))
+	}
+
+	fmt.Fprintf(out, "\n%d of %d resources planned without changes\n", len(results)-changed, len(results))
+	if len(skipped) > 0 {
+		fmt.Fprintf(out, "%d resources were skipped as they reference variables without a value\n", len(skipped))
+	}
+
+	if changed > 0 {
+		return fmt.Errorf("%d generated resources do not plan cleanly", changed)
+	}
+
+	return nil
+}
+
+// unresolvedVariables returns the names of the variables without a known
+// value, keyed by the address of the resource referencing them.
+func unresolvedVariables(variables []generatedVariable) map[string][]string {
+	unresolved := make(map[string][]string)
+	for _, v := range variables {
+		if v.Value.IsNull() {
+			unresolved[v.Address] = append(unresolved[v.Address], v.Name)
+		}
+	}
+
+	return unresolved
+}
+
+// excludeResources removes the resources at the addresses from config along
+// with their import blocks and the variables they reference.
+func excludeResources(config string, addresses map[string][]string) (string, error) {
+	f, diags := hclwrite.ParseConfig([]byte(config), verifyConfigFilename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return "", fmt.Errorf("failed to parse generated configuration: %s", diags.Error())
+	}
+
+	variables := make(map[string]bool)
+	for _, names := range addresses {
+		for _, name := range names {
+			variables[name] = true
+		}
+	}
+
+	body := f.Body()
+	for _, block := range body.Blocks() {
+		labels := block.Labels()
+		remove := false
+		switch block.Type() {
+		case "resource":
+			if len(labels) == 2 {
+				_, remove = addresses[labels[0]+"."+labels[1]]
+			}
+		case "import":
+			if to := block.Body().GetAttribute("to"); to != nil {
+				_, remove = addresses[strings.TrimSpace(tokensString(to.Expr().BuildTokens(nil)))]
+			}
+		case "variable":
+			remove = len(labels) == 1 && variables[labels[0]]
+		}
+
+		if remove {
+			body.RemoveBlock(block)
+		}
+	}
+
+	return string(hclwrite.Format(f.Bytes())), nil
+}
+
+// writeVerifyDataFiles writes the data files referenced relative to the
+// generated configuration, such as those of `--collection-threshold`, into
+// dir. Files referenced by an absolute path are read from where they were
+// written.
+func writeVerifyDataFiles(dir string, dataFiles []generatedDataFile) error {
+	for _, d := range dataFiles {
+		path, ok := d.modulePath()
+		if !ok {
+			continue
+		}
+
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, d.data, 0o644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeVerifyVariables declares the generated variables with a known value
+// that aren't already declared in dir, as they are written to
+// `--variables-dir` when using `--sensitive-variables`, and sets their values.
+func writeVerifyVariables(dir string, variables []generatedVariable) error {
+	declared, err := declaredVariables(dir)
+	if err != nil {
+		return err
+	}
+
+	declarations := hclwrite.NewEmptyFile()
+	values := hclwrite.NewEmptyFile()
+	for _, v := range variables {
+		if v.Value.IsNull() {
+			continue
+		}
+		if !declared[v.Name] {
+			declared[v.Name] = true
+			appendVariableBlock(declarations.Body(), v)
+			declarations.Body().AppendNewline()
+		}
+		values.Body().SetAttributeValue(v.Name, v.Value)
+	}
+
+	if len(values.Body().Attributes()) == 0 {
+		return nil
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, verifyVariablesFilename), hclwrite.Format(declarations.Bytes()), 0o644); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, verifyValuesFilename), hclwrite.Format(values.Bytes()), 0o600)
+}
+
+// declaredVariables returns the names of the variables declared by the
+// configuration files in dir.
+func declaredVariables(dir string) (map[string]bool, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+
+	declared := make(map[string]bool)
+	for _, path := range paths {
+		f, err := parseOrCreateHCLFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, block := range f.Body().Blocks() {
+			if block.Type() == "variable" && len(block.Labels()) == 1 {
+				declared[block.Labels()[0]] = true
+			}
+		}
+	}
+
+	return declared, nil
+}
+
+// planResults builds the verification result for each of the addresses from
+// the plan. Addresses missing from the plan are reported as such rather than
+// being treated as clean.
+func planResults(plan *tfjson.Plan, addresses []string) []verifyResult {
+	changes := make(map[string]*tfjson.ResourceChange)
+	if plan != nil {
+		for _, rc := range plan.ResourceChanges {
+			changes[rc.Address] = rc
+		}
+	}
+
+	results := make([]verifyResult, 0, len(addresses))
+	for _, address := range addresses {
+		rc, ok := changes[address]
+		if !ok || rc.Change == nil {
+			results = append(results, verifyResult{Address: address, Action: "missing"})
+			continue
+		}
+
+		action := string(tfjson.ActionNoop)
+		if !rc.Change.Actions.NoOp() {
+			action = actionString(rc.Change.Actions)
+		}
+
+		results = append(results, verifyResult{
+			Address:    address,
+			Action:     action,
+			Attributes: changedAttributes(rc.Change),
+		})
+	}
+
+	return results
+}
+
+// actionString joins the plan actions into a single value such as
+// `delete-create` for replacements.
+func actionString(actions tfjson.Actions) string {
+	s := make([]string, len(actions))
+	for i, a := range actions {
+		s[i] = string(a)
+	}
+
+	return strings.Join(s, "-")
+}
+
+// changedAttributes returns the top level attributes whose planned value
+// differs from the imported value.
+func changedAttributes(change *tfjson.Change) []string {
+	before, _ := change.Before.(map[string]interface{})
+	after, _ := change.After.(map[string]interface{})
+	unknown, _ := change.AfterUnknown.(map[string]interface{})
+
+	keys := make(map[string]struct{})
+	for k := range before {
+		keys[k] = struct{}{}
+	}
+	for k := range after {
+		keys[k] = struct{}{}
+	}
+
+	var attributes []string
+	for k := range keys {
+		if u, ok := unknown[k].(bool); ok && u {
+			if change.Actions.NoOp() {
+				continue
+			}
+			attributes = append(attributes, k)
+			continue
+		}
+
+		if !reflect.DeepEqual(before[k], after[k]) {
+			attributes = append(attributes, k)
+		}
+	}
+	sort.Strings(attributes)
+
+	return attributes
+}
+
+// planDiagnostics extracts the diagnostics from the `terraform plan -json`
+// output.
+func planDiagnostics(r io.Reader) []tfjson.Diagnostic {
+	var diagnostics []tfjson.Diagnostic
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var msg planMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+		if msg.Type == "diagnostic" && msg.Diagnostic != nil {
+			diagnostics = append(diagnostics, *msg.Diagnostic)
+		}
+	}
+
+	return diagnostics
+}
+
+// writeDiagnostics outputs Terraform diagnostics with the location they
+// relate to.
+func writeDiagnostics(w io.Writer, diagnostics []tfjson.Diagnostic) {
+	for _, d := range diagnostics {
+		location := ""
+		if d.Range != nil {
+			location = fmt.Sprintf(" (%s:%d)", d.Range.Filename, d.Range.Start.Line)
+		}
+		fmt.Fprintf(w, "%s: %s%s\n", d.Severity, d.Summary, location)
+		if d.Detail != "" {
+			fmt.Fprintf(w, "  %s\n", d.Detail)
+		}
+	}
+}
+
+// copyWorkingDir copies the Terraform working directory so that verification
+// never modifies it. The `.terraform` directory is linked rather than copied
+// to reuse the already initialised providers and modules.
+func copyWorkingDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			if d.Name() == ".terraform" {
+				abs, err := filepath.Abs(path)
+				if err != nil {
+					return err
+				}
+				if err := os.Symlink(abs, target); err != nil {
+					return err
+				}
+				return filepath.SkipDir
+			}
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(target, 0o755)
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		return os.WriteFile(target, data, info.Mode().Perm())
+	})
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestPlanResults(t *testing.T) {
+	plan := &tfjson.Plan{
+		ResourceChanges: []*tfjson.ResourceChange{
+			{
+				Address: "cloudflare_dns_record.clean",
+				Change: &tfjson.Change{
+					Actions:   tfjson.Actions{tfjson.ActionNoop},
+					Before:    map[string]interface{}{"name": "a", "ttl": float64(1)},
+					After:     map[string]interface{}{"name": "a", "ttl": float64(1)},
+					Importing: &tfjson.Importing{ID: "abc"},
+				},
+			},
+			{
+				Address: "cloudflare_dns_record.drifted",
+				Change: &tfjson.Change{
+					Actions:      tfjson.Actions{tfjson.ActionUpdate},
+					Before:       map[string]interface{}{"name": "b", "ttl": float64(1), "proxied": true, "modified_on": "2024-01-01T00:00:00Z"},
+					After:        map[string]interface{}{"name": "b", "ttl": float64(300)},
+					AfterUnknown: map[string]interface{}{"modified_on": true},
+				},
+			},
+			{
+				Address: "cloudflare_dns_record.replaced",
+				Change: &tfjson.Change{
+					Actions: tfjson.Actions{tfjson.ActionDelete, tfjson.ActionCreate},
+					Before:  map[string]interface{}{"type": "A"},
+					After:   map[string]interface{}{"type": "AAAA"},
+				},
+			},
+		},
+	}
+
+	got := planResults(plan, []string{
+		"cloudflare_dns_record.clean",
+		"cloudflare_dns_record.drifted",
+		"cloudflare_dns_record.replaced",
+		"cloudflare_dns_record.missing",
+	})
+
+	assert.Equal(t, []verifyResult{
+		{Address: "cloudflare_dns_record.clean", Action: "no-op"},
+		{Address: "cloudflare_dns_record.drifted", Action: "update", Attributes: []string{"modified_on", "proxied", "ttl"}},
+		{Address: "cloudflare_dns_record.replaced", Action: "delete-create", Attributes: []string{"type"}},
+		{Address: "cloudflare_dns_record.missing", Action: "missing"},
+	}, got)
+	assert.True(t, got[0].clean())
+	assert.False(t, got[1].clean())
+}
+
+func TestPlanDiagnostics(t *testing.T) {
+	output := strings.Join([]string{
+		`{"@level":"info","@message":"Terraform 1.9.0","type":"version"}`,
+		`{"@level":"error","@message":"Error: Unsupported argument","type":"diagnostic","diagnostic":{"severity":"error","summary":"Unsupported argument","detail":"An argument named \"foo\" is not expected here."}}`,
+		`not json`,
+	}, "\n")
+
+	diagnostics := planDiagnostics(strings.NewReader(output))
+
+	assert.Len(t, diagnostics, 1)
+	assert.Equal(t, "Unsupported argument", diagnostics[0].Summary)
+	assert.Equal(t, tfjson.DiagnosticSeverityError, diagnostics[0].Severity)
+}
+
+func TestExcludeResources(t *testing.T) {
+	config := `resource "cloudflare_zero_trust_tunnel_cloudflared" "a" {
+  name          = "a"
+  tunnel_secret = var.zero_trust_tunnel_cloudflared_a_tunnel_secret
+}
+
+import {
+  to = cloudflare_zero_trust_tunnel_cloudflared.a
+  id = "f037e56e89293a057740de681ac9abbe/a"
+}
+
+variable "zero_trust_tunnel_cloudflared_a_tunnel_secret" {
+  type = string
+}
+
+resource "cloudflare_zero_trust_tunnel_cloudflared" "b" {
+  name = "b"
+}
+
+import {
+  to = cloudflare_zero_trust_tunnel_cloudflared.b
+  id = "f037e56e89293a057740de681ac9abbe/b"
+}
+`
+
+	variables := []generatedVariable{
+		{Name: "zero_trust_tunnel_cloudflared_a_tunnel_secret", Address: "cloudflare_zero_trust_tunnel_cloudflared.a"},
+		{Name: "zero_trust_tunnel_cloudflared_b_secret", Address: "cloudflare_zero_trust_tunnel_cloudflared.b", Value: cty.StringVal("s3cret")},
+	}
+	skipped := unresolvedVariables(variables)
+	assert.Equal(t, map[string][]string{
+		"cloudflare_zero_trust_tunnel_cloudflared.a": {"zero_trust_tunnel_cloudflared_a_tunnel_secret"},
+	}, skipped)
+
+	excluded, err := excludeResources(config, skipped)
+	require.NoError(t, err)
+	assert.NotContains(t, excluded, "cloudflare_zero_trust_tunnel_cloudflared.a")
+	assert.NotContains(t, excluded, `variable "zero_trust_tunnel_cloudflared_a_tunnel_secret"`)
+	assert.Contains(t, excluded, `resource "cloudflare_zero_trust_tunnel_cloudflared" "b"`)
+	assert.Contains(t, excluded, "to = cloudflare_zero_trust_tunnel_cloudflared.b")
+}
+
+func TestWriteVerifyVariables(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "variables.tf"), []byte(`variable "declared" {}
+`), 0o644))
+
+	err := writeVerifyVariables(dir, []generatedVariable{
+		{Name: "declared", Type: cty.String, Value: cty.StringVal("a")},
+		{Name: "undeclared", Type: cty.String, Description: "b", Sensitive: true, Value: cty.StringVal("b")},
+		{Name: "unknown", Type: cty.String},
+	})
+	require.NoError(t, err)
+
+	declarations, err := os.ReadFile(filepath.Join(dir, verifyVariablesFilename))
+	require.NoError(t, err)
+	assert.Equal(t, `variable "undeclared" {
+  type        = string
+  description = "b"
+  sensitive   = true
+}
+
+`, string(declarations))
+
+	values, err := os.ReadFile(filepath.Join(dir, verifyValuesFilename))
+	require.NoError(t, err)
+	assert.Equal(t, `declared   = "a"
+undeclared = "b"
+`, string(values))
+}
+
+func TestWriteVerifyDataFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	err := writeVerifyDataFiles(dir, []generatedDataFile{
+		{reference: "${path.module}/cloudflare_list_example_items.json", data: []byte("[]\n")},
+		{reference: "${path.module}/data/cloudflare_list_other_items.csv", data: []byte("ip\n192.0.2.1\n")},
+		{reference: "/srv/terraform/cloudflare_list_absolute_items.json", data: []byte("[]\n")},
+	})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir, "cloudflare_list_example_items.json"))
+	require.NoError(t, err)
+	assert.Equal(t, "[]\n", string(data))
+
+	data, err = os.ReadFile(filepath.Join(dir, "data", "cloudflare_list_other_items.csv"))
+	require.NoError(t, err)
+	assert.Equal(t, "ip\n192.0.2.1\n", string(data))
+
+	assert.NoFileExists(t, filepath.Join(dir, "srv", "terraform", "cloudflare_list_absolute_items.json"))
+}
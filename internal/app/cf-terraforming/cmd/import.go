@@ -4,75 +4,90 @@ import (
 	"context"
 	"crypto/md5"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
 	cfv0 "github.com/cloudflare/cloudflare-go"
-	"github.com/cloudflare/cloudflare-go/v4"
-	"github.com/cloudflare/cloudflare-go/v4/option"
-	"github.com/hashicorp/go-version"
-	"github.com/hashicorp/hc-install/product"
-	"github.com/hashicorp/hc-install/releases"
 	"github.com/hashicorp/hcl/v2/hclwrite"
-	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"github.com/tidwall/gjson"
-	"github.com/zclconf/go-cty/cty"
 )
 
 // resourceImportStringFormats contains a mapping of the resource type to the
 // composite ID that is compatible with performing an import.
 var resourceImportStringFormats = map[string]string{
-	"cloudflare_access_application":    ":account_id/:id",
-	"cloudflare_access_group":          ":account_id/:id",
-	"cloudflare_access_rule":           ":identifier_type/:identifier_value/:id",
-	"cloudflare_account_member":        ":account_id/:id",
-	"cloudflare_argo":                  ":zone_id/argo",
-	"cloudflare_bot_management":        ":zone_id",
-	"cloudflare_byo_ip_prefix":         ":id",
-	"cloudflare_certificate_pack":      ":zone_id/:id",
-	"cloudflare_custom_hostname":       ":zone_id/:id",
-	"cloudflare_custom_pages":          ":identifier_type/:identifier_value/:id",
-	"cloudflare_custom_ssl":            ":zone_id/:id",
-	"cloudflare_filter":                ":zone_id/:id",
-	"cloudflare_firewall_rule":         ":zone_id/:id",
-	"cloudflare_healthcheck":           ":zone_id/:id",
-	"cloudflare_ip_list":               ":account_id/:id",
-	"cloudflare_load_balancer":         ":zone_id/:id",
-	"cloudflare_load_balancer_pool":    ":account_id/:id",
-	"cloudflare_load_balancer_monitor": ":account_id/:id",
-	"cloudflare_origin_ca_certificate": ":id",
-	"cloudflare_page_rule":             ":zone_id/:id",
-	"cloudflare_rate_limit":            ":zone_id/:id",
-	"cloudflare_record":                ":zone_id/:id",
-	"cloudflare_ruleset":               ":identifier_type/:identifier_value/:id",
-	"cloudflare_spectrum_application":  ":zone_id/:id",
-	"cloudflare_teams_list":            ":account_id/:id",
-	"cloudflare_teams_location":        ":account_id/:id",
-	"cloudflare_teams_proxy_endpoint":  ":account_id/:id",
-	"cloudflare_teams_rule":            ":account_id/:id",
-	"cloudflare_tunnel":                ":account_id/:id",
-	"cloudflare_turnstile_widget":      ":account_id/:id",
-	"cloudflare_waf_override":          ":zone_id/:id",
-	"cloudflare_waiting_room":          ":zone_id/:id",
-	"cloudflare_worker_route":          ":zone_id/:id",
-	"cloudflare_workers_kv_namespace":  ":account_id/:id",
-	"cloudflare_zone_lockdown":         ":zone_id/:id",
-	"cloudflare_zone":                  ":id",
+	"cloudflare_access_application":              ":account_id/:id",
+	"cloudflare_access_group":                    ":account_id/:id",
+	"cloudflare_access_identity_provider":        ":account_id/:id",
+	"cloudflare_access_mutual_tls_certificate":   ":account_id/:id",
+	"cloudflare_access_rule":                     ":identifier_type/:identifier_value/:id",
+	"cloudflare_access_service_token":            ":account_id/:id",
+	"cloudflare_account_member":                  ":account_id/:id",
+	"cloudflare_api_shield":                      ":zone_id",
+	"cloudflare_argo":                            ":zone_id/argo",
+	"cloudflare_bot_management":                  ":zone_id",
+	"cloudflare_byo_ip_prefix":                   ":id",
+	"cloudflare_certificate_pack":                ":zone_id/:id",
+	"cloudflare_custom_hostname":                 ":zone_id/:id",
+	"cloudflare_custom_hostname_fallback_origin": ":zone_id",
+	"cloudflare_custom_pages":                    ":identifier_type/:identifier_value/:id",
+	"cloudflare_custom_ssl":                      ":zone_id/:id",
+	"cloudflare_filter":                          ":zone_id/:id",
+	"cloudflare_firewall_rule":                   ":zone_id/:id",
+	"cloudflare_healthcheck":                     ":zone_id/:id",
+	"cloudflare_ip_list":                         ":account_id/:id",
+	"cloudflare_list":                            ":account_id/:id",
+	"cloudflare_load_balancer":                   ":zone_id/:id",
+	"cloudflare_load_balancer_pool":              ":account_id/:id",
+	"cloudflare_load_balancer_monitor":           ":account_id/:id",
+	"cloudflare_logpush_job":                     ":identifier_type/:identifier_value/:id",
+	"cloudflare_managed_headers":                 ":zone_id",
+	"cloudflare_origin_ca_certificate":           ":id",
+	"cloudflare_page_rule":                       ":zone_id/:id",
+	"cloudflare_rate_limit":                      ":zone_id/:id",
+	"cloudflare_record":                          ":zone_id/:id",
+	"cloudflare_ruleset":                         ":identifier_type/:identifier_value/:id",
+	"cloudflare_spectrum_application":            ":zone_id/:id",
+	"cloudflare_teams_list":                      ":account_id/:id",
+	"cloudflare_teams_location":                  ":account_id/:id",
+	"cloudflare_teams_proxy_endpoint":            ":account_id/:id",
+	"cloudflare_teams_rule":                      ":account_id/:id",
+	"cloudflare_tiered_cache":                    ":zone_id",
+	"cloudflare_tunnel":                          ":account_id/:id",
+	"cloudflare_turnstile_widget":                ":account_id/:id",
+	"cloudflare_url_normalization_settings":      ":zone_id",
+	"cloudflare_user_agent_blocking_rule":        ":zone_id/:id",
+	"cloudflare_waf_override":                    ":zone_id/:id",
+	"cloudflare_waf_package":                     ":zone_id/:id",
+	"cloudflare_waiting_room":                    ":zone_id/:id",
+	"cloudflare_waiting_room_event":              ":zone_id/:waiting_room_id/:id",
+	"cloudflare_waiting_room_rules":              ":zone_id/:waiting_room_id",
+	"cloudflare_waiting_room_settings":           ":zone_id",
+	"cloudflare_worker_route":                    ":zone_id/:id",
+	"cloudflare_workers_kv_namespace":            ":account_id/:id",
+	"cloudflare_zone_lockdown":                   ":zone_id/:id",
+	"cloudflare_zone_settings_override":          ":zone_id",
+	"cloudflare_zone":                            ":id",
 }
 
 var providerVersionString string
 
 func init() {
 	rootCmd.AddCommand(importCommand)
+
+	importCommand.Flags().BoolVar(&executeImport, "execute", false, "Import the resources directly into the Terraform state of the working directory instead of outputting the import commands")
+	importCommand.Flags().BoolVar(&importDryRun, "dry-run", false, "Show which resources --execute would import or skip without modifying the Terraform state")
+	importCommand.Flags().StringVar(&importReportPath, "import-report", "", "Path to write a JSON report of the --execute results")
+	importCommand.Flags().IntVar(&importParallelism, "parallelism", 1, "Number of imports --execute runs at once. Each import waits on the Terraform state lock held by the others")
+	importCommand.Flags().BoolVar(&excludeManaged, "exclude-managed", false, "Skip resources that are already tracked in the Terraform state of the working directory")
+	importCommand.Flags().BoolVar(&verifyImports, "verify-imports", false, "Verify each import ID by fetching the resource from the Cloudflare API before outputting it")
+	importCommand.Flags().StringSliceVar(&rulesetPhases, "ruleset-phase", []string{}, "Only import rulesets in the provided phases. Example: `http_request_firewall_custom,http_request_cache_settings`")
+	importCommand.Flags().StringSliceVar(&rulesetKinds, "ruleset-kind", []string{}, "Only import rulesets of the provided kinds: root, zone or custom")
 }
 
 var importCommand = &cobra.Command{
@@ -86,150 +101,50 @@ func runImport() func(cmd *cobra.Command, args []string) {
 	return func(cmd *cobra.Command, args []string) {
 		zoneID = viper.GetString("zone")
 		accountID = viper.GetString("account")
-		workingDir := viper.GetString("terraform-install-path")
-		execPath := viper.GetString("terraform-binary-path")
-
-		// Download terraform if no existing binary was provided
-		if execPath == "" {
-			tmpDir, err := os.MkdirTemp("", "tfinstall")
-			if err != nil {
-				log.Fatal(err)
-			}
-			defer os.RemoveAll(tmpDir)
-
-			installConstraints, err := version.NewConstraint("~> 1.0")
-			if err != nil {
-				log.Fatal("failed to parse version constraints for installation version")
-			}
-
-			installer := &releases.LatestVersion{
-				Product:     product.Terraform,
-				Constraints: installConstraints,
-			}
-
-			execPath, err = installer.Install(context.Background())
-			if err != nil {
-				log.Fatalf("error installing Terraform: %s", err)
-			}
-		}
 
-		// Setup and configure Terraform to operate in the temporary directory where
-		// the provider is already configured.
-		log.WithFields(logrus.Fields{
-			"directory": workingDir,
-		}).Debug("initializing Terraform")
-		tf, err := tfexec.NewTerraform(workingDir, execPath)
-		if err != nil {
-			log.Fatal(err)
+		if importParallelism < 1 {
+			log.Fatalf("--parallelism must be at least 1, got %d", importParallelism)
 		}
 
-		_, providerVersion, err := tf.Version(context.Background(), true)
-		if err != nil {
-			log.Fatalf("failed to retrieve terraform and provider version information: %s", err)
-		}
+		tf, registryPath, cleanup := initTerraform()
+		defer cleanup()
 
-		var registryPath string
-		for provider := range providerVersion {
-			if strings.Contains(provider, "/cloudflare/cloudflare") {
-				registryPath = provider
-				continue
-			}
+		// the for_each imports target the resources collapsed by `generate
+		// --for-each`, which leaves v4 resources with nested blocks as is.
+		if useImportForEach && useModernImportBlock && !strings.HasPrefix(providerVersionString, "5") {
+			cleanup()
+			log.Fatal("--import-for-each requires v5 of the provider, use generate --for-each --import-for-each to import v4 resources")
 		}
 
-		detectedVersion, ok := providerVersion[registryPath]
-		if !ok {
-			log.WithFields(logrus.Fields{
-				"available_registries": providerVersion,
-			}).Fatal("failed to find registry")
+		// verification fetches each resource from the `get` endpoints mapped for
+		// v5, v4 resources have no equivalent to check against.
+		if verifyImports && !strings.HasPrefix(providerVersionString, "5") {
+			cleanup()
+			log.Fatalf("--verify-imports requires v5 of the provider, found %s", providerVersionString)
 		}
 
-		providerVersionString := detectedVersion.String()
-		log.WithFields(logrus.Fields{
-			"version":  providerVersionString,
-			"registry": registryPath,
-		}).Debug("detected provider")
-
+		var entries []importEntry
 		var jsonStructData []interface{}
 
 		if strings.HasPrefix(providerVersionString, "5") {
 			resources := strings.Split(resourceType, ",")
-			for _, resourceType := range resources {
-				var result *http.Response
-
-				// by default, we want to use the `list` operation however, there are times
-				// when resources exist only as `get` operations but contain multiple
-				// resources.
-				endpoint := resourceToEndpoint[resourceType]["list"]
-				if endpoint == "" {
-					endpoint = resourceToEndpoint[resourceType]["get"]
-				}
 
-				// if we encounter a combined endpoint, we need to rewrite to use the correct
-				// endpoint depending on what parameters are being provided.
-				if strings.Contains(endpoint, "{account_or_zone}") {
-					if accountID != "" {
-						endpoint = strings.Replace(endpoint, "/{account_or_zone}/{account_or_zone_id}/", "/accounts/{account_id}/", 1)
-					} else {
-						endpoint = strings.Replace(endpoint, "/{account_or_zone}/{account_or_zone_id}/", "/zones/{zone_id}/", 1)
-					}
-				}
-
-				// replace the URL placeholders with the actual values we have.
-				placeholderReplacer := strings.NewReplacer("{account_id}", accountID, "{zone_id}", zoneID)
-				endpoint = placeholderReplacer.Replace(endpoint)
-
-				if strings.Contains(endpoint, "{") {
-					log.WithFields(logrus.Fields{
-						"resource": resourceType,
-						"endpoint": endpoint,
-					}).Debug("failed to substitute all path placeholders due to unknown parameters")
-
-					continue
-				}
-
-				client := cloudflare.NewClient()
-				if apiToken != "" {
-					client.Options = append(client.Options, option.WithAPIToken(apiToken))
-				} else {
-					client.Options = append(client.Options, option.WithAPIKey(apiKey), option.WithAPIEmail(apiEmail))
-				}
-
-				err := client.Get(context.Background(), endpoint, nil, &result)
-				if err != nil {
-					var apierr *cloudflare.Error
-					if errors.As(err, &apierr) {
-						if apierr.StatusCode == http.StatusNotFound {
-							log.WithFields(logrus.Fields{
-								"resource": resourceType,
-								"endpoint": endpoint,
-							}).Debug("no resources found")
-
-							continue
-						}
-					}
-					log.Fatalf("failed to fetch API endpoint: %s", err)
-				}
+			resourceIDsMap := make(map[string][]string)
+			if slices.Contains(resources, "cloudflare_zone_setting") || slices.Contains(resources, "cloudflare_hostname_tls_setting") {
+				resourceIDsMap = getResourceMappings()
+			}
 
-				body, err := io.ReadAll(result.Body)
+			for _, resourceType := range resources {
+				jsonStructData, err := fetchResourcesV5(resourceType, resourceIDsMap[resourceType])
 				if err != nil {
-					log.Fatalln(err)
-				}
-
-				value := gjson.Get(string(body), "result")
-				if value.Type == gjson.Null {
 					log.WithFields(logrus.Fields{
 						"resource": resourceType,
-						"endpoint": endpoint,
-					}).Debug("no result found")
+					}).Debugf("skipping resource: %s", err)
 
 					continue
 				}
 
-				modifiedJSON := modifyResponsePayload(resourceType, value)
-				err = json.Unmarshal([]byte(modifiedJSON), &jsonStructData)
-				if err != nil {
-					log.Fatalf("failed to unmarshal result: %s", err)
-				}
+				entries = append(entries, buildImportEntries(resourceType, jsonStructData)...)
 			}
 		} else {
 			var identifier *cfv0.ResourceContainer
@@ -259,6 +174,39 @@ func runImport() func(cmd *cobra.Command, args []string) {
 						log.Fatal(err)
 					}
 
+					m, _ := json.Marshal(jsonPayload)
+					err = json.Unmarshal(m, &jsonStructData)
+					if err != nil {
+						log.Fatal(err)
+					}
+				case "cloudflare_access_identity_provider":
+					jsonPayload, _, err := apiV0.ListAccessIdentityProviders(context.Background(), identifier, cfv0.ListAccessIdentityProvidersParams{})
+					if err != nil {
+						log.Fatal(err)
+					}
+
+					m, _ := json.Marshal(jsonPayload)
+					err = json.Unmarshal(m, &jsonStructData)
+					if err != nil {
+						log.Fatal(err)
+					}
+				case "cloudflare_access_service_token":
+					jsonPayload, _, err := apiV0.ListAccessServiceTokens(context.Background(), identifier, cfv0.ListAccessServiceTokensParams{})
+					if err != nil {
+						log.Fatal(err)
+					}
+
+					m, _ := json.Marshal(jsonPayload)
+					err = json.Unmarshal(m, &jsonStructData)
+					if err != nil {
+						log.Fatal(err)
+					}
+				case "cloudflare_access_mutual_tls_certificate":
+					jsonPayload, _, err := apiV0.ListAccessMutualTLSCertificates(context.Background(), identifier, cfv0.ListAccessMutualTLSCertificatesParams{})
+					if err != nil {
+						log.Fatal(err)
+					}
+
 					m, _ := json.Marshal(jsonPayload)
 					err = json.Unmarshal(m, &jsonStructData)
 					if err != nil {
@@ -303,6 +251,48 @@ func runImport() func(cmd *cobra.Command, args []string) {
 						ID: fmt.Sprintf("%x", md5.Sum([]byte(time.Now().String()))),
 					}}
 
+					m, _ := json.Marshal(jsonPayload)
+					err := json.Unmarshal(m, &jsonStructData)
+					if err != nil {
+						log.Fatal(err)
+					}
+				case "cloudflare_api_shield":
+					apiShieldConfig, _, err := apiV0.GetAPIShieldConfiguration(context.Background(), identifier)
+					if err != nil {
+						log.Fatal(err)
+					}
+
+					// an empty configuration isn't generated so there is nothing to
+					// import either.
+					if len(apiShieldConfig.AuthIdCharacteristics) == 0 {
+						continue
+					}
+
+					m, _ := json.Marshal([]cfv0.APIShield{apiShieldConfig})
+					err = json.Unmarshal(m, &jsonStructData)
+					if err != nil {
+						log.Fatal(err)
+					}
+
+					jsonStructData[0].(map[string]interface{})["id"] = zoneID
+				case "cloudflare_user_agent_blocking_rule":
+					page := 1
+					var jsonPayload []cfv0.UserAgentRule
+					for {
+						res, err := apiV0.ListUserAgentRules(context.Background(), zoneID, page)
+						if err != nil {
+							log.Fatal(err)
+						}
+
+						jsonPayload = append(jsonPayload, res.Result...)
+						res.ResultInfo = res.ResultInfo.Next()
+
+						if res.ResultInfo.Done() {
+							break
+						}
+						page = page + 1
+					}
+
 					m, _ := json.Marshal(jsonPayload)
 					err := json.Unmarshal(m, &jsonStructData)
 					if err != nil {
@@ -376,6 +366,23 @@ func runImport() func(cmd *cobra.Command, args []string) {
 							log.Fatal(err)
 						}
 					}
+				case "cloudflare_custom_hostname_fallback_origin":
+					fallbackOrigin, err := apiV0.CustomHostnameFallbackOrigin(context.Background(), zoneID)
+					if err != nil {
+						log.Fatal(err)
+					}
+
+					if fallbackOrigin.Origin == "" {
+						continue
+					}
+
+					m, _ := json.Marshal([]cfv0.CustomHostnameFallbackOrigin{fallbackOrigin})
+					err = json.Unmarshal(m, &jsonStructData)
+					if err != nil {
+						log.Fatal(err)
+					}
+
+					jsonStructData[0].(map[string]interface{})["id"] = sanitiseTerraformResourceName(fallbackOrigin.Origin)
 				case "cloudflare_filter":
 					jsonPayload, _, err := apiV0.Filters(context.Background(), identifier, cfv0.FilterListParams{})
 					if err != nil {
@@ -437,6 +444,16 @@ func runImport() func(cmd *cobra.Command, args []string) {
 					if err != nil {
 						log.Fatal(err)
 					}
+				case "cloudflare_list":
+					jsonPayload, err := apiV0.ListLists(context.Background(), identifier, cfv0.ListListsParams{})
+					if err != nil {
+						log.Fatal(err)
+					}
+					m, _ := json.Marshal(jsonPayload)
+					err = json.Unmarshal(m, &jsonStructData)
+					if err != nil {
+						log.Fatal(err)
+					}
 				case "cloudflare_load_balancer":
 					jsonPayload, err := apiV0.ListLoadBalancers(context.Background(), identifier, cfv0.ListLoadBalancerParams{})
 					if err != nil {
@@ -477,6 +494,19 @@ func runImport() func(cmd *cobra.Command, args []string) {
 					if err != nil {
 						log.Fatal(err)
 					}
+				case "cloudflare_managed_headers":
+					jsonPayload, err := apiV0.ListZoneManagedHeaders(context.Background(), cfv0.ResourceIdentifier(zoneID), cfv0.ListManagedHeadersParams{Status: "enabled"})
+					if err != nil {
+						log.Fatal(err)
+					}
+
+					m, _ := json.Marshal([]cfv0.ManagedHeaders{jsonPayload})
+					err = json.Unmarshal(m, &jsonStructData)
+					if err != nil {
+						log.Fatal(err)
+					}
+
+					jsonStructData[0].(map[string]interface{})["id"] = zoneID
 				case "cloudflare_origin_ca_certificate":
 					jsonPayload, err := apiV0.ListOriginCACertificates(context.Background(), cfv0.ListOriginCertificatesParams{ZoneID: zoneID})
 					if err != nil {
@@ -530,7 +560,7 @@ func runImport() func(cmd *cobra.Command, args []string) {
 					// have them try to import something they can't manage with terraform
 					var nonManagedRules []cfv0.Ruleset
 					for _, r := range jsonPayload {
-						if r.Kind != string(cfv0.RulesetKindManaged) {
+						if rulesetSelected(r.Kind, r.Phase) {
 							nonManagedRules = append(nonManagedRules, r)
 						}
 					}
@@ -630,6 +660,19 @@ func runImport() func(cmd *cobra.Command, args []string) {
 					for i := 0; i < len(jsonStructData); i++ {
 						jsonStructData[i].(map[string]interface{})["id"] = jsonStructData[i].(map[string]interface{})["sitekey"]
 					}
+				case "cloudflare_url_normalization_settings":
+					jsonPayload, err := apiV0.URLNormalizationSettings(context.Background(), &cfv0.ResourceContainer{Identifier: zoneID, Level: cfv0.ZoneRouteLevel})
+					if err != nil {
+						log.Fatal(err)
+					}
+
+					m, _ := json.Marshal([]cfv0.URLNormalizationSettings{jsonPayload})
+					err = json.Unmarshal(m, &jsonStructData)
+					if err != nil {
+						log.Fatal(err)
+					}
+
+					jsonStructData[0].(map[string]interface{})["id"] = zoneID
 				case "cloudflare_waf_override":
 					jsonPayload, err := apiV0.ListWAFOverrides(context.Background(), zoneID)
 					if err != nil {
@@ -661,6 +704,32 @@ func runImport() func(cmd *cobra.Command, args []string) {
 					if err != nil {
 						log.Fatal(err)
 					}
+				case "cloudflare_waiting_room_event":
+					jsonStructData = waitingRoomEvents(zoneID)
+				case "cloudflare_waiting_room_rules":
+					waitingRooms, err := apiV0.ListWaitingRooms(context.Background(), zoneID)
+					if err != nil {
+						log.Fatal(err)
+					}
+					for _, waitingRoom := range waitingRooms {
+						jsonStructData = append(jsonStructData, map[string]interface{}{
+							"id":              waitingRoom.ID,
+							"waiting_room_id": waitingRoom.ID,
+						})
+					}
+				case "cloudflare_waiting_room_settings":
+					waitingRoomSettings, err := apiV0.GetWaitingRoomSettings(context.Background(), cfv0.ZoneIdentifier(zoneID))
+					if err != nil {
+						log.Fatal(err)
+					}
+
+					m, _ := json.Marshal([]cfv0.WaitingRoomSettings{waitingRoomSettings})
+					err = json.Unmarshal(m, &jsonStructData)
+					if err != nil {
+						log.Fatal(err)
+					}
+
+					jsonStructData[0].(map[string]interface{})["id"] = zoneID
 				case "cloudflare_workers_kv_namespace":
 					jsonPayload, _, err := apiV0.ListWorkersKVNamespaces(context.Background(), identifier, cfv0.ListWorkersKVNamespacesParams{})
 					if err != nil {
@@ -704,75 +773,124 @@ func runImport() func(cmd *cobra.Command, args []string) {
 					if err != nil {
 						log.Fatal(err)
 					}
+				case "cloudflare_zone_settings_override":
+					jsonStructData = append(jsonStructData, map[string]interface{}{"id": zoneID})
+				case "cloudflare_tiered_cache":
+					jsonStructData = append(jsonStructData, map[string]interface{}{"id": zoneID})
 				default:
 					fmt.Fprintf(cmd.OutOrStderr(), "%q is not yet supported for state import", resourceType)
 					return
 				}
+
+				entries = append(entries, buildImportEntries(resourceType, jsonStructData)...)
+				jsonStructData = nil
+			}
+		}
+
+		if excludeManaged {
+			state, err := tf.Show(context.Background())
+			if err != nil {
+				log.Fatalf("failed to read Terraform state: %s", err)
 			}
+			entries = excludeManagedEntries(entries, stateResources(state))
 		}
 
-		importFile := hclwrite.NewEmptyFile()
-		importBody := importFile.Body()
+		if verifyImports {
+			verifyImportEntries(entries)
+		}
 
-		for _, data := range jsonStructData {
-			var id string
+		if executeImport || importDryRun {
+			executeImports(cmd, tf, entries)
+			return
+		}
 
-			if data.(map[string]interface{})["id"] == nil {
-				if accountID != "" {
-					id = accountID
+		if !useModernImportBlock {
+			for _, entry := range entries {
+				fmt.Fprint(cmd.OutOrStdout(), string(verificationComment(entry).Bytes()))
+				fmt.Fprint(cmd.OutOrStdout(), buildTerraformImportCommand(entry.resourceType, entry.resourceID, resourceToEndpoint[entry.resourceType]["get"], entry.attributes))
+			}
+		} else {
+			var providerSchema *tfjson.ProviderSchema
+			if useImportIdentity {
+				ps, err := tf.ProvidersSchema(context.Background())
+				if err != nil {
+					log.Fatal("failed to read provider schema", err)
 				}
+				providerSchema = ps.Schemas[registryPath]
+			}
 
-				if zoneID != "" {
-					id = zoneID
+			importFile := hclwrite.NewEmptyFile()
+			importBody := importFile.Body()
+
+			types, grouped := groupEntriesByType(entries)
+			for _, t := range types {
+				identitySchema := identitySchemaFor(providerSchema, t)
+				if useImportForEach && len(grouped[t]) > 1 {
+					appendForEachImportBlock(importBody, t, grouped[t], identitySchema)
+					importBody.AppendNewline()
+					continue
 				}
-			} else {
-				id = data.(map[string]interface{})["id"].(string)
-			}
 
-			if useModernImportBlock {
-				idvalue := buildRawImportAddress(resourceType, id, resourceToEndpoint[resourceType]["get"])
-				imp := importBody.AppendNewBlock("import", []string{}).Body()
-				imp.SetAttributeRaw("to", hclwrite.TokensForIdentifier(fmt.Sprintf("%s.%s", resourceType, fmt.Sprintf("%s_%s", terraformResourceNamePrefix, id))))
-				imp.SetAttributeValue("id", cty.StringVal(idvalue))
-				importFile.Body().AppendNewline()
-			} else {
-				fmt.Fprint(cmd.OutOrStdout(), buildTerraformImportCommand(resourceType, id, resourceToEndpoint[resourceType]["get"]))
+				for _, entry := range grouped[t] {
+					appendImportBlock(importBody, entry, identitySchema)
+					importBody.AppendNewline()
+				}
 			}
+
+			fmt.Fprint(cmd.OutOrStdout(), string(hclwrite.Format(importFile.Bytes())))
 		}
 
-		if useModernImportBlock {
-			// don't format the output; there is a bug in hclwrite.Format that
-			// splits incorrectly on certain characters. instead, manually
-			// insert new lines on the block.
-			fmt.Fprint(cmd.OutOrStdout(), string(importFile.Bytes()))
+		if failed, unverified := verificationCounts(entries); failed > 0 || unverified > 0 {
+			log.Fatalf("%d of %d import IDs failed verification and %d could not be verified", failed, len(entries), unverified)
 		}
 	}
 }
 
+// buildImportEntries builds the import entry for each API result of
+// resourceType using the same resource names as `generate`.
+func buildImportEntries(resourceType string, jsonStructData []interface{}) []importEntry {
+	entries := make([]importEntry, 0, len(jsonStructData))
+	for _, data := range jsonStructData {
+		attributes := data.(map[string]interface{})
+		id := resourceIDFromData(attributes)
+		entries = append(entries, importEntry{
+			Address:      resourceType + "." + terraformResourceName(id),
+			ID:           buildRawImportAddress(resourceType, id, resourceToEndpoint[resourceType]["get"], attributes),
+			resourceType: resourceType,
+			resourceID:   id,
+			attributes:   attributes,
+		})
+	}
+
+	return entries
+}
+
 // buildTerraformImportCommand takes the resourceType and resourceID in order to
 // lookup the resource type import string and then return a suitable composite
 // value that is compatible with `terraform import`.
 //
 // Note: `endpoint` is only used on > v4. Otherwise it is ignored.
-func buildTerraformImportCommand(resourceType, resourceID, endpoint string) string {
-	resourceImportAddress := buildRawImportAddress(resourceType, resourceID, endpoint)
-	return fmt.Sprintf("%s %s.%s_%s %s\n", terraformImportCmdPrefix, resourceType, terraformResourceNamePrefix, resourceID, resourceImportAddress)
+func buildTerraformImportCommand(resourceType, resourceID, endpoint string, attributes map[string]interface{}) string {
+	resourceImportAddress := buildRawImportAddress(resourceType, resourceID, endpoint, attributes)
+	return fmt.Sprintf("%s %s.%s %s\n", terraformImportCmdPrefix, resourceType, terraformResourceName(resourceID), resourceImportAddress)
 }
 
 // buildRawImportAddress takes the resourceType and resourceID in order to lookup
 // the resource type import string and then return a suitable address.
+// `attributes` is the API result for the resource and is used to populate any
+// parent identifiers the import format requires (such as `:waiting_room_id`).
 //
 // Note: `endpoint` is only used on > v4. Otherwise it is ignored.
-func buildRawImportAddress(resourceType, resourceID, endpoint string) string {
+func buildRawImportAddress(resourceType, resourceID, endpoint string, attributes map[string]interface{}) string {
 	if strings.HasPrefix(providerVersionString, "5") {
 		prefix := ""
-		if strings.Contains(endpoint, "{account_or_zone}") {
+		if strings.Contains(endpoint, "{accounts_or_zones}") {
 			if accountID != "" {
 				prefix = "accounts"
-				endpoint = strings.Replace(endpoint, "/{account_or_zone}/{account_or_zone_id}/", "/accounts/{account_id}/", 1)
+				endpoint = strings.Replace(endpoint, "/{accounts_or_zones}/{account_or_zone_id}/", "/accounts/{account_id}/", 1)
 			} else {
 				prefix = "zones"
-				endpoint = strings.Replace(endpoint, "/{account_or_zone}/{account_or_zone_id}/", "/zones/{zone_id}/", 1)
+				endpoint = strings.Replace(endpoint, "/{accounts_or_zones}/{account_or_zone_id}/", "/zones/{zone_id}/", 1)
 			}
 		}
 
@@ -821,10 +939,13 @@ func buildRawImportAddress(resourceType, resourceID, endpoint string) string {
 			identiferValue = zoneID
 		}
 
+		waitingRoomID, _ := attributes["waiting_room_id"].(string)
+
 		s := resourceImportStringFormats[resourceType]
 		replacer := strings.NewReplacer(
 			":identifier_type", identiferType,
 			":identifier_value", identiferValue,
+			":waiting_room_id", waitingRoomID,
 			":zone_id", zoneID,
 			":account_id", accountID,
 			":id", resourceID,
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// importIdentity builds the resource identity object for an entry using the
+// provider's identity schema. Identity attributes are populated from the
+// targeted account or zone, the resource ID or the API result. If an attribute
+// required for import can't be resolved, false is returned and the caller
+// should fall back to the string ID.
+func importIdentity(schema *tfjson.IdentitySchema, entry importEntry) (cty.Value, bool) {
+	if schema == nil || len(schema.Attributes) == 0 {
+		return cty.NilVal, false
+	}
+
+	identity := make(map[string]cty.Value)
+	for name, attr := range schema.Attributes {
+		var value interface{}
+		switch {
+		case name == "id":
+			value = entry.resourceID
+		case name == "account_id" && accountID != "":
+			value = accountID
+		case name == "zone_id" && zoneID != "":
+			value = zoneID
+		default:
+			value = entry.attributes[name]
+		}
+
+		v, ok := identityValue(value, attr.IdentityType)
+		if !ok {
+			if attr.RequiredForImport {
+				return cty.NilVal, false
+			}
+			continue
+		}
+		identity[name] = v
+	}
+
+	return cty.ObjectVal(identity), true
+}
+
+// identityValue converts a value from the API into the type the identity
+// schema declares.
+func identityValue(value interface{}, ty cty.Type) (cty.Value, bool) {
+	var s string
+	switch v := value.(type) {
+	case nil:
+		return cty.NilVal, false
+	case string:
+		s = v
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		s = strconv.FormatBool(v)
+	default:
+		return cty.NilVal, false
+	}
+
+	if s == "" {
+		return cty.NilVal, false
+	}
+
+	switch ty {
+	case cty.Number:
+		n, err := cty.ParseNumberVal(s)
+		if err != nil {
+			return cty.NilVal, false
+		}
+		return n, true
+	case cty.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return cty.NilVal, false
+		}
+		return cty.BoolVal(b), true
+	default:
+		return cty.StringVal(s), true
+	}
+}
+
+// appendImportBlock writes an `import` block for a single entry. When an
+// identity schema is provided and the identity can be resolved, `identity` is
+// used in place of the string `id`.
+func appendImportBlock(body *hclwrite.Body, entry importEntry, identitySchema *tfjson.IdentitySchema) {
+	body.AppendUnstructuredTokens(verificationComment(entry))
+
+	imp := body.AppendNewBlock("import", []string{}).Body()
+	resourceType, name, _ := strings.Cut(entry.Address, ".")
+	imp.SetAttributeTraversal("to", hcl.Traversal{
+		hcl.TraverseRoot{Name: resourceType},
+		hcl.TraverseAttr{Name: name},
+	})
+
+	if identity, ok := importIdentity(identitySchema, entry); ok {
+		imp.SetAttributeValue("identity", identity)
+	} else {
+		imp.SetAttributeValue("id", cty.StringVal(entry.ID))
+	}
+}
+
+// appendForEachImportBlock writes a single `import` block that uses
+// `for_each` to import every entry of a resource type. The resource instances
+// are keyed the same way as `generate --for-each` keys them so the imports
+// target the collapsed resource it outputs.
+func appendForEachImportBlock(body *hclwrite.Body, resourceType string, entries []importEntry, identitySchema *tfjson.IdentitySchema) {
+	resources := make([]*collapsedResource, len(entries))
+	byResource := make(map[*collapsedResource]importEntry, len(entries))
+	for i, entry := range entries {
+		_, name, _ := strings.Cut(entry.Address, ".")
+		resources[i] = &collapsedResource{name: name, attributes: entry.attributes}
+		byResource[resources[i]] = entry
+	}
+	assignForEachKeys(resourceType, resources)
+
+	values := make(map[string]cty.Value, len(resources))
+	useIdentity := identitySchema != nil
+	for _, r := range resources {
+		entry := byResource[r]
+		body.AppendUnstructuredTokens(verificationComment(entry))
+
+		identity, ok := importIdentity(identitySchema, entry)
+		if !ok {
+			useIdentity = false
+		}
+		values[r.key] = identity
+	}
+
+	// Terraform requires every `for_each` value to be used in the same way so
+	// if a single identity can't be resolved, all entries use string IDs.
+	if !useIdentity {
+		for _, r := range resources {
+			values[r.key] = cty.StringVal(byResource[r].ID)
+		}
+	}
+
+	appendForEachImport(body, resourceType, terraformResourceNamePrefix, values, useIdentity)
+}
+
+// appendForEachImport writes a single `import` block that imports each of
+// values into the instance of resourceType.resourceName with the same key.
+// The values are either resource identities or string IDs.
+func appendForEachImport(body *hclwrite.Body, resourceType, resourceName string, values map[string]cty.Value, useIdentity bool) {
+	imp := body.AppendNewBlock("import", []string{}).Body()
+	imp.SetAttributeValue("for_each", cty.ObjectVal(values))
+
+	to := hclwrite.TokensForTraversal(hcl.Traversal{
+		hcl.TraverseRoot{Name: resourceType},
+		hcl.TraverseAttr{Name: resourceName},
+	})
+	to = append(to, &hclwrite.Token{Type: hclsyntax.TokenOBrack, Bytes: []byte("[")})
+	to = append(to, hclwrite.TokensForTraversal(hcl.Traversal{
+		hcl.TraverseRoot{Name: "each"},
+		hcl.TraverseAttr{Name: "key"},
+	})...)
+	to = append(to, &hclwrite.Token{Type: hclsyntax.TokenCBrack, Bytes: []byte("]")})
+	imp.SetAttributeRaw("to", to)
+
+	eachValue := hcl.Traversal{
+		hcl.TraverseRoot{Name: "each"},
+		hcl.TraverseAttr{Name: "value"},
+	}
+	if useIdentity {
+		imp.SetAttributeTraversal("identity", eachValue)
+	} else {
+		imp.SetAttributeTraversal("id", eachValue)
+	}
+}
+
+// groupEntriesByType groups entries by their resource type, preserving the
+// order in which each type was first seen.
+func groupEntriesByType(entries []importEntry) ([]string, map[string][]importEntry) {
+	var types []string
+	grouped := make(map[string][]importEntry)
+	for _, entry := range entries {
+		if _, ok := grouped[entry.resourceType]; !ok {
+			types = append(types, entry.resourceType)
+		}
+		grouped[entry.resourceType] = append(grouped[entry.resourceType], entry)
+	}
+
+	return types, grouped
+}
+
+// identitySchemaFor returns the resource identity schema for resourceType if
+// `--import-identity` is enabled and the provider supports it.
+func identitySchemaFor(providerSchema *tfjson.ProviderSchema, resourceType string) *tfjson.IdentitySchema {
+	if !useImportIdentity || providerSchema == nil {
+		return nil
+	}
+
+	return providerSchema.ResourceIdentitySchemas[resourceType]
+}
@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	importStatusPending  = "pending"
+	importStatusImported = "imported"
+	importStatusSkipped  = "skipped"
+	importStatusFailed   = "failed"
+
+	// importLockTimeout is how long each `terraform import` waits on the state
+	// lock should another Terraform run be holding it.
+	importLockTimeout = "5m"
+)
+
+var (
+	executeImport, importDryRun bool
+	importReportPath            string
+	importParallelism           int
+)
+
+// importEntry is a single resource address and the ID used to import it.
+type importEntry struct {
+	Address string `json:"address"`
+	ID      string `json:"id"`
+
+	resourceType string
+	resourceID   string
+	attributes   map[string]interface{}
+
+	// verifyError is populated by `--verify-imports` when the resource could
+	// not be fetched using the computed ID and unverified when it couldn't be
+	// fetched at all.
+	verifyError, unverified string
+}
+
+// importResult is the outcome of importing an importEntry into state.
+type importResult struct {
+	importEntry
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// planImports marks every entry whose address is already tracked in state as
+// skipped, entries that failed or couldn't be verified as failed and
+// everything else as pending.
+func planImports(entries []importEntry, managed map[string]*tfjson.StateResource) []importResult {
+	results := make([]importResult, len(entries))
+	for i, entry := range entries {
+		results[i] = importResult{importEntry: entry, Status: importStatusPending}
+		if _, ok := managed[entry.Address]; ok {
+			results[i].Status = importStatusSkipped
+			continue
+		}
+		if entry.verifyError != "" {
+			results[i].Status = importStatusFailed
+			results[i].Error = "verification failed: " + entry.verifyError
+		}
+		if entry.unverified != "" {
+			results[i].Status = importStatusFailed
+			results[i].Error = "not verified: " + entry.unverified
+		}
+	}
+
+	return results
+}
+
+// executeImports runs `terraform import` for each entry that isn't already in
+// the state of the working directory, writing a line per resource and an
+// optional JSON report once complete.
+func executeImports(cmd *cobra.Command, tf *tfexec.Terraform, entries []importEntry) {
+	ctx := context.Background()
+
+	state, err := tf.Show(ctx)
+	if err != nil {
+		log.Fatalf("failed to read Terraform state: %s", err)
+	}
+
+	results := planImports(entries, stateResources(state))
+
+	if importDryRun {
+		for _, r := range results {
+			fmt.Fprintf(cmd.OutOrStdout(), "%-8s %s %s\n", r.Status, r.Address, r.ID)
+		}
+		writeImportReport(results)
+		return
+	}
+
+	// every import writes to the same state so each one waits on the state
+	// lock held by the others rather than failing.
+	runImports(results, importParallelism, func(address, id string) error {
+		return tf.Import(ctx, address, id, tfexec.LockTimeout(importLockTimeout))
+	})
+
+	counts := make(map[string]int)
+	for _, r := range results {
+		counts[r.Status]++
+		fmt.Fprintf(cmd.OutOrStdout(), "%-8s %s %s\n", r.Status, r.Address, r.ID)
+		if r.Error != "" {
+			fmt.Fprintf(cmd.OutOrStdout(), "         %s\n", r.Error)
+		}
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "\n%d imported, %d skipped, %d failed\n", counts[importStatusImported], counts[importStatusSkipped], counts[importStatusFailed])
+
+	writeImportReport(results)
+
+	if counts[importStatusFailed] > 0 {
+		log.Fatalf("%d of %d imports failed", counts[importStatusFailed], len(results))
+	}
+}
+
+// runImports imports every pending result using up to parallelism workers and
+// records whether each one was imported or failed.
+func runImports(results []importResult, parallelism int, importResource func(address, id string) error) {
+	pending := make(chan *importResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range pending {
+				log.WithFields(logrus.Fields{
+					"address": r.Address,
+					"id":      r.ID,
+				}).Debug("importing resource")
+
+				if err := importResource(r.Address, r.ID); err != nil {
+					r.Status = importStatusFailed
+					r.Error = err.Error()
+					continue
+				}
+				r.Status = importStatusImported
+			}
+		}()
+	}
+
+	for i := range results {
+		if results[i].Status == importStatusPending {
+			pending <- &results[i]
+		}
+	}
+	close(pending)
+	wg.Wait()
+}
+
+// writeImportReport outputs the import results as JSON to `--import-report`
+// when it has been provided.
+func writeImportReport(results []importResult) {
+	if importReportPath == "" {
+		return
+	}
+
+	report, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		log.Fatalf("failed to build import report: %s", err)
+	}
+
+	if err := os.WriteFile(importReportPath, report, 0o644); err != nil {
+		log.Fatalf("failed to write import report: %s", err)
+	}
+}
+
+// excludeManagedEntries removes the entries for resources that are already
+// tracked in state.
+func excludeManagedEntries(entries []importEntry, resources map[string]*tfjson.StateResource) []importEntry {
+	ids := managedIDs(resources)
+
+	unmanaged := make([]importEntry, 0, len(entries))
+	for _, entry := range entries {
+		if _, ok := resources[entry.Address]; ok {
+			continue
+		}
+		if ids[entry.resourceType][entry.resourceID] {
+			continue
+		}
+		unmanaged = append(unmanaged, entry)
+	}
+
+	return unmanaged
+}
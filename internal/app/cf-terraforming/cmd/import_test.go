@@ -0,0 +1,463 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// legacyResourceTypes returns the resource types handled by the `switch` over
+// `resourceType` inside of the named function. This is how the v4 code path
+// declares what it supports so we inspect the source to keep `generate` and
+// `import` from drifting apart.
+func legacyResourceTypes(t *testing.T, filename, funcName string) []string {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, nil, 0)
+	if err != nil {
+		t.Fatalf("failed to parse %s: %s", filename, err)
+	}
+
+	var types []string
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != funcName {
+			continue
+		}
+
+		ast.Inspect(fn, func(n ast.Node) bool {
+			sw, ok := n.(*ast.SwitchStmt)
+			if !ok {
+				return true
+			}
+			if tag, ok := sw.Tag.(*ast.Ident); !ok || tag.Name != "resourceType" {
+				return true
+			}
+
+			for _, stmt := range sw.Body.List {
+				for _, expr := range stmt.(*ast.CaseClause).List {
+					lit, ok := expr.(*ast.BasicLit)
+					if !ok || lit.Kind != token.STRING {
+						continue
+					}
+					value, _ := strconv.Unquote(lit.Value)
+					if strings.HasPrefix(value, "cloudflare_") {
+						types = append(types, value)
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	sort.Strings(types)
+	return types
+}
+
+func TestImport_LegacyParityWithGenerate(t *testing.T) {
+	generateTypes := legacyResourceTypes(t, "generate.go", "generateResources")
+	importTypes := legacyResourceTypes(t, "import.go", "runImport")
+
+	assert.NotEmpty(t, generateTypes)
+
+	for _, r := range generateTypes {
+		assert.Contains(t, importTypes, r, "%s can be generated but not imported", r)
+	}
+
+	for _, r := range importTypes {
+		assert.Contains(t, resourceImportStringFormats, r, "%s does not have an import format defined", r)
+	}
+}
+
+func TestBuildRawImportAddress_Legacy(t *testing.T) {
+	zoneID = cloudflareTestZoneID
+	accountID = ""
+	defer func() { zoneID = "" }()
+
+	tests := map[string]struct {
+		resourceType string
+		resourceID   string
+		attributes   map[string]interface{}
+		want         string
+	}{
+		"zone scoped":          {resourceType: "cloudflare_record", resourceID: "abc", want: cloudflareTestZoneID + "/abc"},
+		"zone singleton":       {resourceType: "cloudflare_tiered_cache", resourceID: cloudflareTestZoneID, want: cloudflareTestZoneID},
+		"identifier type":      {resourceType: "cloudflare_logpush_job", resourceID: "123", want: "zone/" + cloudflareTestZoneID + "/123"},
+		"parent from response": {resourceType: "cloudflare_waiting_room_event", resourceID: "evt", attributes: map[string]interface{}{"waiting_room_id": "room"}, want: cloudflareTestZoneID + "/room/evt"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, buildRawImportAddress(tc.resourceType, tc.resourceID, "", tc.attributes))
+		})
+	}
+}
+
+func TestBuildRawImportAddress_AccountsOrZones(t *testing.T) {
+	providerVersionString = "5.0.0"
+	defer func() {
+		zoneID, accountID = "", ""
+		providerVersionString = ""
+	}()
+
+	endpoint := resourceToEndpoint["cloudflare_ruleset"]["get"]
+
+	zoneID, accountID = cloudflareTestZoneID, ""
+	assert.Equal(t, "zones/"+cloudflareTestZoneID+"/abc", buildRawImportAddress("cloudflare_ruleset", "abc", endpoint, nil))
+
+	zoneID, accountID = "", cloudflareTestAccountID
+	assert.Equal(t, "accounts/"+cloudflareTestAccountID+"/abc", buildRawImportAddress("cloudflare_ruleset", "abc", endpoint, nil))
+}
+
+func TestPlanImports(t *testing.T) {
+	entries := []importEntry{
+		{Address: "cloudflare_record.terraform_managed_resource_a", ID: cloudflareTestZoneID + "/a"},
+		{Address: "cloudflare_record.terraform_managed_resource_b", ID: cloudflareTestZoneID + "/b"},
+	}
+	managed := map[string]*tfjson.StateResource{
+		"cloudflare_record.terraform_managed_resource_a": {Address: "cloudflare_record.terraform_managed_resource_a"},
+	}
+
+	results := planImports(entries, managed)
+
+	assert.Equal(t, importStatusSkipped, results[0].Status)
+	assert.Equal(t, importStatusPending, results[1].Status)
+	assert.Equal(t, entries[1], results[1].importEntry)
+}
+
+func TestRunImports_Parallelism(t *testing.T) {
+	results := make([]importResult, 8)
+	for i := range results {
+		results[i] = importResult{importEntry: importEntry{Address: fmt.Sprintf("cloudflare_record.terraform_managed_resource_%d", i)}, Status: importStatusPending}
+	}
+	results[0].Status = importStatusSkipped
+
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	runImports(results, 3, func(address, id string) error {
+		mu.Lock()
+		running++
+		maxRunning = max(maxRunning, running)
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+
+		if address == "cloudflare_record.terraform_managed_resource_1" {
+			return errors.New("resource not found")
+		}
+		return nil
+	})
+
+	assert.Equal(t, 3, maxRunning)
+	assert.Equal(t, importStatusSkipped, results[0].Status)
+	assert.Equal(t, importStatusFailed, results[1].Status)
+	assert.Equal(t, "resource not found", results[1].Error)
+	for _, r := range results[2:] {
+		assert.Equal(t, importStatusImported, r.Status)
+	}
+}
+
+func TestVerifyImportEntries_Unverified(t *testing.T) {
+	entries := []importEntry{
+		{Address: "cloudflare_content_scanning.terraform_managed_resource_a", ID: cloudflareTestZoneID, resourceType: "cloudflare_content_scanning", resourceID: "a"},
+	}
+
+	verifyImportEntries(entries)
+
+	failed, unverified := verificationCounts(entries)
+	assert.Equal(t, 0, failed)
+	assert.Equal(t, 1, unverified)
+	assert.Equal(t, "# not verified: no get endpoint is defined for cloudflare_content_scanning\n", string(verificationComment(entries[0]).Bytes()))
+
+	results := planImports(entries, nil)
+	assert.Equal(t, importStatusFailed, results[0].Status)
+	assert.Equal(t, "not verified: no get endpoint is defined for cloudflare_content_scanning", results[0].Error)
+}
+
+func TestResolveGetEndpoint(t *testing.T) {
+	zoneID = cloudflareTestZoneID
+	accountID = ""
+	defer func() { zoneID = "" }()
+
+	tests := map[string]struct {
+		resourceType string
+		resourceID   string
+		attributes   map[string]interface{}
+		want         string
+		wantErr      bool
+	}{
+		"resource ID":          {resourceType: "cloudflare_dns_record", resourceID: "abc", want: "/zones/" + cloudflareTestZoneID + "/dns_records/abc"},
+		"combined scope":       {resourceType: "cloudflare_zero_trust_access_identity_provider", resourceID: "idp", want: "/zones/" + cloudflareTestZoneID + "/access/identity_providers/idp"},
+		"parent from response": {resourceType: "cloudflare_waiting_room_event", resourceID: "evt", attributes: map[string]interface{}{"waiting_room_id": "room"}, want: "/zones/" + cloudflareTestZoneID + "/waiting_rooms/room/events/evt"},
+		"composite ID":         {resourceType: "cloudflare_hostname_tls_setting", resourceID: "ciphers/app.example.com", attributes: map[string]interface{}{"setting_id": "ciphers", "hostname": "app.example.com"}, want: "/zones/" + cloudflareTestZoneID + "/hostnames/settings/ciphers/app.example.com"},
+		"missing parent":       {resourceType: "cloudflare_waiting_room_event", resourceID: "evt", wantErr: true},
+		"no get endpoint":      {resourceType: "cloudflare_content_scanning", resourceID: "abc", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := resolveGetEndpoint(tc.resourceType, tc.resourceID, tc.attributes)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestAppendImportBlock(t *testing.T) {
+	zoneID = cloudflareTestZoneID
+	accountID = ""
+	defer func() { zoneID = "" }()
+
+	identitySchema := &tfjson.IdentitySchema{
+		Attributes: map[string]*tfjson.IdentityAttribute{
+			"id":      {IdentityType: cty.String, RequiredForImport: true},
+			"zone_id": {IdentityType: cty.String, RequiredForImport: true},
+		},
+	}
+
+	tests := map[string]struct {
+		entry    importEntry
+		identity *tfjson.IdentitySchema
+		want     string
+	}{
+		"string ID": {
+			entry: importEntry{Address: "cloudflare_dns_record.terraform_managed_resource_abc", ID: cloudflareTestZoneID + "/abc", resourceType: "cloudflare_dns_record", resourceID: "abc"},
+			want: `import {
+  to = cloudflare_dns_record.terraform_managed_resource_abc
+  id = "` + cloudflareTestZoneID + `/abc"
+}
+`,
+		},
+		"identity": {
+			entry:    importEntry{Address: "cloudflare_dns_record.terraform_managed_resource_abc", ID: cloudflareTestZoneID + "/abc", resourceType: "cloudflare_dns_record", resourceID: "abc"},
+			identity: identitySchema,
+			want: `import {
+  to = cloudflare_dns_record.terraform_managed_resource_abc
+  identity = {
+    id      = "abc"
+    zone_id = "` + cloudflareTestZoneID + `"
+  }
+}
+`,
+		},
+		"unresolvable identity falls back to ID": {
+			entry: importEntry{Address: "cloudflare_dns_record.terraform_managed_resource_abc", ID: cloudflareTestZoneID + "/abc", resourceType: "cloudflare_dns_record", resourceID: "abc"},
+			identity: &tfjson.IdentitySchema{
+				Attributes: map[string]*tfjson.IdentityAttribute{
+					"record_name": {IdentityType: cty.String, RequiredForImport: true},
+				},
+			},
+			want: `import {
+  to = cloudflare_dns_record.terraform_managed_resource_abc
+  id = "` + cloudflareTestZoneID + `/abc"
+}
+`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := hclwrite.NewEmptyFile()
+			appendImportBlock(f.Body(), tc.entry, tc.identity)
+			assert.Equal(t, tc.want, string(hclwrite.Format(f.Bytes())))
+		})
+	}
+}
+
+func TestAppendForEachImportBlock(t *testing.T) {
+	entries := []importEntry{
+		{Address: "cloudflare_dns_record.terraform_managed_resource_b", ID: "zone/b", resourceType: "cloudflare_dns_record", resourceID: "b", attributes: map[string]interface{}{"name": "www.example.com", "type": "A"}},
+		{Address: "cloudflare_dns_record.terraform_managed_resource_a_1", ID: "zone/a:1", resourceType: "cloudflare_dns_record", resourceID: "a:1"},
+	}
+
+	f := hclwrite.NewEmptyFile()
+	appendForEachImportBlock(f.Body(), "cloudflare_dns_record", entries, nil)
+
+	want := `import {
+  for_each = {
+    a_1                 = "zone/a:1"
+    "www.example.com_A" = "zone/b"
+  }
+  to = cloudflare_dns_record.terraform_managed_resource[each.key]
+  id = each.value
+}
+`
+	assert.Equal(t, want, string(hclwrite.Format(f.Bytes())))
+}
+
+func TestAppendForEachImportBlock_MatchesGenerateForEach(t *testing.T) {
+	zoneID = cloudflareTestZoneID
+	providerVersionString = "5.0.0"
+	defer func() {
+		zoneID = ""
+		providerVersionString = ""
+	}()
+
+	// the same records forEachTestConfig was generated from.
+	entries := buildImportEntries("cloudflare_dns_record", []interface{}{
+		map[string]interface{}{"id": "b2", "name": "www.example.com", "type": "A", "content": "192.0.2.2"},
+		map[string]interface{}{"id": "a1", "name": "example.com", "type": "MX", "content": "mx.example.com"},
+	})
+
+	generated, _ := collapseForEach(parseTestHCL(t, forEachTestConfig), "cloudflare_dns_record", "")
+	imports := hclwrite.NewEmptyFile()
+	appendForEachImportBlock(imports.Body(), "cloudflare_dns_record", entries, nil)
+
+	f := parseTestHCL(t, string(generated.Bytes())+"\n"+string(imports.Bytes()))
+
+	generatedIDs := make(map[string]string)
+	importedIDs := make(map[string]string)
+	var address string
+	for _, block := range f.Body().Blocks() {
+		switch block.Type() {
+		case "resource":
+			address = block.Labels()[0] + "." + block.Labels()[1]
+		case "import":
+			to := tokensString(block.Body().GetAttribute("to").Expr().BuildTokens(nil))
+			id, ok := evaluateTokens(block.Body().GetAttribute("id").Expr().BuildTokens(nil))
+			if ok {
+				// a single import of an instance of the collapsed resource.
+				generatedIDs[to] = id.AsString()
+				continue
+			}
+
+			forEach, ok := evaluateTokens(block.Body().GetAttribute("for_each").Expr().BuildTokens(nil))
+			require.True(t, ok)
+			for key, id := range forEach.AsValueMap() {
+				importedIDs[strings.Replace(to, "[each.key]", fmt.Sprintf("[%q]", key), 1)] = id.AsString()
+			}
+		}
+	}
+
+	assert.Equal(t, "cloudflare_dns_record.terraform_managed_resource", address)
+	assert.Len(t, generatedIDs, 2)
+	assert.Equal(t, generatedIDs, importedIDs)
+}
+
+func TestTerraformResourceName(t *testing.T) {
+	assert.Equal(t, "terraform_managed_resource_0da42c8d-2132-a9f7", terraformResourceName("0da42c8d-2132-a9f7"))
+	assert.Equal(t, "terraform_managed_resource_zone_abc_def", terraformResourceName("zone/abc:def"))
+}
+
+func TestBuildImportEntries_ParameterisedSingletons(t *testing.T) {
+	zoneID = cloudflareTestZoneID
+	accountID = ""
+	providerVersionString = "5.0.0"
+	defer func() {
+		zoneID = ""
+		providerVersionString = ""
+	}()
+
+	zoneSettings := []interface{}{
+		map[string]interface{}{"id": "always_online", "value": "on"},
+		map[string]interface{}{"id": "min_tls_version", "value": "1.2"},
+	}
+	processCustomCasesV5(&zoneSettings, "cloudflare_zone_setting", "")
+
+	hostnameSettings := []interface{}{
+		map[string]interface{}{"hostname": "app.example.com", "value": "1.2"},
+		map[string]interface{}{"hostname": "api.example.com", "value": "1.3"},
+	}
+	processCustomCasesV5(&hostnameSettings, "cloudflare_hostname_tls_setting", "min_tls_version")
+
+	tests := map[string]struct {
+		resourceType string
+		data         []interface{}
+		want         []importEntry
+	}{
+		"zone setting": {
+			resourceType: "cloudflare_zone_setting",
+			data:         zoneSettings,
+			want: []importEntry{
+				{Address: "cloudflare_zone_setting.terraform_managed_resource_always_online", ID: cloudflareTestZoneID + "/always_online"},
+				{Address: "cloudflare_zone_setting.terraform_managed_resource_min_tls_version", ID: cloudflareTestZoneID + "/min_tls_version"},
+			},
+		},
+		"hostname tls setting": {
+			resourceType: "cloudflare_hostname_tls_setting",
+			data:         hostnameSettings,
+			want: []importEntry{
+				{Address: "cloudflare_hostname_tls_setting.terraform_managed_resource_min_tls_version_app_example_com", ID: cloudflareTestZoneID + "/min_tls_version/app.example.com"},
+				{Address: "cloudflare_hostname_tls_setting.terraform_managed_resource_min_tls_version_api_example_com", ID: cloudflareTestZoneID + "/min_tls_version/api.example.com"},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			entries := buildImportEntries(tc.resourceType, tc.data)
+			assert.Len(t, entries, len(tc.want))
+			for i, want := range tc.want {
+				assert.Equal(t, want.Address, entries[i].Address)
+				assert.Equal(t, want.ID, entries[i].ID)
+			}
+		})
+	}
+}
+
+func TestGetResourceMappings(t *testing.T) {
+	resourceIDFlags = []string{"cloudflare_zone_setting=always_online", "min_tls_version", "cloudflare_hostname_tls_setting=ciphers"}
+	defer func() { resourceIDFlags = nil }()
+
+	assert.Equal(t, map[string][]string{
+		"cloudflare_zone_setting":         {"always_online", "min_tls_version"},
+		"cloudflare_hostname_tls_setting": {"ciphers"},
+	}, getResourceMappings())
+}
+
+func TestExcludeManaged(t *testing.T) {
+	resources := map[string]*tfjson.StateResource{
+		"cloudflare_dns_record.www": {
+			Address:         "cloudflare_dns_record.www",
+			Type:            "cloudflare_dns_record",
+			AttributeValues: map[string]interface{}{"id": "a"},
+		},
+		"cloudflare_dns_record.terraform_managed_resource_b": {
+			Address:         "cloudflare_dns_record.terraform_managed_resource_b",
+			Type:            "cloudflare_dns_record",
+			AttributeValues: map[string]interface{}{"id": "renamed"},
+		},
+		"cloudflare_ruleset.c": {
+			Address:         "cloudflare_ruleset.c",
+			Type:            "cloudflare_ruleset",
+			AttributeValues: map[string]interface{}{"id": "c"},
+		},
+	}
+
+	data := []interface{}{
+		map[string]interface{}{"id": "a"},
+		map[string]interface{}{"id": "b"},
+		map[string]interface{}{"id": "c"},
+	}
+	assert.Equal(t, []interface{}{map[string]interface{}{"id": "c"}}, excludeManagedResources("cloudflare_dns_record", data, resources))
+
+	entries := []importEntry{
+		{Address: "cloudflare_dns_record.terraform_managed_resource_a", resourceType: "cloudflare_dns_record", resourceID: "a"},
+		{Address: "cloudflare_dns_record.terraform_managed_resource_b", resourceType: "cloudflare_dns_record", resourceID: "b"},
+		{Address: "cloudflare_dns_record.terraform_managed_resource_c", resourceType: "cloudflare_dns_record", resourceID: "c"},
+	}
+	assert.Equal(t, entries[2:], excludeManagedEntries(entries, resources))
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/sirupsen/logrus"
+)
+
+var endpointPlaceholder = regexp.MustCompile(`{[a-z0-9_]*}`)
+
+// resolveGetEndpoint builds the `get` endpoint for a single resource. The
+// segments of the resource ID fill the trailing placeholders, with any left
+// over (such as the hostname of a composite `setting/hostname` ID) appended to
+// the path. Parent identifiers (such as `{waiting_room_id}`) are populated
+// from the API result.
+func resolveGetEndpoint(resourceType, resourceID string, attributes map[string]interface{}) (string, error) {
+	endpoint := resourceToEndpoint[resourceType]["get"]
+	if endpoint == "" {
+		return "", fmt.Errorf("no get endpoint is defined for %s", resourceType)
+	}
+
+	endpoint = resolveEndpointScope(endpoint)
+
+	placeholders := endpointPlaceholder.FindAllString(endpoint, -1)
+	segments := strings.Split(resourceID, "/")
+	fromID := min(len(segments), len(placeholders))
+
+	for i, placeholder := range placeholders {
+		value := ""
+		if i >= len(placeholders)-fromID {
+			value = segments[i-(len(placeholders)-fromID)]
+		} else if v, ok := attributes[strings.Trim(placeholder, "{}")]; ok && v != nil {
+			value = fmt.Sprintf("%v", v)
+		}
+
+		if value == "" {
+			return "", fmt.Errorf("unable to resolve %s in %s", placeholder, endpoint)
+		}
+
+		endpoint = strings.Replace(endpoint, placeholder, url.PathEscape(value), 1)
+	}
+
+	for _, segment := range segments[fromID:] {
+		endpoint += "/" + url.PathEscape(segment)
+	}
+
+	return endpoint, nil
+}
+
+// verifyImportEntries fetches every entry from its `get` endpoint to confirm
+// the computed import ID refers to a resource that exists. Failures are
+// recorded on the entry rather than halting so that all problems are reported
+// at once.
+func verifyImportEntries(entries []importEntry) {
+	for i := range entries {
+		entry := &entries[i]
+
+		endpoint, err := resolveGetEndpoint(entry.resourceType, entry.resourceID, entry.attributes)
+		if err != nil {
+			entry.unverified = err.Error()
+			log.WithFields(logrus.Fields{
+				"address": entry.Address,
+			}).Warnf("unable to verify import ID: %s", err)
+			continue
+		}
+
+		var result *http.Response
+		err = api.Get(context.Background(), endpoint, nil, &result)
+		if err != nil {
+			if isNotFound(err) {
+				entry.verifyError = fmt.Sprintf("%s was not found", endpoint)
+			} else {
+				entry.verifyError = err.Error()
+			}
+
+			log.WithFields(logrus.Fields{
+				"address":  entry.Address,
+				"id":       entry.ID,
+				"endpoint": endpoint,
+			}).Warn("import ID failed verification")
+			continue
+		}
+		result.Body.Close()
+
+		log.WithFields(logrus.Fields{
+			"address":  entry.Address,
+			"endpoint": endpoint,
+		}).Debug("verified import ID")
+	}
+}
+
+// verificationCounts returns how many entries failed `--verify-imports` and
+// how many couldn't be verified at all, such as types without a `get`
+// endpoint.
+func verificationCounts(entries []importEntry) (failed, unverified int) {
+	for _, entry := range entries {
+		switch {
+		case entry.verifyError != "":
+			failed++
+		case entry.unverified != "":
+			unverified++
+		}
+	}
+
+	return failed, unverified
+}
+
+// verificationComment builds a HCL comment flagging an entry that failed or
+// couldn't be verified so that it stands out in the output. It is empty for
+// entries that verified or when `--verify-imports` wasn't used.
+func verificationComment(entry importEntry) hclwrite.Tokens {
+	var comment string
+	switch {
+	case entry.verifyError != "":
+		comment = "# verification failed: " + entry.verifyError
+	case entry.unverified != "":
+		comment = "# not verified: " + entry.unverified
+	default:
+		return nil
+	}
+
+	return hclwrite.Tokens{{
+		Type:  hclsyntax.TokenComment,
+		Bytes: []byte(comment + "\n"),
+	}}
+}
@@ -0,0 +1,401 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/spf13/cobra"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const (
+	mergeActionAdded     = "added"
+	mergeActionUpdated   = "updated"
+	mergeActionUnchanged = "unchanged"
+)
+
+// mergeResult is what happened to a single generated resource when merging it
+// into existing configuration.
+type mergeResult struct {
+	Address    string
+	File       string
+	Action     string
+	Attributes []string
+}
+
+// mergeInto merges generated configuration into `--merge-into` and outputs
+// a summary of the changes.
+func mergeInto(cmd *cobra.Command, generated []byte) {
+	results, err := mergeGeneratedConfig(mergeIntoDir, generated)
+	if err != nil {
+		log.Fatalf("failed to merge generated configuration into %s: %s", mergeIntoDir, err)
+	}
+
+	writeMergeSummary(cmd.OutOrStderr(), results)
+}
+
+// mergeFile is an existing configuration file being merged into.
+type mergeFile struct {
+	file    *hclwrite.File
+	changed bool
+	created bool
+}
+
+// mergeGeneratedConfig merges generated configuration into the `.tf` files of
+// dir. Resources that already exist at the same address only have the
+// attributes that differ updated, leaving comments, formatting and any other
+// attributes or blocks alone. New resources, and their import blocks, are
+// appended to the file that already holds resources of the same type.
+// Variables that aren't declared yet are appended to variables.tf and locals
+// are updated where they're already defined or appended to locals.tf.
+func mergeGeneratedConfig(dir string, generated []byte) ([]mergeResult, error) {
+	gen, diags := hclwrite.ParseConfig(generated, "generated.tf", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to parse generated configuration: %s", diags.Error())
+	}
+
+	files, err := loadMergeFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resources := make(map[string]string)
+	blocks := make(map[string]*hclwrite.Block)
+	imports := make(map[string]bool)
+	variables := make(map[string]bool)
+	locals := make(map[string]*hclwrite.Body)
+	localFiles := make(map[string]string)
+	for _, name := range names {
+		for _, block := range files[name].file.Body().Blocks() {
+			switch block.Type() {
+			case "resource":
+				address := strings.Join(block.Labels(), ".")
+				resources[address] = name
+				blocks[address] = block
+			case "import":
+				if to := block.Body().GetAttribute("to"); to != nil {
+					imports[tokensString(to.Expr().BuildTokens(nil))] = true
+				}
+			case "variable":
+				if len(block.Labels()) == 1 {
+					variables[block.Labels()[0]] = true
+				}
+			case "locals":
+				for local := range block.Body().Attributes() {
+					locals[local] = block.Body()
+					localFiles[local] = name
+				}
+			}
+		}
+	}
+
+	var results []mergeResult
+	lastFile := ""
+	for _, block := range gen.Body().Blocks() {
+		switch block.Type() {
+		case "resource":
+			address := strings.Join(block.Labels(), ".")
+			if name, ok := resources[address]; ok {
+				changed := mergeBody(blocks[address].Body(), block.Body(), "")
+				result := mergeResult{Address: address, File: name, Action: mergeActionUnchanged}
+				if len(changed) > 0 {
+					files[name].changed = true
+					result.Action = mergeActionUpdated
+					result.Attributes = changed
+				}
+				results = append(results, result)
+				lastFile = name
+				continue
+			}
+
+			name := mergeTargetFile(files, names, block.Labels()[0])
+			names = appendToMergeFile(files, names, name, block)
+			resources[address] = name
+			blocks[address] = block
+			results = append(results, mergeResult{Address: address, File: name, Action: mergeActionAdded})
+			lastFile = name
+		case "import":
+			to := block.Body().GetAttribute("to")
+			if to == nil || lastFile == "" {
+				continue
+			}
+			key := tokensString(to.Expr().BuildTokens(nil))
+			if imports[key] {
+				continue
+			}
+			imports[key] = true
+			appendMergedBlock(files[lastFile], block)
+		case "variable":
+			if len(block.Labels()) != 1 || variables[block.Labels()[0]] {
+				continue
+			}
+			variables[block.Labels()[0]] = true
+			names = appendToMergeFile(files, names, variablesFilename, block)
+		case "locals":
+			// locals that already exist are updated where they're defined.
+			added := false
+			attrs := block.Body().Attributes()
+			for _, local := range sortedAttributeNames(attrs) {
+				body, ok := locals[local]
+				if !ok {
+					added = true
+					continue
+				}
+				want := attrs[local].Expr().BuildTokens(nil)
+				if !expressionsEqual(body.GetAttribute(local).Expr().BuildTokens(nil), want) {
+					body.SetAttributeRaw(local, want)
+					files[localFiles[local]].changed = true
+				}
+				block.Body().RemoveAttribute(local)
+			}
+			if added {
+				names = appendToMergeFile(files, names, "locals.tf", block)
+			}
+		}
+	}
+
+	for _, name := range names {
+		f := files[name]
+		if !f.changed {
+			continue
+		}
+
+		b := f.file.Bytes()
+		if f.created {
+			b = hclwrite.Format(b)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), b, 0o644); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// loadMergeFiles parses every `.tf` file in the top level of dir.
+func loadMergeFiles(dir string) (map[string]*mergeFile, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]*mergeFile, len(matches))
+	for _, path := range matches {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		f, diags := hclwrite.ParseConfig(b, path, hcl.InitialPos)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("failed to parse %s: %s", path, diags.Error())
+		}
+		files[filepath.Base(path)] = &mergeFile{file: f}
+	}
+
+	return files, nil
+}
+
+// mergeTargetFile picks the file a new resource is appended to: whichever
+// already has the most resources of the same type, or a new file named after
+// the type.
+func mergeTargetFile(files map[string]*mergeFile, names []string, resourceType string) string {
+	target, most := "", 0
+	for _, name := range names {
+		count := 0
+		for _, block := range files[name].file.Body().Blocks() {
+			if block.Type() == "resource" && len(block.Labels()) > 0 && block.Labels()[0] == resourceType {
+				count++
+			}
+		}
+		if count > most {
+			target, most = name, count
+		}
+	}
+
+	if target == "" {
+		target = strings.TrimPrefix(resourceType, "cloudflare_") + ".tf"
+	}
+
+	return target
+}
+
+// appendToMergeFile appends a block to the named file, creating it if it
+// doesn't exist yet, and returns the updated list of file names.
+func appendToMergeFile(files map[string]*mergeFile, names []string, name string, block *hclwrite.Block) []string {
+	if files[name] == nil {
+		files[name] = &mergeFile{file: hclwrite.NewEmptyFile(), created: true}
+		names = append(names, name)
+		sort.Strings(names)
+	}
+	appendMergedBlock(files[name], block)
+
+	return names
+}
+
+// appendMergedBlock appends a block to the end of a file, separated from
+// whatever comes before it by a blank line.
+func appendMergedBlock(f *mergeFile, block *hclwrite.Block) {
+	body := f.file.Body()
+	if len(body.Attributes()) > 0 || len(body.Blocks()) > 0 {
+		if b := f.file.Bytes(); len(b) > 0 && !bytes.HasSuffix(b, []byte("\n")) {
+			body.AppendNewline()
+		}
+		body.AppendNewline()
+	}
+	body.AppendBlock(block)
+	f.changed = true
+}
+
+// mergeBody updates the attributes in existing that differ from generated and
+// returns their names. Attributes only present in existing, or set using
+// references, are kept. Nested
+// blocks are merged in order when both sides have the same number of them,
+// otherwise the existing blocks of that type are replaced.
+func mergeBody(existing, generated *hclwrite.Body, prefix string) []string {
+	var changed []string
+
+	attrs := generated.Attributes()
+	for _, name := range sortedAttributeNames(attrs) {
+		want := attrs[name].Expr().BuildTokens(nil)
+		if have := existing.GetAttribute(name); have != nil {
+			// Attributes using references, such as `var.zone_id`, are
+			// hand-made edits and are kept.
+			if _, ok := evaluateTokens(have.Expr().BuildTokens(nil)); !ok {
+				continue
+			}
+			if expressionsEqual(have.Expr().BuildTokens(nil), want) {
+				continue
+			}
+		}
+		existing.SetAttributeRaw(name, want)
+		changed = append(changed, prefix+name)
+	}
+
+	generatedBlocks := blocksByType(generated.Blocks())
+	existingBlocks := blocksByType(existing.Blocks())
+
+	blockTypes := make([]string, 0, len(generatedBlocks))
+	for t := range generatedBlocks {
+		blockTypes = append(blockTypes, t)
+	}
+	sort.Strings(blockTypes)
+
+	for _, t := range blockTypes {
+		want, have := generatedBlocks[t], existingBlocks[t]
+		if len(want) == len(have) {
+			for i := range want {
+				p := prefix + t + "."
+				if len(want) > 1 {
+					p = fmt.Sprintf("%s%s[%d].", prefix, t, i)
+				}
+				changed = append(changed, mergeBody(have[i].Body(), want[i].Body(), p)...)
+			}
+			continue
+		}
+
+		for _, b := range have {
+			existing.RemoveBlock(b)
+		}
+		for _, b := range want {
+			existing.AppendBlock(b)
+		}
+		changed = append(changed, prefix+t)
+	}
+
+	return changed
+}
+
+func sortedAttributeNames(attrs map[string]*hclwrite.Attribute) []string {
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// blocksByType groups blocks by their type, keeping their order.
+func blocksByType(blocks []*hclwrite.Block) map[string][]*hclwrite.Block {
+	grouped := make(map[string][]*hclwrite.Block)
+	for _, b := range blocks {
+		grouped[b.Type()] = append(grouped[b.Type()], b)
+	}
+
+	return grouped
+}
+
+// expressionsEqual compares two expressions by value when they can be
+// evaluated, so that differences in formatting aren't reported, and by their
+// tokens otherwise.
+func expressionsEqual(a, b hclwrite.Tokens) bool {
+	av, aok := evaluateTokens(a)
+	bv, bok := evaluateTokens(b)
+	if aok && bok {
+		return av.RawEquals(bv)
+	}
+
+	return tokensString(a) == tokensString(b)
+}
+
+// evaluateTokens evaluates an expression without any variables.
+func evaluateTokens(tokens hclwrite.Tokens) (cty.Value, bool) {
+	expr, diags := hclsyntax.ParseExpression(tokens.Bytes(), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return cty.NilVal, false
+	}
+
+	v, diags := expr.Value(driftEvalContext)
+	if diags.HasErrors() || !v.IsWhollyKnown() {
+		return cty.NilVal, false
+	}
+
+	return v, true
+}
+
+// tokensString renders tokens without any whitespace.
+func tokensString(tokens hclwrite.Tokens) string {
+	var sb strings.Builder
+	for _, t := range tokens {
+		if t.Type == hclsyntax.TokenNewline {
+			continue
+		}
+		sb.Write(t.Bytes)
+	}
+
+	return sb.String()
+}
+
+// writeMergeSummary outputs what was added and updated by a merge.
+func writeMergeSummary(w io.Writer, results []mergeResult) {
+	added, updated, unchanged := 0, 0, 0
+	for _, r := range results {
+		switch r.Action {
+		case mergeActionAdded:
+			added++
+			fmt.Fprintf(w, "+ %s (%s)\n", r.Address, r.File)
+		case mergeActionUpdated:
+			updated++
+			fmt.Fprintf(w, "~ %s (%s): %s\n", r.Address, r.File, strings.Join(r.Attributes, ", "))
+		default:
+			unchanged++
+		}
+	}
+
+	fmt.Fprintf(w, "%d added, %d updated, %d unchanged\n", added, updated, unchanged)
+}
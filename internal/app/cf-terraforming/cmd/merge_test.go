@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mergeTestExisting = `# DNS records for example.com
+variable "zone_id" {}
+
+resource "cloudflare_dns_record" "www" {
+  zone_id = var.zone_id # managed by hand
+  name    = "www"
+  content = "192.0.2.1" # origin
+  ttl     = 300
+  comment = "keep me"
+}
+`
+
+const mergeTestGenerated = `resource "cloudflare_dns_record" "www" {
+  content = "192.0.2.10"
+  name    = "www"
+  ttl     = 300
+  zone_id = "0da42c8d2132a9ddaf714f9e7c920711"
+}
+
+resource "cloudflare_dns_record" "terraform_managed_resource_abc" {
+  content = "192.0.2.2"
+  name    = "api"
+  zone_id = "0da42c8d2132a9ddaf714f9e7c920711"
+}
+
+import {
+  to = cloudflare_dns_record.terraform_managed_resource_abc
+  id = "0da42c8d2132a9ddaf714f9e7c920711/abc"
+}
+
+resource "cloudflare_list" "terraform_managed_resource_def" {
+  account_id = "f037e56e89293a057740de681ac9abbe"
+  kind       = "ip"
+  name       = "allowed"
+}
+`
+
+func TestMergeGeneratedConfig(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "dns.tf"), []byte(mergeTestExisting), 0o644))
+
+	results, err := mergeGeneratedConfig(dir, []byte(mergeTestGenerated))
+	require.NoError(t, err)
+
+	assert.Equal(t, []mergeResult{
+		{Address: "cloudflare_dns_record.www", File: "dns.tf", Action: mergeActionUpdated, Attributes: []string{"content"}},
+		{Address: "cloudflare_dns_record.terraform_managed_resource_abc", File: "dns.tf", Action: mergeActionAdded},
+		{Address: "cloudflare_list.terraform_managed_resource_def", File: "list.tf", Action: mergeActionAdded},
+	}, results)
+
+	dns, err := os.ReadFile(filepath.Join(dir, "dns.tf"))
+	require.NoError(t, err)
+	assert.Equal(t, `# DNS records for example.com
+variable "zone_id" {}
+
+resource "cloudflare_dns_record" "www" {
+  zone_id = var.zone_id # managed by hand
+  name    = "www"
+  content = "192.0.2.10" # origin
+  ttl     = 300
+  comment = "keep me"
+}
+
+resource "cloudflare_dns_record" "terraform_managed_resource_abc" {
+  content = "192.0.2.2"
+  name    = "api"
+  zone_id = "0da42c8d2132a9ddaf714f9e7c920711"
+}
+
+import {
+  to = cloudflare_dns_record.terraform_managed_resource_abc
+  id = "0da42c8d2132a9ddaf714f9e7c920711/abc"
+}
+`, string(dns))
+
+	list, err := os.ReadFile(filepath.Join(dir, "list.tf"))
+	require.NoError(t, err)
+	assert.Equal(t, `resource "cloudflare_list" "terraform_managed_resource_def" {
+  account_id = "f037e56e89293a057740de681ac9abbe"
+  kind       = "ip"
+  name       = "allowed"
+}
+`, string(list))
+
+	// merging the same output again is a no-op.
+	results, err = mergeGeneratedConfig(dir, []byte(mergeTestGenerated))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	writeMergeSummary(&buf, results)
+	assert.Equal(t, "0 added, 0 updated, 3 unchanged\n", buf.String())
+}
+
+func TestMergeBody_NestedBlocks(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte(`resource "cloudflare_ruleset" "a" {
+  name = "a"
+
+  rules {
+    action = "block"
+  }
+}
+`), 0o644))
+
+	results, err := mergeGeneratedConfig(dir, []byte(`resource "cloudflare_ruleset" "a" {
+  name = "a"
+
+  rules {
+    action = "skip"
+  }
+}
+`))
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, []string{"rules.action"}, results[0].Attributes)
+}
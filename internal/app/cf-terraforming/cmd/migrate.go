@@ -0,0 +1,410 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/zclconf/go-cty/cty"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:    "migrate [v4 working directory]",
+	Short:  "Output v5 configuration and moved blocks for resources managed with v4 of the provider",
+	Args:   cobra.MaximumNArgs(1),
+	Run:    runMigrate(),
+	PreRun: sharedPreRun,
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+
+	migrateCmd.Flags().BoolVar(&useStateRm, "state-rm", false, "Output terraform state rm commands instead of removed blocks for the replaced v4 resources. Removed blocks are only compatible with Terraform 1.7+")
+}
+
+// migrationTarget is the v5 successor of a v4 resource type.
+type migrationTarget struct {
+	resourceType string
+	// moved is set when the v5 provider can move state from the v4 type so a
+	// `moved` block can be used. Otherwise the resource is removed from state
+	// and imported again.
+	moved bool
+}
+
+// migrationTypes maps v4 resource types that were renamed in v5 to their
+// successor. Types that kept their name in v5 are migrated in place.
+var migrationTypes = map[string]migrationTarget{
+	"cloudflare_record":                        {resourceType: "cloudflare_dns_record", moved: true},
+	"cloudflare_access_application":            {resourceType: "cloudflare_zero_trust_access_application", moved: true},
+	"cloudflare_access_group":                  {resourceType: "cloudflare_zero_trust_access_group", moved: true},
+	"cloudflare_access_identity_provider":      {resourceType: "cloudflare_zero_trust_access_identity_provider", moved: true},
+	"cloudflare_access_mutual_tls_certificate": {resourceType: "cloudflare_zero_trust_access_mtls_certificate"},
+	"cloudflare_access_policy":                 {resourceType: "cloudflare_zero_trust_access_policy"},
+	"cloudflare_access_service_token":          {resourceType: "cloudflare_zero_trust_access_service_token", moved: true},
+	"cloudflare_device_posture_rule":           {resourceType: "cloudflare_zero_trust_device_posture_rule"},
+	"cloudflare_ip_list":                       {resourceType: "cloudflare_list"},
+	"cloudflare_managed_headers":               {resourceType: "cloudflare_managed_transforms"},
+	"cloudflare_teams_account":                 {resourceType: "cloudflare_zero_trust_gateway_settings"},
+	"cloudflare_teams_list":                    {resourceType: "cloudflare_zero_trust_list", moved: true},
+	"cloudflare_teams_location":                {resourceType: "cloudflare_zero_trust_dns_location"},
+	"cloudflare_teams_rule":                    {resourceType: "cloudflare_zero_trust_gateway_policy", moved: true},
+	"cloudflare_tunnel":                        {resourceType: "cloudflare_zero_trust_tunnel_cloudflared", moved: true},
+	"cloudflare_tunnel_config":                 {resourceType: "cloudflare_zero_trust_tunnel_cloudflared_config"},
+	"cloudflare_worker_route":                  {resourceType: "cloudflare_workers_route", moved: true},
+}
+
+// migrationConversions maps v4 resource types that were replaced by a
+// different set of resources in v5 to the `convert` subcommand that produces
+// them.
+var migrationConversions = map[string]string{
+	"cloudflare_firewall_rule":          "waf",
+	"cloudflare_waf_override":           "waf",
+	"cloudflare_zone_settings_override": "zone-settings",
+}
+
+// migration is a resource in v4 state and the v5 resource replacing it.
+type migration struct {
+	From   *tfjson.StateResource
+	ToType string
+	ToName string
+	Moved  bool
+}
+
+// ToAddress is the address of the v5 resource.
+func (m migration) ToAddress() string {
+	return m.ToType + "." + m.ToName
+}
+
+// migrationFailure is a resource in v4 state that couldn't be migrated.
+type migrationFailure struct {
+	Address string
+	Reason  string
+}
+
+func runMigrate() func(cmd *cobra.Command, args []string) {
+	return func(cmd *cobra.Command, args []string) {
+		zoneID = viper.GetString("zone")
+		accountID = viper.GetString("account")
+
+		dir := "."
+		if len(args) > 0 {
+			dir = args[0]
+		}
+
+		tf, registryPath, cleanup := initTerraform()
+		defer cleanup()
+
+		if !strings.HasPrefix(providerVersionString, "5") {
+			log.Fatalf("migrating resources requires v5 of the provider, found %s", providerVersionString)
+		}
+
+		ps, err := tf.ProvidersSchema(context.Background())
+		if err != nil {
+			log.Fatal("failed to read provider schema", err)
+		}
+
+		s := ps.Schemas[registryPath]
+		if s == nil {
+			log.Fatal("failed to detect provider installation")
+		}
+
+		state, err := readStateFrom(tf, dir)
+		if err != nil {
+			log.Fatalf("failed to read Terraform state from %s: %s", dir, err)
+		}
+
+		// the v4 configuration is only used to point out the blocks that
+		// need replacing.
+		var config []configResource
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			if config, err = parseConfigResources(dir); err != nil {
+				log.Fatalf("failed to parse Terraform configuration: %s", err)
+			}
+		}
+
+		migrations, unsupported := planMigration(stateResources(state))
+		failures := append(unsupported, generateMigration(cmd.OutOrStdout(), s, migrations, fetchMigrationData)...)
+
+		writeMigrationSummary(cmd.OutOrStderr(), migrations, failures, config)
+		if len(failures) > 0 {
+			log.Fatalf("%d of %d resources couldn't be migrated", len(failures), len(migrations)+len(unsupported))
+		}
+	}
+}
+
+// planMigration works out the v5 resource replacing each Cloudflare resource
+// in v4 state. Resources keep their name so that references only need their
+// type updating.
+func planMigration(resources map[string]*tfjson.StateResource) ([]migration, []migrationFailure) {
+	addresses := make([]string, 0, len(resources))
+	for address, r := range resources {
+		if strings.HasPrefix(r.Type, "cloudflare_") {
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Strings(addresses)
+
+	var migrations []migration
+	var failures []migrationFailure
+	names := make(map[string]bool)
+	for _, address := range addresses {
+		r := resources[address]
+		if strings.HasPrefix(address, "module.") {
+			failures = append(failures, migrationFailure{Address: address, Reason: "resources in child modules must be migrated from within the module"})
+			continue
+		}
+
+		// moving between instances of the same type is always supported.
+		m := migration{From: r, ToType: r.Type, Moved: true}
+		if target, ok := migrationTypes[r.Type]; ok {
+			m.ToType = target.resourceType
+			m.Moved = target.moved
+		} else if conversion, ok := migrationConversions[r.Type]; ok {
+			failures = append(failures, migrationFailure{Address: address, Reason: fmt.Sprintf("%s was replaced in v5, use \"cf-terraforming convert %s\"", r.Type, conversion)})
+			continue
+		} else if _, ok := resourceToEndpoint[r.Type]; !ok {
+			failures = append(failures, migrationFailure{Address: address, Reason: "there is no v5 equivalent of " + r.Type})
+			continue
+		}
+
+		// v4 types that were merged in v5, such as `cloudflare_ip_list` and
+		// `cloudflare_list`, can clash.
+		m.ToName = stateResourceName(r)
+		if names[m.ToAddress()] {
+			id, _ := r.AttributeValues["id"].(string)
+			m.ToName = terraformResourceName(id)
+		}
+		names[m.ToAddress()] = true
+
+		migrations = append(migrations, m)
+	}
+
+	return migrations, failures
+}
+
+// migrationScope returns the account or zone a resource in v4 state belongs
+// to, if it's known.
+func migrationScope(r *tfjson.StateResource) (account, zone string, ok bool) {
+	if zone, _ := r.AttributeValues["zone_id"].(string); zone != "" {
+		return "", zone, true
+	}
+	if account, _ := r.AttributeValues["account_id"].(string); account != "" {
+		return account, "", true
+	}
+
+	return "", "", false
+}
+
+// fetchMigrationData fetches a resource from the v5 `get` endpoint using the
+// ID and parent identifiers in v4 state.
+func fetchMigrationData(m migration) (map[string]interface{}, error) {
+	id, _ := m.From.AttributeValues["id"].(string)
+	endpoint, err := resolveGetEndpoint(m.ToType, id, m.From.AttributeValues)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := fetchEndpoint(m.ToType, endpoint, "")
+	if err != nil {
+		if isNotFound(err) {
+			return nil, fmt.Errorf("%s was not found", endpoint)
+		}
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("%s didn't return a result", endpoint)
+	}
+
+	data, ok := results[0].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s returned an unexpected result", endpoint)
+	}
+
+	return data, nil
+}
+
+// generateMigration writes the v5 configuration for each migration using the
+// data returned by fetch, along with the blocks that move the v4 state over.
+func generateMigration(w io.Writer, s *tfjson.ProviderSchema, migrations []migration, fetch func(migration) (map[string]interface{}, error)) []migrationFailure {
+	sorted := make([]migration, len(migrations))
+	copy(sorted, migrations)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ToAddress() < sorted[j].ToAddress() })
+
+	// endpoints and import IDs are scoped to the account or zone of each
+	// resource, or the one targeted when it isn't known.
+	targetAccount, targetZone := accountID, zoneID
+	defer func() {
+		accountID, zoneID = targetAccount, targetZone
+	}()
+
+	var failures []migrationFailure
+	var placeholders []generatedVariable
+	f := hclwrite.NewEmptyFile()
+	body := f.Body()
+	for _, m := range sorted {
+		accountID, zoneID = targetAccount, targetZone
+		if account, zone, ok := migrationScope(m.From); ok {
+			accountID, zoneID = account, zone
+		}
+
+		r := s.ResourceSchemas[m.ToType]
+		if r == nil {
+			failures = append(failures, migrationFailure{Address: m.From.Address, Reason: m.ToType + " is not in the provider schema"})
+			continue
+		}
+
+		structData, err := fetch(m)
+		if err != nil {
+			failures = append(failures, migrationFailure{Address: m.From.Address, Reason: err.Error()})
+			continue
+		}
+
+		attributes := make(map[string]interface{}, len(structData))
+		for k, v := range structData {
+			attributes[k] = v
+		}
+		id := resourceIDFromData(attributes)
+
+		resource := body.AppendNewBlock("resource", []string{m.ToType, m.ToName}).Body()
+		placeholders = append(placeholders, renderResource(r, m.ToType, m.ToName, structData, resource)...)
+		body.AppendNewline()
+
+		appendMigrationBlocks(body, m, buildRawImportAddress(m.ToType, id, resourceToEndpoint[m.ToType]["get"], attributes))
+	}
+
+	for _, v := range placeholders {
+		appendVariableBlock(body, v)
+		body.AppendNewline()
+	}
+
+	for _, resourceType := range migrationTypesIn(sorted) {
+		postProcess(f, resourceType)
+	}
+	fmt.Fprint(w, string(hclwrite.Format(f.Bytes())))
+
+	return failures
+}
+
+// migrationTypesIn returns the v5 resource types being migrated to.
+func migrationTypesIn(migrations []migration) []string {
+	var types []string
+	for _, m := range migrations {
+		if !contains(types, m.ToType) {
+			types = append(types, m.ToType)
+		}
+	}
+
+	return types
+}
+
+// appendMigrationBlocks moves the v4 state of a resource to its v5 address.
+// Resources that kept their type are upgraded by the provider and don't need
+// moving. When the provider can't move state between the types, the v4
+// resource is removed from state without being destroyed and imported again.
+func appendMigrationBlocks(body *hclwrite.Body, m migration, importID string) {
+	from := m.From.Address
+	if from == m.ToAddress() {
+		return
+	}
+
+	if m.Moved {
+		moved := body.AppendNewBlock("moved", nil).Body()
+		moved.SetAttributeRaw("from", stateAddressTokens(from))
+		moved.SetAttributeTraversal("to", addressTraversal(m.ToAddress()))
+		body.AppendNewline()
+		return
+	}
+
+	appendRemovedBlock(body, from, m.From.Index != nil)
+
+	imp := body.AppendNewBlock("import", nil).Body()
+	imp.SetAttributeTraversal("to", addressTraversal(m.ToAddress()))
+	imp.SetAttributeValue("id", cty.StringVal(importID))
+	body.AppendNewline()
+}
+
+// appendRemovedBlock appends a removed block that forgets address without
+// destroying it. A comment with the state rm command is used instead when
+// using `--state-rm` or for a single instance, which removed blocks can't
+// target.
+func appendRemovedBlock(body *hclwrite.Body, address string, instance bool) {
+	if useStateRm || instance {
+		body.AppendUnstructuredTokens(hclwrite.Tokens{{
+			Type:  hclsyntax.TokenComment,
+			Bytes: []byte(fmt.Sprintf("# %s must be removed from state: %s\n", address, stateRmCommand(address))),
+		}})
+		return
+	}
+
+	removed := body.AppendNewBlock("removed", nil).Body()
+	removed.SetAttributeTraversal("from", addressTraversal(address))
+	lifecycle := removed.AppendNewBlock("lifecycle", nil).Body()
+	lifecycle.SetAttributeValue("destroy", cty.False)
+	body.AppendNewline()
+}
+
+// stateAddressTokens converts a resource address from state, which may
+// include an instance key such as `cloudflare_record.mx[0]`, into tokens.
+func stateAddressTokens(address string) hclwrite.Tokens {
+	traversal, diags := hclsyntax.ParseTraversalAbs([]byte(address), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return hclwrite.TokensForTraversal(addressTraversal(address))
+	}
+
+	return hclwrite.TokensForTraversal(traversal)
+}
+
+// writeMigrationSummary lists each migrated resource, the v4 configuration
+// blocks that need replacing and the resources that couldn't be migrated.
+func writeMigrationSummary(w io.Writer, migrations []migration, failures []migrationFailure, config []configResource) {
+	failed := make(map[string]bool, len(failures))
+	for _, f := range failures {
+		failed[f.Address] = true
+	}
+
+	blocks := make(map[string]configResource, len(config))
+	for _, c := range config {
+		blocks[c.Address()] = c
+	}
+
+	migrated := 0
+	for _, m := range migrations {
+		if failed[m.From.Address] {
+			continue
+		}
+		migrated++
+
+		action := "moved"
+		switch {
+		case m.From.Address == m.ToAddress():
+			action = "upgraded in place"
+		case !m.Moved:
+			action = "removed and imported"
+		}
+
+		line := fmt.Sprintf("%s -> %s (%s)", m.From.Address, m.ToAddress(), action)
+		if c, ok := blocks[m.From.Type+"."+m.From.Name]; ok {
+			line += fmt.Sprintf(", replaces %s:%d", c.File, c.Line)
+		}
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintf(w, "%d resources migrated\n", migrated)
+
+	if len(failures) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "unable to migrate %d resources:\n", len(failures))
+	for _, f := range failures {
+		fmt.Fprintf(w, "  %s: %s\n", f.Address, f.Reason)
+	}
+}
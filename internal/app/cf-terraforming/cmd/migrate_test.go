@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func migrateTestResources() map[string]*tfjson.StateResource {
+	return map[string]*tfjson.StateResource{
+		"cloudflare_record.www": {
+			Address: "cloudflare_record.www", Mode: tfjson.ManagedResourceMode, Type: "cloudflare_record", Name: "www",
+			AttributeValues: map[string]interface{}{"id": "abc123", "zone_id": "0da42c8d2132a9ddaf714f9e7c920711", "value": "192.0.2.1"},
+		},
+		"cloudflare_teams_location.office": {
+			Address: "cloudflare_teams_location.office", Mode: tfjson.ManagedResourceMode, Type: "cloudflare_teams_location", Name: "office",
+			AttributeValues: map[string]interface{}{"id": "loc1", "account_id": "f037e56e89293a057740de681ac9abbe"},
+		},
+		"cloudflare_zone.example": {
+			Address: "cloudflare_zone.example", Mode: tfjson.ManagedResourceMode, Type: "cloudflare_zone", Name: "example",
+			AttributeValues: map[string]interface{}{"id": "0da42c8d2132a9ddaf714f9e7c920711"},
+		},
+		"cloudflare_zone_settings_override.example": {
+			Address: "cloudflare_zone_settings_override.example", Mode: tfjson.ManagedResourceMode, Type: "cloudflare_zone_settings_override", Name: "example",
+			AttributeValues: map[string]interface{}{"id": "0da42c8d2132a9ddaf714f9e7c920711"},
+		},
+		"module.dns.cloudflare_record.mx": {
+			Address: "module.dns.cloudflare_record.mx", Mode: tfjson.ManagedResourceMode, Type: "cloudflare_record", Name: "mx",
+			AttributeValues: map[string]interface{}{"id": "def456"},
+		},
+		"random_id.suffix": {
+			Address: "random_id.suffix", Mode: tfjson.ManagedResourceMode, Type: "random_id", Name: "suffix",
+		},
+	}
+}
+
+func TestMigrationTypes_TargetsExist(t *testing.T) {
+	for from, target := range migrationTypes {
+		_, ok := resourceToEndpoint[target.resourceType]
+		assert.True(t, ok, "%s migrates to %s which has no endpoint mapping", from, target.resourceType)
+	}
+}
+
+func TestPlanMigration(t *testing.T) {
+	migrations, failures := planMigration(migrateTestResources())
+
+	addresses := make(map[string]string)
+	for _, m := range migrations {
+		addresses[m.From.Address] = m.ToAddress()
+	}
+	assert.Equal(t, map[string]string{
+		"cloudflare_record.www":            "cloudflare_dns_record.www",
+		"cloudflare_teams_location.office": "cloudflare_zero_trust_dns_location.office",
+		"cloudflare_zone.example":          "cloudflare_zone.example",
+	}, addresses)
+
+	assert.Equal(t, []migrationFailure{
+		{Address: "cloudflare_zone_settings_override.example", Reason: "cloudflare_zone_settings_override was replaced in v5, use \"cf-terraforming convert zone-settings\""},
+		{Address: "module.dns.cloudflare_record.mx", Reason: "resources in child modules must be migrated from within the module"},
+	}, failures)
+}
+
+func TestGenerateMigration(t *testing.T) {
+	defer func(v string) { providerVersionString = v }(providerVersionString)
+	providerVersionString = "5.1.0"
+
+	schema := func() *tfjson.Schema {
+		return &tfjson.Schema{Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+			"id":         {AttributeType: cty.String, Computed: true},
+			"account_id": {AttributeType: cty.String, Optional: true},
+			"zone_id":    {AttributeType: cty.String, Optional: true},
+			"name":       {AttributeType: cty.String, Required: true},
+			"content":    {AttributeType: cty.String, Optional: true},
+		}}}
+	}
+	s := &tfjson.ProviderSchema{ResourceSchemas: map[string]*tfjson.Schema{
+		"cloudflare_dns_record":              schema(),
+		"cloudflare_zero_trust_dns_location": schema(),
+		"cloudflare_zone":                    schema(),
+	}}
+
+	migrations, _ := planMigration(migrateTestResources())
+	live := map[string]map[string]interface{}{
+		"cloudflare_record.www":            {"id": "abc123", "zone_id": "0da42c8d2132a9ddaf714f9e7c920711", "name": "www.example.com", "content": "192.0.2.1"},
+		"cloudflare_zone.example":          {"id": "0da42c8d2132a9ddaf714f9e7c920711", "account_id": "f037e56e89293a057740de681ac9abbe", "name": "example.com"},
+		"cloudflare_teams_location.office": {"id": "loc1", "account_id": "f037e56e89293a057740de681ac9abbe", "name": "Office"},
+	}
+
+	var buf bytes.Buffer
+	failures := generateMigration(&buf, s, migrations, func(m migration) (map[string]interface{}, error) {
+		return live[m.From.Address], nil
+	})
+	require.Empty(t, failures)
+
+	assert.Equal(t, `resource "cloudflare_dns_record" "www" {
+  content = "192.0.2.1"
+  name    = "www.example.com"
+  zone_id = "0da42c8d2132a9ddaf714f9e7c920711"
+}
+
+moved {
+  from = cloudflare_record.www
+  to   = cloudflare_dns_record.www
+}
+
+resource "cloudflare_zero_trust_dns_location" "office" {
+  account_id = "f037e56e89293a057740de681ac9abbe"
+  name       = "Office"
+}
+
+removed {
+  from = cloudflare_teams_location.office
+  lifecycle {
+    destroy = false
+  }
+}
+
+import {
+  to = cloudflare_zero_trust_dns_location.office
+  id = "f037e56e89293a057740de681ac9abbe/loc1"
+}
+
+resource "cloudflare_zone" "example" {
+  account_id = "f037e56e89293a057740de681ac9abbe"
+  name       = "example.com"
+}
+
+`, buf.String())
+}
+
+func TestGenerateMigration_FetchFailure(t *testing.T) {
+	migrations, _ := planMigration(map[string]*tfjson.StateResource{
+		"cloudflare_record.www": migrateTestResources()["cloudflare_record.www"],
+	})
+	s := &tfjson.ProviderSchema{ResourceSchemas: map[string]*tfjson.Schema{
+		"cloudflare_dns_record": {Block: &tfjson.SchemaBlock{}},
+	}}
+
+	var buf bytes.Buffer
+	failures := generateMigration(&buf, s, migrations, func(migration) (map[string]interface{}, error) {
+		return nil, errors.New("/zones/0da42c8d2132a9ddaf714f9e7c920711/dns_records/abc123 was not found")
+	})
+
+	assert.Equal(t, []migrationFailure{{
+		Address: "cloudflare_record.www",
+		Reason:  "/zones/0da42c8d2132a9ddaf714f9e7c920711/dns_records/abc123 was not found",
+	}}, failures)
+	assert.Empty(t, buf.String())
+}
+
+func TestAppendMigrationBlocks_Instance(t *testing.T) {
+	f := parseTestHCL(t, "")
+	appendMigrationBlocks(f.Body(), migration{
+		From:   &tfjson.StateResource{Address: `cloudflare_record.mx["primary"]`, Type: "cloudflare_record", Name: "mx", Index: "primary"},
+		ToType: "cloudflare_dns_record",
+		ToName: "mx_primary",
+		Moved:  true,
+	}, "")
+
+	assert.Equal(t, `moved {
+  from = cloudflare_record.mx["primary"]
+  to   = cloudflare_dns_record.mx_primary
+}
+
+`, string(f.Bytes()))
+}
+
+func TestWriteMigrationSummary(t *testing.T) {
+	migrations, failures := planMigration(migrateTestResources())
+
+	var buf bytes.Buffer
+	writeMigrationSummary(&buf, migrations, failures, []configResource{{Type: "cloudflare_record", Name: "www", File: "dns.tf", Line: 3}})
+
+	assert.Equal(t, `cloudflare_record.www -> cloudflare_dns_record.www (moved), replaces dns.tf:3
+cloudflare_teams_location.office -> cloudflare_zero_trust_dns_location.office (removed and imported)
+cloudflare_zone.example -> cloudflare_zone.example (upgraded in place)
+3 resources migrated
+unable to migrate 2 resources:
+  cloudflare_zone_settings_override.example: cloudflare_zone_settings_override was replaced in v5, use "cf-terraforming convert zone-settings"
+  module.dns.cloudflare_record.mx: resources in child modules must be migrated from within the module
+`, buf.String())
+}
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/spf13/cobra"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// generatedModuleName is the name of the module generated by `--module` and
+// the directory it's written to within `modules`.
+const generatedModuleName = "cloudflare"
+
+// writeModule packages generated configuration into a module within dir,
+// along with a root `main.tf` that calls it:
+//
+//	<dir>/main.tf                              module call and import blocks
+//	<dir>/variables.tf                         secrets passed to the module
+//	<dir>/versions.tf                          provider requirements
+//	<dir>/modules/cloudflare/main.tf           generated resources
+//	<dir>/modules/cloudflare/variables.tf      account and zone IDs and secrets
+//	<dir>/modules/cloudflare/outputs.tf        IDs of the generated resources
+//	<dir>/modules/cloudflare/versions.tf       provider requirements
+//
+// Import blocks can only be declared in the root module so they are moved to
+// the root `main.tf` and target the resources within the module. Nothing is
+// written if any of the files already exist.
+func writeModule(dir string, generated []byte, variables []generatedVariable, params *idParameters, registryPath, version string) error {
+	gen, diags := hclwrite.ParseConfig(generated, "generated.tf", hcl.InitialPos)
+	if diags.HasErrors() {
+		return fmt.Errorf("failed to parse generated configuration: %s", diags.Error())
+	}
+
+	moduleDir := filepath.Join(dir, "modules", generatedModuleName)
+
+	resources := hclwrite.NewEmptyFile()
+	outputs := hclwrite.NewEmptyFile()
+	root := hclwrite.NewEmptyFile()
+
+	call := root.Body().AppendNewBlock("module", []string{generatedModuleName}).Body()
+	call.SetAttributeValue("source", cty.StringVal("./modules/"+generatedModuleName))
+	params.appendModuleArguments(call)
+	for _, v := range variables {
+		call.SetAttributeTraversal(v.Name, variableTraversal(v.Name))
+	}
+	root.Body().AppendNewline()
+
+	for _, block := range gen.Body().Blocks() {
+		switch block.Type() {
+		case "import":
+			to := block.Body().GetAttribute("to")
+			if to == nil {
+				continue
+			}
+			moved := root.Body().AppendNewBlock("import", nil).Body()
+			target := hclwrite.TokensForTraversal(hcl.Traversal{
+				hcl.TraverseRoot{Name: "module"},
+				hcl.TraverseAttr{Name: generatedModuleName},
+			})
+			target = append(target, &hclwrite.Token{Type: hclsyntax.TokenDot, Bytes: []byte(".")})
+			moved.SetAttributeRaw("to", append(target, to.Expr().BuildTokens(nil)...))
+			attrs := block.Body().Attributes()
+			for _, name := range sortedAttributeNames(attrs) {
+				if name != "to" {
+					moved.SetAttributeRaw(name, attrs[name].Expr().BuildTokens(nil))
+				}
+			}
+			root.Body().AppendNewline()
+		case "variable":
+			// placeholders are declared along with the other variables.
+			continue
+		case "resource":
+			resources.Body().AppendBlock(block)
+			resources.Body().AppendNewline()
+
+			labels := block.Labels()
+			output := outputs.Body().AppendNewBlock("output", []string{placeholderVariableName(labels[0], labels[1], "id")}).Body()
+			// resources using `for_each` output a map of IDs by instance key.
+			if block.Body().GetAttribute("for_each") != nil {
+				output.SetAttributeRaw("value", hclwrite.Tokens{{
+					Type:  hclsyntax.TokenIdent,
+					Bytes: []byte(fmt.Sprintf("{ for key, r in %s.%s : key => r.id }", labels[0], labels[1])),
+				}})
+			} else {
+				output.SetAttributeTraversal("value", hcl.Traversal{
+					hcl.TraverseRoot{Name: labels[0]},
+					hcl.TraverseAttr{Name: labels[1]},
+					hcl.TraverseAttr{Name: "id"},
+				})
+			}
+			outputs.Body().AppendNewline()
+		default:
+			resources.Body().AppendBlock(block)
+			resources.Body().AppendNewline()
+		}
+	}
+
+	moduleVariables := hclwrite.NewEmptyFile()
+	params.appendDefinitions(moduleVariables.Body())
+	rootVariables := hclwrite.NewEmptyFile()
+	for _, v := range variables {
+		appendVariableBlock(moduleVariables.Body(), v)
+		moduleVariables.Body().AppendNewline()
+		appendVariableBlock(rootVariables.Body(), v)
+		rootVariables.Body().AppendNewline()
+	}
+
+	versions := providerVersionsFile(registryPath, version)
+
+	files := map[string]*hclwrite.File{
+		filepath.Join(moduleDir, "main.tf"):      resources,
+		filepath.Join(moduleDir, "variables.tf"): moduleVariables,
+		filepath.Join(moduleDir, "outputs.tf"):   outputs,
+		filepath.Join(moduleDir, "versions.tf"):  versions,
+		filepath.Join(dir, "main.tf"):            root,
+		filepath.Join(dir, "versions.tf"):        versions,
+	}
+	if len(variables) > 0 {
+		files[filepath.Join(dir, "variables.tf")] = rootVariables
+	}
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var existing []string
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			existing = append(existing, path)
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if len(existing) > 0 {
+		return fmt.Errorf("refusing to overwrite %s, write the module to a new directory", strings.Join(existing, ", "))
+	}
+
+	if err := os.MkdirAll(moduleDir, 0o755); err != nil {
+		return err
+	}
+
+	for path, f := range files {
+		if err := os.WriteFile(path, hclwrite.Format(f.Bytes()), 0o644); err != nil {
+			return err
+		}
+	}
+
+	if writeSecrets {
+		return writeSecretsFile(dir, variables)
+	}
+
+	return nil
+}
+
+// writeGeneratedModule writes the configuration generated with `--module` and
+// lists the variables that must be set.
+func writeGeneratedModule(cmd *cobra.Command, generated []byte, variables []generatedVariable, params *idParameters, registryPath string) {
+	if err := writeModule(moduleDir, generated, variables, params, registryPath, providerVersionString); err != nil {
+		log.Fatalf("failed to write module to %s: %s", moduleDir, err)
+	}
+
+	fmt.Fprintf(cmd.OutOrStderr(), "wrote module %q to %s\n", generatedModuleName, filepath.Join(moduleDir, "modules", generatedModuleName))
+	writeRequiredPlaceholders(cmd.OutOrStderr(), variables)
+}
+
+// providerVersionsFile pins the provider to the version detected in the
+// Terraform working directory.
+func providerVersionsFile(registryPath, version string) *hclwrite.File {
+	f := hclwrite.NewEmptyFile()
+	providers := f.Body().AppendNewBlock("terraform", nil).Body().AppendNewBlock("required_providers", nil).Body()
+
+	source := strings.TrimPrefix(registryPath, "registry.terraform.io/")
+	providers.SetAttributeValue("cloudflare", cty.ObjectVal(map[string]cty.Value{
+		"source":  cty.StringVal(source),
+		"version": cty.StringVal(version),
+	}))
+
+	return f
+}
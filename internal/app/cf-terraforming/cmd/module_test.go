@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	return string(b)
+}
+
+func TestWriteModule(t *testing.T) {
+	f := parseTestHCL(t, `resource "cloudflare_dns_record" "www" {
+  name    = "www"
+  zone_id = "0da42c8d2132a9ddaf714f9e7c920711"
+}
+
+import {
+  to = cloudflare_dns_record.www
+  id = "0da42c8d2132a9ddaf714f9e7c920711/abc"
+}
+
+resource "cloudflare_workers_secret" "token" {
+  account_id = "f037e56e89293a057740de681ac9abbe"
+  text       = var.workers_secret_token_text
+}
+`)
+
+	params := newIDParameters("f037e56e89293a057740de681ac9abbe", "0da42c8d2132a9ddaf714f9e7c920711", nil)
+	params.asModule = true
+	params.parameterizeFile(f)
+
+	variables := []generatedVariable{{
+		Name:        "workers_secret_token_text",
+		Type:        cty.String,
+		Description: "Sensitive value of text for cloudflare_workers_secret.token.",
+		Sensitive:   true,
+		Value:       cty.StringVal("s3cr3t"),
+	}}
+
+	dir := t.TempDir()
+	require.NoError(t, writeModule(dir, hclwrite.Format(f.Bytes()), variables, params, "registry.terraform.io/cloudflare/cloudflare", "5.1.0"))
+
+	moduleDir := filepath.Join(dir, "modules", "cloudflare")
+	assert.Equal(t, `resource "cloudflare_dns_record" "www" {
+  name    = "www"
+  zone_id = var.zone_id
+}
+
+resource "cloudflare_workers_secret" "token" {
+  account_id = var.account_id
+  text       = var.workers_secret_token_text
+}
+
+`, readTestFile(t, filepath.Join(moduleDir, "main.tf")))
+
+	assert.Equal(t, `variable "account_id" {
+  type        = string
+  description = "Cloudflare account ID"
+}
+
+variable "zone_id" {
+  type        = string
+  description = "Cloudflare zone ID"
+}
+
+variable "workers_secret_token_text" {
+  type        = string
+  description = "Sensitive value of text for cloudflare_workers_secret.token."
+  sensitive   = true
+}
+
+`, readTestFile(t, filepath.Join(moduleDir, "variables.tf")))
+
+	assert.Equal(t, `output "dns_record_www_id" {
+  value = cloudflare_dns_record.www.id
+}
+
+output "workers_secret_token_id" {
+  value = cloudflare_workers_secret.token.id
+}
+
+`, readTestFile(t, filepath.Join(moduleDir, "outputs.tf")))
+
+	versions := `terraform {
+  required_providers {
+    cloudflare = {
+      source  = "cloudflare/cloudflare"
+      version = "5.1.0"
+    }
+  }
+}
+`
+	assert.Equal(t, versions, readTestFile(t, filepath.Join(moduleDir, "versions.tf")))
+	assert.Equal(t, versions, readTestFile(t, filepath.Join(dir, "versions.tf")))
+
+	assert.Equal(t, `module "cloudflare" {
+  source                    = "./modules/cloudflare"
+  account_id                = "f037e56e89293a057740de681ac9abbe"
+  zone_id                   = "0da42c8d2132a9ddaf714f9e7c920711"
+  workers_secret_token_text = var.workers_secret_token_text
+}
+
+import {
+  to = module.cloudflare.cloudflare_dns_record.www
+  id = "0da42c8d2132a9ddaf714f9e7c920711/abc"
+}
+
+`, readTestFile(t, filepath.Join(dir, "main.tf")))
+
+	assert.Equal(t, `variable "workers_secret_token_text" {
+  type        = string
+  description = "Sensitive value of text for cloudflare_workers_secret.token."
+  sensitive   = true
+}
+
+`, readTestFile(t, filepath.Join(dir, "variables.tf")))
+	assert.NoFileExists(t, filepath.Join(dir, secretsFilename))
+}
+
+func TestWriteModule_MultipleZones(t *testing.T) {
+	f := parseTestHCL(t, `resource "cloudflare_dns_record" "www" {
+  name    = "www"
+  zone_id = "0da42c8d2132a9ddaf714f9e7c920711"
+}
+`)
+
+	params := newIDParameters("", "", func(string) string { return "example.com" })
+	params.asModule = true
+	params.parameterizeFile(f)
+
+	dir := t.TempDir()
+	require.NoError(t, writeModule(dir, hclwrite.Format(f.Bytes()), nil, params, "registry.terraform.io/cloudflare/cloudflare", "5.1.0"))
+
+	assert.Contains(t, readTestFile(t, filepath.Join(dir, "modules", "cloudflare", "main.tf")), `zone_id = var.zone_ids["example.com"]`)
+	assert.Equal(t, `variable "zone_ids" {
+  type        = map(string)
+  description = "Cloudflare zone IDs keyed by zone name"
+}
+
+`, readTestFile(t, filepath.Join(dir, "modules", "cloudflare", "variables.tf")))
+	assert.Contains(t, readTestFile(t, filepath.Join(dir, "main.tf")), `zone_ids = {
+    "example.com" = "0da42c8d2132a9ddaf714f9e7c920711"
+  }`)
+	assert.NoFileExists(t, filepath.Join(dir, "variables.tf"))
+}
+
+func TestWriteModule_ForEachOutputs(t *testing.T) {
+	f := parseTestHCL(t, `resource "cloudflare_dns_record" "terraform_managed_resource" {
+  for_each = local.dns_record
+  name     = each.value.name
+}
+`)
+
+	dir := t.TempDir()
+	require.NoError(t, writeModule(dir, hclwrite.Format(f.Bytes()), nil, newIDParameters("", "", nil), "registry.terraform.io/cloudflare/cloudflare", "5.1.0"))
+
+	assert.Equal(t, `output "dns_record_terraform_managed_resource_id" {
+  value = { for key, r in cloudflare_dns_record.terraform_managed_resource : key => r.id }
+}
+
+`, readTestFile(t, filepath.Join(dir, "modules", "cloudflare", "outputs.tf")))
+}
+
+func TestWriteModule_ExistingFiles(t *testing.T) {
+	f := parseTestHCL(t, `resource "cloudflare_dns_record" "terraform_managed_resource" {
+  name = "www"
+}
+`)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte("# existing\n"), 0o644))
+
+	err := writeModule(dir, hclwrite.Format(f.Bytes()), nil, newIDParameters("", "", nil), "registry.terraform.io/cloudflare/cloudflare", "5.1.0")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), filepath.Join(dir, "main.tf"))
+
+	assert.Equal(t, "# existing\n", readTestFile(t, filepath.Join(dir, "main.tf")))
+	assert.NoFileExists(t, filepath.Join(dir, "versions.tf"))
+	assert.NoDirExists(t, filepath.Join(dir, "modules"))
+}
@@ -0,0 +1,266 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const terraformStateRmCmdPrefix = "terraform state rm"
+
+var orphansCmd = &cobra.Command{
+	Use:    "orphans",
+	Short:  "Output removed blocks for resources in Terraform state that no longer exist in Cloudflare",
+	Run:    runOrphans(),
+	PreRun: sharedPreRun,
+}
+
+func init() {
+	rootCmd.AddCommand(orphansCmd)
+
+	orphansCmd.Flags().BoolVar(&useStateRm, "state-rm", false, "Output terraform state rm commands instead of removed blocks for orphaned resources. Removed blocks are only compatible with Terraform 1.7+")
+}
+
+func runOrphans() func(cmd *cobra.Command, args []string) {
+	return func(cmd *cobra.Command, args []string) {
+		zoneID = viper.GetString("zone")
+		accountID = viper.GetString("account")
+
+		tf, _, cleanup := initTerraform()
+		defer cleanup()
+
+		if !strings.HasPrefix(providerVersionString, "5") {
+			log.Fatalf("finding orphaned resources requires v5 of the provider, found %s", providerVersionString)
+		}
+
+		state, err := tf.Show(context.Background())
+		if err != nil {
+			log.Fatalf("failed to read Terraform state: %s", err)
+		}
+
+		orphans := findOrphans(stateResources(state))
+		if len(orphans) == 0 {
+			fmt.Fprintln(cmd.OutOrStderr(), "no orphaned resources found")
+			return
+		}
+
+		if useStateRm {
+			writeStateRmCommands(cmd.OutOrStdout(), orphans)
+		} else {
+			writeRemovedBlocks(cmd.OutOrStdout(), orphans)
+		}
+	}
+}
+
+// orphanGroup is the state resources of a single type within the same
+// account or zone which can be checked with a single list request.
+type orphanGroup struct {
+	resourceType string
+	accountID    string
+	zoneID       string
+	resources    []*tfjson.StateResource
+}
+
+// groupStateResources groups the Cloudflare resources in state by type and
+// the account or zone they belong to.
+func groupStateResources(resources map[string]*tfjson.StateResource) []*orphanGroup {
+	groups := make(map[string]*orphanGroup)
+	for _, r := range resources {
+		if !strings.HasPrefix(r.Type, "cloudflare_") {
+			continue
+		}
+
+		account, _ := r.AttributeValues["account_id"].(string)
+		zone, _ := r.AttributeValues["zone_id"].(string)
+		if account == "" && zone == "" {
+			account, zone = accountID, zoneID
+		}
+
+		key := strings.Join([]string{r.Type, account, zone}, "/")
+		if groups[key] == nil {
+			groups[key] = &orphanGroup{resourceType: r.Type, accountID: account, zoneID: zone}
+		}
+		groups[key].resources = append(groups[key].resources, r)
+	}
+
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	sorted := make([]*orphanGroup, 0, len(keys))
+	for _, k := range keys {
+		g := groups[k]
+		sort.Slice(g.resources, func(i, j int) bool { return g.resources[i].Address < g.resources[j].Address })
+		sorted = append(sorted, g)
+	}
+
+	return sorted
+}
+
+// findOrphans compares the IDs in state against what the API lists for each
+// resource type. Every candidate is then fetched individually to confirm it
+// no longer exists before it is reported, so that a partial listing can never
+// cause a live resource to be removed from state.
+func findOrphans(resources map[string]*tfjson.StateResource) []*tfjson.StateResource {
+	defer func(a, z string) {
+		accountID, zoneID = a, z
+	}(accountID, zoneID)
+
+	var orphans []*tfjson.StateResource
+	for _, g := range groupStateResources(resources) {
+		if resourceEndpoint(g.resourceType) == "" {
+			log.WithFields(logrus.Fields{
+				"resource": g.resourceType,
+			}).Debug("skipping resource without an API endpoint mapping")
+			continue
+		}
+
+		accountID, zoneID = g.accountID, g.zoneID
+		if zoneID != "" {
+			accountID = ""
+		}
+
+		for _, r := range unlistedResources(g) {
+			gone, err := resourceIsGone(r)
+			if err != nil {
+				log.WithFields(logrus.Fields{
+					"address": r.Address,
+				}).Warnf("unable to check whether resource exists: %s", err)
+				continue
+			}
+
+			if gone {
+				orphans = append(orphans, r)
+			}
+		}
+	}
+
+	return orphans
+}
+
+// unlistedResources returns the resources in the group that are missing from
+// the list endpoint. When the resources can't be listed without a parent
+// identifier, every resource in the group is returned.
+func unlistedResources(g *orphanGroup) []*tfjson.StateResource {
+	endpoint := resolveEndpointScope(resourceEndpoint(g.resourceType))
+	if strings.Contains(endpoint, "{") {
+		return g.resources
+	}
+
+	live, err := fetchEndpoint(g.resourceType, endpoint, "")
+	if err != nil && !isNotFound(err) && !errors.Is(err, errNoResult) {
+		log.WithFields(logrus.Fields{
+			"resource": g.resourceType,
+			"endpoint": endpoint,
+		}).Warnf("unable to list resources: %s", err)
+		return nil
+	}
+
+	liveIDs := make(map[string]bool, len(live))
+	for _, l := range live {
+		if data, ok := l.(map[string]interface{}); ok {
+			liveIDs[resourceIDFromData(data)] = true
+		}
+	}
+
+	var candidates []*tfjson.StateResource
+	for _, r := range g.resources {
+		if id, ok := r.AttributeValues["id"].(string); ok && !liveIDs[id] {
+			candidates = append(candidates, r)
+		}
+	}
+
+	return candidates
+}
+
+// resourceIsGone fetches a single resource from the `get` endpoint using the
+// values in state and reports whether the API returned a 404.
+func resourceIsGone(r *tfjson.StateResource) (bool, error) {
+	id, _ := r.AttributeValues["id"].(string)
+	endpoint, err := resolveGetEndpoint(r.Type, id, r.AttributeValues)
+	if err != nil {
+		return false, err
+	}
+
+	var result *http.Response
+	err = api.Get(context.Background(), endpoint, nil, &result)
+	if err != nil {
+		if isNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	result.Body.Close()
+
+	return false, nil
+}
+
+// writeRemovedBlocks outputs a `removed` block for each orphaned resource so
+// that Terraform forgets it without attempting to destroy it. Removed blocks
+// can't target a single instance of a resource or module using `count` or
+// `for_each` so those are output as `terraform state rm` commands within a
+// comment.
+func writeRemovedBlocks(w io.Writer, orphans []*tfjson.StateResource) {
+	f := hclwrite.NewEmptyFile()
+	body := f.Body()
+
+	for _, r := range orphans {
+		if r.Index != nil || strings.Contains(r.Address, "[") {
+			body.AppendUnstructuredTokens(hclwrite.Tokens{{
+				Type:  hclsyntax.TokenComment,
+				Bytes: []byte(fmt.Sprintf("# %s no longer exists: %s\n", r.Address, stateRmCommand(r.Address))),
+			}})
+			body.AppendNewline()
+			continue
+		}
+
+		removed := body.AppendNewBlock("removed", []string{}).Body()
+		removed.SetAttributeTraversal("from", addressTraversal(r.Address))
+		lifecycle := removed.AppendNewBlock("lifecycle", []string{}).Body()
+		lifecycle.SetAttributeValue("destroy", cty.False)
+		body.AppendNewline()
+	}
+
+	fmt.Fprint(w, string(hclwrite.Format(f.Bytes())))
+}
+
+// writeStateRmCommands outputs a `terraform state rm` command for each
+// orphaned resource.
+func writeStateRmCommands(w io.Writer, orphans []*tfjson.StateResource) {
+	for _, r := range orphans {
+		fmt.Fprintln(w, stateRmCommand(r.Address))
+	}
+}
+
+// stateRmCommand builds the `terraform state rm` command for an address,
+// quoting it as instance keys contain characters the shell would interpret.
+func stateRmCommand(address string) string {
+	return fmt.Sprintf("%s '%s'", terraformStateRmCmdPrefix, strings.ReplaceAll(address, "'", `'\''`))
+}
+
+// addressTraversal converts a resource address without an instance key, such
+// as `module.dns.cloudflare_dns_record.www`, into a traversal.
+func addressTraversal(address string) hcl.Traversal {
+	parts := strings.Split(address, ".")
+	traversal := hcl.Traversal{hcl.TraverseRoot{Name: parts[0]}}
+	for _, p := range parts[1:] {
+		traversal = append(traversal, hcl.TraverseAttr{Name: p})
+	}
+
+	return traversal
+}
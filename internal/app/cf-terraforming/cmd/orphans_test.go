@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupStateResources(t *testing.T) {
+	resources := map[string]*tfjson.StateResource{
+		"cloudflare_dns_record.b":  {Address: "cloudflare_dns_record.b", Type: "cloudflare_dns_record", AttributeValues: map[string]interface{}{"zone_id": "z1"}},
+		"cloudflare_dns_record.a":  {Address: "cloudflare_dns_record.a", Type: "cloudflare_dns_record", AttributeValues: map[string]interface{}{"zone_id": "z1"}},
+		"cloudflare_dns_record.c":  {Address: "cloudflare_dns_record.c", Type: "cloudflare_dns_record", AttributeValues: map[string]interface{}{"zone_id": "z2"}},
+		"cloudflare_list.d":        {Address: "cloudflare_list.d", Type: "cloudflare_list", AttributeValues: map[string]interface{}{"account_id": "a1"}},
+		"random_id.e":              {Address: "random_id.e", Type: "random_id"},
+		"module.x.cloudflare_zone": {Address: "module.x.cloudflare_zone.f", Type: "cloudflare_zone", AttributeValues: map[string]interface{}{"account": map[string]interface{}{"id": "a1"}}},
+	}
+
+	zoneID = "fallback"
+	defer func() { zoneID = "" }()
+
+	groups := groupStateResources(resources)
+
+	assert.Len(t, groups, 4)
+	assert.Equal(t, "cloudflare_dns_record", groups[0].resourceType)
+	assert.Equal(t, "z1", groups[0].zoneID)
+	assert.Equal(t, "cloudflare_dns_record.a", groups[0].resources[0].Address)
+	assert.Equal(t, "cloudflare_dns_record.b", groups[0].resources[1].Address)
+	assert.Equal(t, "z2", groups[1].zoneID)
+	assert.Equal(t, "a1", groups[2].accountID)
+	assert.Equal(t, "fallback", groups[3].zoneID)
+}
+
+func TestWriteOrphans(t *testing.T) {
+	orphans := []*tfjson.StateResource{
+		{Address: "cloudflare_dns_record.www"},
+		{Address: "module.dns.cloudflare_dns_record.api"},
+		{Address: `cloudflare_dns_record.records["mail"]`, Index: "mail"},
+	}
+
+	var out bytes.Buffer
+	writeRemovedBlocks(&out, orphans)
+	assert.Equal(t, `removed {
+  from = cloudflare_dns_record.www
+  lifecycle {
+    destroy = false
+  }
+}
+
+removed {
+  from = module.dns.cloudflare_dns_record.api
+  lifecycle {
+    destroy = false
+  }
+}
+
+# cloudflare_dns_record.records["mail"] no longer exists: terraform state rm 'cloudflare_dns_record.records["mail"]'
+
+`, out.String())
+
+	out.Reset()
+	writeStateRmCommands(&out, orphans)
+	assert.Equal(t, `terraform state rm 'cloudflare_dns_record.www'
+terraform state rm 'module.dns.cloudflare_dns_record.api'
+terraform state rm 'cloudflare_dns_record.records["mail"]'
+`, out.String())
+}
@@ -0,0 +1,322 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go/v4"
+	"github.com/cloudflare/cloudflare-go/v4/zones"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const (
+	accountIDVariable = "account_id"
+	zoneIDVariable    = "zone_id"
+	zoneIDsLocal      = "zone_ids"
+)
+
+// idParameters tracks the account and zone IDs lifted out of generated
+// configuration by `--parameterize`. A single account or zone is referenced
+// through a variable. When zones aren't targeted individually, each zone is
+// referenced through a local map keyed by the zone name instead.
+type idParameters struct {
+	accountID string
+	zoneID    string
+
+	usedAccount bool
+	usedZone    bool
+
+	// zones maps zone IDs to their key in `local.zone_ids`.
+	zones    map[string]string
+	zoneName func(id string) string
+
+	// asModule references zones through a `var.zone_ids` map and leaves
+	// import IDs as literals, as import blocks can only be in the root module.
+	asModule bool
+}
+
+func newIDParameters(account, zone string, zoneName func(id string) string) *idParameters {
+	return &idParameters{
+		accountID: account,
+		zoneID:    zone,
+		zones:     make(map[string]string),
+		zoneName:  zoneName,
+	}
+}
+
+// lookupZoneName fetches the name of a zone to key it by, returning an empty
+// string if it can't be found.
+func lookupZoneName(id string) string {
+	zone, err := api.Zones.Get(context.Background(), zones.ZoneGetParams{ZoneID: cloudflare.F(id)})
+	if err != nil {
+		log.Debugf("failed to fetch name of zone %s: %s", id, err)
+		return ""
+	}
+
+	return zone.Name
+}
+
+// stateZoneNames returns a lookup of zone names from the zones in state, for
+// when the Cloudflare API isn't available.
+func stateZoneNames(resources map[string]*tfjson.StateResource) func(id string) string {
+	names := make(map[string]string)
+	for _, r := range resources {
+		if r.Type != "cloudflare_zone" {
+			continue
+		}
+		id, _ := r.AttributeValues["id"].(string)
+		name, _ := r.AttributeValues["name"].(string)
+		names[id] = name
+	}
+
+	return func(id string) string { return names[id] }
+}
+
+// parameterizeFile replaces account and zone ID literals in the resources and
+// import blocks of f with references.
+func (p *idParameters) parameterizeFile(f *hclwrite.File) {
+	for _, block := range f.Body().Blocks() {
+		body := block.Body()
+		switch block.Type() {
+		case "resource":
+			for _, name := range []string{"account_id", "zone_id"} {
+				id, ok := literalString(body.GetAttribute(name))
+				if !ok {
+					continue
+				}
+				if traversal := p.reference(name, id); traversal != nil {
+					body.SetAttributeTraversal(name, traversal)
+				}
+			}
+		case "import":
+			id, ok := literalString(body.GetAttribute("id"))
+			if !ok || p.asModule {
+				continue
+			}
+			if tokens, ok := p.importIDTemplate(id); ok {
+				body.SetAttributeRaw("id", tokens)
+			}
+		}
+	}
+}
+
+// reference returns the reference replacing the ID set on attribute, or nil
+// when it should stay a literal.
+func (p *idParameters) reference(attribute, id string) hcl.Traversal {
+	switch attribute {
+	case "account_id":
+		if p.accountID == "" {
+			p.accountID = id
+		}
+		if id != p.accountID {
+			return nil
+		}
+		p.usedAccount = true
+		return variableTraversal(accountIDVariable)
+	case "zone_id":
+		if p.zoneID != "" {
+			if id != p.zoneID {
+				return nil
+			}
+			p.usedZone = true
+			return variableTraversal(zoneIDVariable)
+		}
+		return p.zoneTraversal(p.zoneKey(id))
+	}
+
+	return nil
+}
+
+// zoneTraversal references a zone in the map of zone IDs.
+func (p *idParameters) zoneTraversal(key string) hcl.Traversal {
+	root := "local"
+	if p.asModule {
+		root = "var"
+	}
+
+	return hcl.Traversal{
+		hcl.TraverseRoot{Name: root},
+		hcl.TraverseAttr{Name: zoneIDsLocal},
+		hcl.TraverseIndex{Key: cty.StringVal(key)},
+	}
+}
+
+// zoneKey returns the key of a zone in `local.zone_ids`, which is the zone
+// name when it's known and unique, otherwise the zone ID.
+func (p *idParameters) zoneKey(id string) string {
+	if key, ok := p.zones[id]; ok {
+		return key
+	}
+
+	key := id
+	if p.zoneName != nil {
+		if name := p.zoneName(id); name != "" {
+			key = name
+		}
+	}
+	for _, existing := range p.zones {
+		if existing == key {
+			key = id
+			break
+		}
+	}
+	p.zones[id] = key
+
+	return key
+}
+
+// known returns the reference for an ID that has already been lifted out of
+// a resource.
+func (p *idParameters) known(id string) hcl.Traversal {
+	switch {
+	case p.usedAccount && id == p.accountID:
+		return variableTraversal(accountIDVariable)
+	case p.usedZone && id == p.zoneID:
+		return variableTraversal(zoneIDVariable)
+	}
+	if key, ok := p.zones[id]; ok {
+		return p.zoneTraversal(key)
+	}
+
+	return nil
+}
+
+// importIDTemplate rewrites an import ID such as `<zone_id>/<record_id>` into
+// a template interpolating the references for the account and zone IDs.
+func (p *idParameters) importIDTemplate(id string) (hclwrite.Tokens, bool) {
+	tokens := hclwrite.Tokens{{Type: hclsyntax.TokenOQuote, Bytes: []byte(`"`)}}
+	literal := ""
+	replaced := false
+
+	flush := func() {
+		if literal == "" {
+			return
+		}
+		quoted := hclwrite.TokensForValue(cty.StringVal(literal))
+		tokens = append(tokens, quoted[1:len(quoted)-1]...)
+		literal = ""
+	}
+
+	for i, segment := range strings.Split(id, "/") {
+		if i > 0 {
+			literal += "/"
+		}
+		traversal := p.known(segment)
+		if traversal == nil {
+			literal += segment
+			continue
+		}
+
+		flush()
+		tokens = append(tokens, &hclwrite.Token{Type: hclsyntax.TokenTemplateInterp, Bytes: []byte("${")})
+		tokens = append(tokens, hclwrite.TokensForTraversal(traversal)...)
+		tokens = append(tokens, &hclwrite.Token{Type: hclsyntax.TokenTemplateSeqEnd, Bytes: []byte("}")})
+		replaced = true
+	}
+	flush()
+	tokens = append(tokens, &hclwrite.Token{Type: hclsyntax.TokenCQuote, Bytes: []byte(`"`)})
+
+	return tokens, replaced
+}
+
+// appendDefinitions outputs the variables and locals referenced by the
+// parameterized configuration. The variables default to the IDs they replaced
+// so the configuration works as is, but can be pointed elsewhere.
+func (p *idParameters) appendDefinitions(body *hclwrite.Body) {
+	if p.usedAccount {
+		p.appendIDVariable(body, accountIDVariable, "Cloudflare account ID", p.accountID)
+	}
+	if p.usedZone {
+		p.appendIDVariable(body, zoneIDVariable, "Cloudflare zone ID", p.zoneID)
+	}
+
+	if len(p.zones) == 0 {
+		return
+	}
+
+	if p.asModule {
+		variable := body.AppendNewBlock("variable", []string{zoneIDsLocal}).Body()
+		variable.SetAttributeRaw("type", hclwrite.TokensForIdentifier("map(string)"))
+		variable.SetAttributeValue("description", cty.StringVal("Cloudflare zone IDs keyed by zone name"))
+		body.AppendNewline()
+		return
+	}
+
+	ids := make(map[string]cty.Value, len(p.zones))
+	for id, key := range p.zones {
+		ids[key] = cty.StringVal(id)
+	}
+
+	locals := body.AppendNewBlock("locals", nil).Body()
+	locals.SetAttributeValue(zoneIDsLocal, cty.ObjectVal(ids))
+	body.AppendNewline()
+}
+
+// writeIDDefinitions outputs the definitions for the IDs lifted out by
+// `--parameterize`, if any.
+func writeIDDefinitions(w io.Writer, p *idParameters) {
+	if p == nil {
+		return
+	}
+
+	f := hclwrite.NewEmptyFile()
+	p.appendDefinitions(f.Body())
+	fmt.Fprint(w, string(hclwrite.Format(f.Bytes())))
+}
+
+// appendModuleArguments sets the IDs on the module block calling a module
+// generated with asModule.
+func (p *idParameters) appendModuleArguments(body *hclwrite.Body) {
+	if p.usedAccount {
+		body.SetAttributeValue(accountIDVariable, cty.StringVal(p.accountID))
+	}
+	if p.usedZone {
+		body.SetAttributeValue(zoneIDVariable, cty.StringVal(p.zoneID))
+	}
+	if len(p.zones) > 0 {
+		ids := make(map[string]cty.Value, len(p.zones))
+		for id, key := range p.zones {
+			ids[key] = cty.StringVal(id)
+		}
+		body.SetAttributeValue(zoneIDsLocal, cty.ObjectVal(ids))
+	}
+}
+
+// appendIDVariable declares the variable for an account or zone ID. Module
+// variables don't have a default as the IDs are passed in by the caller.
+func (p *idParameters) appendIDVariable(body *hclwrite.Body, name, description, value string) {
+	variable := body.AppendNewBlock("variable", []string{name}).Body()
+	variable.SetAttributeRaw("type", hclwrite.TokensForIdentifier("string"))
+	variable.SetAttributeValue("description", cty.StringVal(description))
+	if !p.asModule {
+		variable.SetAttributeValue("default", cty.StringVal(value))
+	}
+	body.AppendNewline()
+}
+
+// literalString returns the value of an attribute set to a string literal.
+func literalString(attr *hclwrite.Attribute) (string, bool) {
+	if attr == nil {
+		return "", false
+	}
+
+	tokens := attr.Expr().BuildTokens(nil)
+	for _, t := range tokens {
+		if t.Type == hclsyntax.TokenTemplateInterp || t.Type == hclsyntax.TokenIdent {
+			return "", false
+		}
+	}
+
+	v, ok := evaluateTokens(tokens)
+	if !ok || v.Type() != cty.String || v.IsNull() {
+		return "", false
+	}
+
+	return v.AsString(), true
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseTestHCL(t *testing.T, src string) *hclwrite.File {
+	t.Helper()
+
+	f, diags := hclwrite.ParseConfig([]byte(src), "test.tf", hcl.InitialPos)
+	require.False(t, diags.HasErrors(), diags.Error())
+
+	return f
+}
+
+func TestParameterize_SingleZone(t *testing.T) {
+	f := parseTestHCL(t, `resource "cloudflare_dns_record" "www" {
+  name    = "www"
+  zone_id = "0da42c8d2132a9ddaf714f9e7c920711"
+}
+
+import {
+  to = cloudflare_dns_record.www
+  id = "0da42c8d2132a9ddaf714f9e7c920711/abc"
+}
+`)
+
+	params := newIDParameters("", "0da42c8d2132a9ddaf714f9e7c920711", nil)
+	params.parameterizeFile(f)
+
+	var buf bytes.Buffer
+	buf.Write(hclwrite.Format(f.Bytes()))
+	writeIDDefinitions(&buf, params)
+
+	assert.Equal(t, `resource "cloudflare_dns_record" "www" {
+  name    = "www"
+  zone_id = var.zone_id
+}
+
+import {
+  to = cloudflare_dns_record.www
+  id = "${var.zone_id}/abc"
+}
+variable "zone_id" {
+  type        = string
+  description = "Cloudflare zone ID"
+  default     = "0da42c8d2132a9ddaf714f9e7c920711"
+}
+
+`, buf.String())
+}
+
+func TestParameterize_MultipleZones(t *testing.T) {
+	f := parseTestHCL(t, `resource "cloudflare_page_rule" "a" {
+  account_id = "f037e56e89293a057740de681ac9abbe"
+  zone_id    = "0da42c8d2132a9ddaf714f9e7c920711"
+}
+
+resource "cloudflare_page_rule" "b" {
+  zone_id = "1d5fdc9e88c8a8c4518b068cd94331fe"
+}
+
+resource "cloudflare_page_rule" "c" {
+  zone_id = var.existing
+}
+`)
+
+	names := map[string]string{
+		"0da42c8d2132a9ddaf714f9e7c920711": "example.com",
+	}
+	params := newIDParameters("f037e56e89293a057740de681ac9abbe", "", func(id string) string { return names[id] })
+	params.parameterizeFile(f)
+
+	var buf bytes.Buffer
+	buf.Write(hclwrite.Format(f.Bytes()))
+	writeIDDefinitions(&buf, params)
+
+	assert.Equal(t, `resource "cloudflare_page_rule" "a" {
+  account_id = var.account_id
+  zone_id    = local.zone_ids["example.com"]
+}
+
+resource "cloudflare_page_rule" "b" {
+  zone_id = local.zone_ids["1d5fdc9e88c8a8c4518b068cd94331fe"]
+}
+
+resource "cloudflare_page_rule" "c" {
+  zone_id = var.existing
+}
+variable "account_id" {
+  type        = string
+  description = "Cloudflare account ID"
+  default     = "f037e56e89293a057740de681ac9abbe"
+}
+
+locals {
+  zone_ids = {
+    "1d5fdc9e88c8a8c4518b068cd94331fe" = "1d5fdc9e88c8a8c4518b068cd94331fe"
+    "example.com"                      = "0da42c8d2132a9ddaf714f9e7c920711"
+  }
+}
+
+`, buf.String())
+}
+
+func TestMergeGeneratedConfig_Definitions(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte(`locals {
+  zone_ids = {
+    "example.com" = "0da42c8d2132a9ddaf714f9e7c920711"
+  }
+}
+
+variable "account_id" {}
+`), 0o644))
+
+	_, err := mergeGeneratedConfig(dir, []byte(`variable "account_id" {
+  default = "f037e56e89293a057740de681ac9abbe"
+}
+
+variable "zone_id" {
+  default = "0da42c8d2132a9ddaf714f9e7c920711"
+}
+
+locals {
+  zone_ids = {
+    "example.com" = "0da42c8d2132a9ddaf714f9e7c920711"
+    "example.net" = "1d5fdc9e88c8a8c4518b068cd94331fe"
+  }
+}
+`))
+	require.NoError(t, err)
+
+	main, err := os.ReadFile(filepath.Join(dir, "main.tf"))
+	require.NoError(t, err)
+	assert.Equal(t, `locals {
+  zone_ids = {
+    "example.com" = "0da42c8d2132a9ddaf714f9e7c920711"
+    "example.net" = "1d5fdc9e88c8a8c4518b068cd94331fe"
+  }
+}
+
+variable "account_id" {}
+`, string(main))
+
+	vars, err := os.ReadFile(filepath.Join(dir, variablesFilename))
+	require.NoError(t, err)
+	assert.Equal(t, `variable "zone_id" {
+  default = "0da42c8d2132a9ddaf714f9e7c920711"
+}
+`, string(vars))
+
+	_, err = os.Stat(filepath.Join(dir, "locals.tf"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -20,6 +20,23 @@ var (
 
 	verbose, useModernImportBlock bool
 
+	useImportIdentity, useImportForEach, useForEach bool
+	forEachDataDir                                  string
+
+	collectionThreshold             int
+	collectionFormat, collectionDir string
+
+	verifyGenerated, excludeManaged, useStateRm, explainGenerated bool
+
+	useSensitiveVariables, writeSecrets, parameterizeIDs bool
+	variablesDir                                         string
+
+	outputFormat, generateFromStatePath, mergeIntoDir, moduleDir string
+
+	verifyImports bool
+
+	rulesetPhases, rulesetKinds []string
+
 	apiV0 *cfv0.API
 	api   *cloudflare.Client
 
@@ -60,6 +77,8 @@ func init() {
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Specify verbose output (same as setting log level to debug)")
 	rootCmd.PersistentFlags().StringVar(&resourceType, "resource-type", "", "Comma delimitered string of which resource(s) you wish to generate")
 	rootCmd.PersistentFlags().BoolVarP(&useModernImportBlock, "modern-import-block", "", false, "Whether to generate HCL import blocks for generated resources instead of terraform import compatible CLI commands. This is only compatible with Terraform 1.5+")
+	rootCmd.PersistentFlags().BoolVar(&useImportIdentity, "import-identity", false, "Use resource identity objects instead of string IDs in import blocks for resources that support it. This is only compatible with Terraform 1.12+")
+	rootCmd.PersistentFlags().BoolVar(&useImportForEach, "import-for-each", false, "Generate a single for_each import block per resource type when importing more than one resource of that type")
 
 	rootCmd.PersistentFlags().StringVarP(&zoneID, "zone", "z", "", "Target the provided zone ID for the command")
 	if err = viper.BindPFlag("zone", rootCmd.PersistentFlags().Lookup("zone")); err != nil {
@@ -174,26 +193,28 @@ func getResourceMappings() map[string][]string {
 		"cloudflare_hostname_tls_setting": make([]string, 0),
 	}
 
+	// the flag values are split on commas so any value without a type is a
+	// continuation of the previous mapping.
+	rType := ""
 	for _, mapping := range resourceIDFlags {
-		parts := strings.Split(mapping, "=")
-
-		rType := strings.TrimSpace(parts[0])
-		_, ok := settingsMap[rType]
-		if !ok {
-			log.Fatalf("unsupported resource type: %s", rType)
+		setting := mapping
+		if t, v, ok := strings.Cut(mapping, "="); ok {
+			rType = strings.TrimSpace(t)
+			if _, ok := settingsMap[rType]; !ok {
+				log.Fatalf("unsupported resource type: %s", rType)
+			}
+			setting = v
 		}
-		settingsString := parts[1]
 
-		settings := strings.Split(settingsString, ",")
+		if rType == "" {
+			log.Fatalf("missing resource type for %q", mapping)
+		}
 
-		var cleanSettings []string
-		for _, setting := range settings {
-			setting = strings.TrimSpace(setting)
-			if setting != "" {
-				cleanSettings = append(cleanSettings, setting)
+		for _, s := range strings.Split(setting, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				settingsMap[rType] = append(settingsMap[rType], s)
 			}
 		}
-		settingsMap[rType] = cleanSettings
 	}
 
 	return settingsMap
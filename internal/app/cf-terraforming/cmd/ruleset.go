@@ -0,0 +1,284 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// rulesetLogCustomFields are the action parameters of `log_custom_field`
+// rules listing the fields to log.
+var rulesetLogCustomFields = []string{"cookie_fields", "raw_response_fields", "request_fields", "response_fields", "transformed_request_fields"}
+
+// rulesetSelected reports whether a ruleset should be generated. Managed
+// rulesets are owned by Cloudflare and are never generated, otherwise the
+// ruleset must match `--ruleset-kind` and `--ruleset-phase` when set.
+func rulesetSelected(kind, phase string) bool {
+	if kind == "managed" {
+		return false
+	}
+	if len(rulesetKinds) > 0 && !contains(rulesetKinds, kind) {
+		return false
+	}
+	if len(rulesetPhases) > 0 && !contains(rulesetPhases, phase) {
+		return false
+	}
+
+	return true
+}
+
+// selectRulesets filters the rulesets returned by the API and sorts them by
+// phase and name so the output is deterministic.
+func selectRulesets(rulesets []interface{}) []interface{} {
+	var selected []interface{}
+	for _, r := range rulesets {
+		ruleset, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		kind, _ := ruleset["kind"].(string)
+		phase, _ := ruleset["phase"].(string)
+		if rulesetSelected(kind, phase) {
+			selected = append(selected, ruleset)
+		}
+	}
+
+	sort.SliceStable(selected, func(i, j int) bool {
+		a, b := selected[i].(map[string]interface{}), selected[j].(map[string]interface{})
+		if fmt.Sprint(a["phase"]) != fmt.Sprint(b["phase"]) {
+			return fmt.Sprint(a["phase"]) < fmt.Sprint(b["phase"])
+		}
+		return fmt.Sprint(a["name"]) < fmt.Sprint(b["name"])
+	})
+
+	return selected
+}
+
+// expandRulesets replaces the rulesets returned by the list endpoint, which
+// don't include their rules, with the full ruleset. Phase entrypoints and
+// custom rulesets are fetched the same way for both accounts and zones.
+func expandRulesets(rulesets []interface{}) []interface{} {
+	expanded := make([]interface{}, 0, len(rulesets))
+	for _, r := range rulesets {
+		ruleset := r.(map[string]interface{})
+		if _, ok := ruleset["rules"]; ok {
+			expanded = append(expanded, ruleset)
+			continue
+		}
+
+		id, _ := ruleset["id"].(string)
+		full, err := fetchRuleset(id)
+		if err != nil {
+			log.Warnf("failed to fetch the rules of ruleset %s: %s", id, err)
+			continue
+		}
+		expanded = append(expanded, full)
+	}
+
+	return expanded
+}
+
+// fetchRuleset retrieves a single ruleset with its rules.
+func fetchRuleset(id string) (map[string]interface{}, error) {
+	endpoint := resolveEndpointScope(resourceToEndpoint["cloudflare_ruleset"]["get"])
+	endpoint = strings.Replace(endpoint, "{ruleset_id}", url.PathEscape(id), 1)
+
+	return getRuleset(endpoint)
+}
+
+// fetchZoneEntrypoint retrieves the entrypoint ruleset of a phase for a zone,
+// or nil when the zone doesn't have one.
+func fetchZoneEntrypoint(zone, phase string) (map[string]interface{}, error) {
+	ruleset, err := getRuleset(fmt.Sprintf("/zones/%s/rulesets/phases/%s/entrypoint", url.PathEscape(zone), url.PathEscape(phase)))
+	if isNotFound(err) {
+		return nil, nil
+	}
+
+	return ruleset, err
+}
+
+// getRuleset retrieves the ruleset at endpoint.
+func getRuleset(endpoint string) (map[string]interface{}, error) {
+	var result *http.Response
+	if err := api.Get(context.Background(), endpoint, nil, &result); err != nil {
+		return nil, err
+	}
+	defer result.Body.Close()
+
+	body, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	value := gjson.Get(string(body), "result")
+	if value.Type == gjson.Null {
+		return nil, errNoResult
+	}
+
+	var ruleset map[string]interface{}
+	if err := json.Unmarshal([]byte(value.Raw), &ruleset); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return ruleset, nil
+}
+
+// processRulesetsV5 prepares the rules of each ruleset for the v5 schema.
+func processRulesetsV5(rulesets []interface{}) {
+	for _, r := range rulesets {
+		ruleset, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		rules, ok := ruleset["rules"].([]interface{})
+		if !ok {
+			continue
+		}
+
+		for _, rr := range rules {
+			rule, ok := rr.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			// the rule IDs are computed and a `ref` matching the ID is the
+			// default so neither is tracked.
+			if rule["ref"] == rule["id"] {
+				rule["ref"] = nil
+			}
+			rule["id"] = nil
+
+			// the version and last update of a rule are computed and can't
+			// be set in configuration.
+			delete(rule, "version")
+			delete(rule, "last_updated")
+
+			params, ok := rule["action_parameters"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			if headers, ok := params["headers"]; ok {
+				params["headers"] = rulesetHeadersV5(headers)
+			}
+			for _, name := range rulesetLogCustomFields {
+				if fields, ok := params[name].([]interface{}); ok {
+					params[name] = rulesetLogFieldsV5(fields)
+				}
+			}
+			if rule["action"] == "skip" {
+				if skipped, ok := params["rules"].(map[string]interface{}); ok {
+					params["rules"] = rulesetSkipRulesV5(skipped)
+				}
+			}
+			if cacheKey, ok := params["cache_key"].(map[string]interface{}); ok {
+				if customKey, ok := cacheKey["custom_key"].(map[string]interface{}); ok {
+					if queryString, ok := customKey["query_string"].(map[string]interface{}); ok {
+						rulesetQueryStringV5(queryString)
+					}
+				}
+			}
+		}
+	}
+}
+
+// rulesetHeadersV5 converts the headers of a rewrite rule into the map keyed
+// by header name that v5 uses. Headers listed with their name, as v4 did, are
+// remapped and empty values and expressions are dropped.
+func rulesetHeadersV5(headers interface{}) map[string]interface{} {
+	remapped := make(map[string]interface{})
+	add := func(name string, header map[string]interface{}) {
+		h := make(map[string]interface{})
+		for k, v := range header {
+			if k == "name" || v == nil || v == "" {
+				continue
+			}
+			h[k] = v
+		}
+		remapped[name] = h
+	}
+
+	switch h := headers.(type) {
+	case map[string]interface{}:
+		for name, header := range h {
+			if m, ok := header.(map[string]interface{}); ok {
+				add(name, m)
+			}
+		}
+	case []interface{}:
+		for _, header := range h {
+			if m, ok := header.(map[string]interface{}); ok {
+				add(fmt.Sprint(m["name"]), m)
+			}
+		}
+	}
+
+	return remapped
+}
+
+// rulesetLogFieldsV5 converts the fields of a `log_custom_field` rule into
+// objects with only a name, which is how v5 takes them rather than the
+// flattened list of names v4 used.
+func rulesetLogFieldsV5(fields []interface{}) []interface{} {
+	converted := make([]interface{}, 0, len(fields))
+	for _, f := range fields {
+		switch field := f.(type) {
+		case string:
+			converted = append(converted, map[string]interface{}{"name": field})
+		case map[string]interface{}:
+			converted = append(converted, map[string]interface{}{"name": field["name"]})
+		}
+	}
+
+	return converted
+}
+
+// rulesetSkipRulesV5 converts the rules skipped by a skip rule, keyed by
+// ruleset ID, into lists of rule IDs. Comma separated IDs, as v4 used, are
+// split.
+func rulesetSkipRulesV5(rules map[string]interface{}) map[string]interface{} {
+	remapped := make(map[string]interface{}, len(rules))
+	for rulesetID, ids := range rules {
+		switch v := ids.(type) {
+		case string:
+			var list []interface{}
+			for _, id := range strings.Split(v, ",") {
+				list = append(list, strings.TrimSpace(id))
+			}
+			remapped[rulesetID] = list
+		default:
+			remapped[rulesetID] = v
+		}
+	}
+
+	return remapped
+}
+
+// rulesetQueryStringV5 converts the included and excluded query string
+// parameters of a custom cache key into the v5 form, where a wildcard
+// includes or excludes all parameters and anything else is a list.
+func rulesetQueryStringV5(queryString map[string]interface{}) {
+	for _, name := range []string{"include", "exclude"} {
+		switch v := queryString[name].(type) {
+		case string:
+			if v == "*" {
+				queryString[name] = map[string]interface{}{"all": true}
+			} else {
+				queryString[name] = map[string]interface{}{"list": []interface{}{v}}
+			}
+		case []interface{}:
+			if len(v) == 1 && v[0] == "*" {
+				queryString[name] = map[string]interface{}{"all": true}
+			} else {
+				queryString[name] = map[string]interface{}{"list": v}
+			}
+		}
+	}
+}
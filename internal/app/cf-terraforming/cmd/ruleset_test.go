@@ -0,0 +1,399 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go/v4"
+	"github.com/cloudflare/cloudflare-go/v4/option"
+	"github.com/dnaeon/go-vcr/recorder"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestRulesetSelected(t *testing.T) {
+	assert.True(t, rulesetSelected("zone", "http_request_firewall_custom"))
+	assert.True(t, rulesetSelected("root", "http_request_firewall_managed"))
+	assert.False(t, rulesetSelected("managed", "http_request_firewall_managed"))
+
+	rulesetPhases = []string{"http_request_cache_settings"}
+	rulesetKinds = []string{"zone"}
+	defer func() { rulesetPhases, rulesetKinds = nil, nil }()
+
+	assert.True(t, rulesetSelected("zone", "http_request_cache_settings"))
+	assert.False(t, rulesetSelected("zone", "http_request_firewall_custom"))
+	assert.False(t, rulesetSelected("custom", "http_request_cache_settings"))
+}
+
+func TestRulesetSelectRulesets(t *testing.T) {
+	rulesets := []interface{}{
+		map[string]interface{}{"id": "b", "kind": "zone", "name": "default", "phase": "http_request_firewall_custom"},
+		map[string]interface{}{"id": "m", "kind": "managed", "name": "Cloudflare Managed Ruleset", "phase": "http_request_firewall_managed"},
+		map[string]interface{}{"id": "c", "kind": "custom", "name": "b custom", "phase": "http_request_firewall_custom"},
+		map[string]interface{}{"id": "a", "kind": "zone", "name": "default", "phase": "http_request_cache_settings"},
+	}
+
+	assert.Equal(t, []interface{}{rulesets[3], rulesets[2], rulesets[0]}, selectRulesets(rulesets))
+
+	rulesetKinds = []string{"custom"}
+	defer func() { rulesetKinds = nil }()
+	assert.Equal(t, []interface{}{rulesets[2]}, selectRulesets(rulesets))
+}
+
+func TestRulesetProcessRulesetsV5(t *testing.T) {
+	rulesets := []interface{}{
+		map[string]interface{}{
+			"id":    "0a1b2c",
+			"kind":  "zone",
+			"phase": "http_request_late_transform",
+			"rules": []interface{}{
+				map[string]interface{}{
+					"id":     "3d4e5f",
+					"ref":    "3d4e5f",
+					"action": "rewrite",
+					"action_parameters": map[string]interface{}{
+						"headers": map[string]interface{}{
+							"x-source":  map[string]interface{}{"operation": "set", "value": "cloudflare", "expression": ""},
+							"x-removed": map[string]interface{}{"operation": "remove"},
+						},
+					},
+				},
+				map[string]interface{}{
+					"id":     "6a7b8c",
+					"ref":    "my_ref",
+					"action": "rewrite",
+					"action_parameters": map[string]interface{}{
+						"headers": []interface{}{
+							map[string]interface{}{"name": "x-colo", "operation": "set", "expression": "cf.colo.id", "value": nil},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	processRulesetsV5(rulesets)
+
+	rules := rulesets[0].(map[string]interface{})["rules"].([]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"id":     nil,
+		"ref":    nil,
+		"action": "rewrite",
+		"action_parameters": map[string]interface{}{
+			"headers": map[string]interface{}{
+				"x-source":  map[string]interface{}{"operation": "set", "value": "cloudflare"},
+				"x-removed": map[string]interface{}{"operation": "remove"},
+			},
+		},
+	}, rules[0])
+	assert.Equal(t, map[string]interface{}{
+		"id":     nil,
+		"ref":    "my_ref",
+		"action": "rewrite",
+		"action_parameters": map[string]interface{}{
+			"headers": map[string]interface{}{
+				"x-colo": map[string]interface{}{"operation": "set", "expression": "cf.colo.id"},
+			},
+		},
+	}, rules[1])
+}
+
+func TestRulesetLogFieldsV5(t *testing.T) {
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "cf.bot_score"},
+		map[string]interface{}{"name": "x-request-id"},
+	}, rulesetLogFieldsV5([]interface{}{
+		map[string]interface{}{"name": "cf.bot_score"},
+		"x-request-id",
+	}))
+}
+
+func TestRulesetSkipRulesV5(t *testing.T) {
+	assert.Equal(t, map[string]interface{}{
+		"efb7b8c949ac4650a09736fc376e9aee": []interface{}{"5de7edfa648c4d6891dc3e7f84534ffa", "e3a567afc347477d9702d9047e97d760"},
+		"4814384a9e5d4991b9815dcfc25d2f1f": []interface{}{"6179ae15870a4bb7b2d480d4843b323c"},
+	}, rulesetSkipRulesV5(map[string]interface{}{
+		"efb7b8c949ac4650a09736fc376e9aee": "5de7edfa648c4d6891dc3e7f84534ffa,e3a567afc347477d9702d9047e97d760",
+		"4814384a9e5d4991b9815dcfc25d2f1f": []interface{}{"6179ae15870a4bb7b2d480d4843b323c"},
+	}))
+}
+
+func TestRulesetQueryStringV5(t *testing.T) {
+	rulesets := []interface{}{
+		map[string]interface{}{
+			"rules": []interface{}{
+				map[string]interface{}{
+					"action": "set_cache_settings",
+					"action_parameters": map[string]interface{}{
+						"cache_key": map[string]interface{}{
+							"custom_key": map[string]interface{}{
+								"query_string": map[string]interface{}{
+									"include": []interface{}{"*"},
+									"exclude": []interface{}{"utm_source", "utm_medium"},
+								},
+							},
+						},
+					},
+				},
+				map[string]interface{}{
+					"action": "set_cache_settings",
+					"action_parameters": map[string]interface{}{
+						"cache_key": map[string]interface{}{
+							"custom_key": map[string]interface{}{
+								"query_string": map[string]interface{}{"exclude": "*"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	processRulesetsV5(rulesets)
+
+	rules := rulesets[0].(map[string]interface{})["rules"].([]interface{})
+	queryString := func(i int) interface{} {
+		return rules[i].(map[string]interface{})["action_parameters"].(map[string]interface{})["cache_key"].(map[string]interface{})["custom_key"].(map[string]interface{})["query_string"]
+	}
+	assert.Equal(t, map[string]interface{}{
+		"include": map[string]interface{}{"all": true},
+		"exclude": map[string]interface{}{"list": []interface{}{"utm_source", "utm_medium"}},
+	}, queryString(0))
+	assert.Equal(t, map[string]interface{}{
+		"exclude": map[string]interface{}{"all": true},
+	}, queryString(1))
+}
+
+// useRulesetCassette points the API client at a recorded cassette and scopes
+// the requests to the account or zone for the duration of the test.
+func useRulesetCassette(t *testing.T, name, account, zone string) {
+	r, err := recorder.New("../../../../testdata/cloudflare/v5/" + name)
+	require.NoError(t, err)
+
+	previousAPI, previousAccount, previousZone := api, accountID, zoneID
+	t.Cleanup(func() {
+		api, accountID, zoneID = previousAPI, previousAccount, previousZone
+		require.NoError(t, r.Stop())
+	})
+
+	api = cloudflare.NewClient(option.WithHTTPClient(&http.Client{Transport: r}), option.WithAPIToken("token"), option.WithMaxRetries(0))
+	accountID, zoneID = account, zone
+}
+
+func TestExpandResourcesV5_AccountRulesets(t *testing.T) {
+	useRulesetCassette(t, "cloudflare_ruleset_account", cloudflareTestAccountID, "")
+
+	rulesets, err := fetchResourcesV5("cloudflare_ruleset", nil)
+	require.NoError(t, err)
+
+	// the managed ruleset is dropped before anything is fetched and the list
+	// endpoint doesn't include the rules.
+	require.Len(t, rulesets, 2)
+	for _, r := range rulesets {
+		assert.NotContains(t, r, "rules")
+	}
+
+	rulesets = expandResourcesV5("cloudflare_ruleset", rulesets)
+	require.Len(t, rulesets, 2)
+
+	custom := rulesets[0].(map[string]interface{})
+	assert.Equal(t, "Block scanners", custom["name"])
+	assert.Equal(t, "custom", custom["kind"])
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"id":          nil,
+		"ref":         nil,
+		"action":      "block",
+		"expression":  `(http.user_agent contains "sqlmap")`,
+		"description": "Block sqlmap",
+		"enabled":     true,
+	}}, custom["rules"])
+
+	entrypoint := rulesets[1].(map[string]interface{})
+	assert.Equal(t, "root", entrypoint["kind"])
+	assert.Equal(t, "http_request_firewall_custom", entrypoint["phase"])
+	rules := entrypoint["rules"].([]interface{})
+	require.Len(t, rules, 1)
+	assert.Equal(t, "deploy_block_scanners", rules[0].(map[string]interface{})["ref"])
+	assert.Equal(t, map[string]interface{}{
+		"id":      "2f2feab2026849078ba485f918791bdc",
+		"version": "latest",
+	}, rules[0].(map[string]interface{})["action_parameters"])
+}
+
+func TestExpandResourcesV5_ZoneEntrypoints(t *testing.T) {
+	useRulesetCassette(t, "cloudflare_ruleset_zone_entrypoints", "", cloudflareTestZoneID)
+	hook := test.NewLocal(log)
+	defer hook.Reset()
+
+	rulesets, err := fetchResourcesV5("cloudflare_ruleset", nil)
+	require.NoError(t, err)
+	require.Len(t, rulesets, 2)
+
+	// the cache settings entrypoint can't be fetched so it's left out rather
+	// than generated without its rules.
+	rulesets = expandResourcesV5("cloudflare_ruleset", rulesets)
+	require.Len(t, rulesets, 1)
+	require.NotNil(t, hook.LastEntry())
+	assert.Contains(t, hook.LastEntry().Message, "failed to fetch the rules of ruleset a6905ff86d3844cebc1a88dd80c659e7")
+
+	transform := rulesets[0].(map[string]interface{})
+	assert.Equal(t, "http_request_late_transform", transform["phase"])
+	rules := transform["rules"].([]interface{})
+	require.Len(t, rules, 1)
+	assert.Equal(t, map[string]interface{}{
+		"headers": map[string]interface{}{
+			"x-source":     map[string]interface{}{"operation": "set", "value": "cloudflare"},
+			"x-powered-by": map[string]interface{}{"operation": "remove"},
+		},
+	}, rules[0].(map[string]interface{})["action_parameters"])
+}
+
+func TestFetchResourcesV5_RulesetsNotExpanded(t *testing.T) {
+	// only the list endpoint is recorded for the zone so fetching any of the
+	// rulesets individually would fail.
+	useRulesetCassette(t, "cloudflare_ruleset_zone_ddos_l7", "", cloudflareTestZoneID)
+
+	rulesets, err := fetchResourcesV5("cloudflare_ruleset", nil)
+	require.NoError(t, err)
+	require.Len(t, rulesets, 1)
+	assert.Equal(t, "76d07b645c674faba69eff8d8451992c", rulesets[0].(map[string]interface{})["id"])
+	assert.NotContains(t, rulesets[0], "rules")
+}
+
+// rulesetSchemaV5 is the part of the v5 `cloudflare_ruleset` schema needed to
+// write rulesets. The rules are a list nested attribute whose values are
+// written as returned once processed.
+var rulesetSchemaV5 = &tfjson.Schema{
+	Block: &tfjson.SchemaBlock{
+		Attributes: map[string]*tfjson.SchemaAttribute{
+			"id":          {AttributeType: cty.String, Computed: true},
+			"account_id":  {AttributeType: cty.String, Optional: true},
+			"zone_id":     {AttributeType: cty.String, Optional: true},
+			"description": {AttributeType: cty.String, Optional: true, Computed: true},
+			"kind":        {AttributeType: cty.String, Required: true},
+			"name":        {AttributeType: cty.String, Required: true},
+			"phase":       {AttributeType: cty.String, Required: true},
+			"rules": {
+				Optional: true,
+				AttributeNestedType: &tfjson.SchemaNestedAttributeType{
+					NestingMode: tfjson.SchemaNestingModeList,
+					Attributes: map[string]*tfjson.SchemaAttribute{
+						"id":                {AttributeType: cty.String, Computed: true},
+						"action":            {AttributeType: cty.String, Optional: true},
+						"action_parameters": {Optional: true},
+						"description":       {AttributeType: cty.String, Optional: true},
+						"enabled":           {AttributeType: cty.Bool, Optional: true},
+						"expression":        {AttributeType: cty.String, Optional: true},
+						"logging":           {Optional: true},
+						"ref":               {AttributeType: cty.String, Optional: true},
+					},
+				},
+			},
+		},
+	},
+}
+
+func TestGenerateRulesetsV5(t *testing.T) {
+	tests := map[string]struct {
+		cassette string
+		expected []string
+	}{
+		"headers remapped by name": {
+			cassette: "cloudflare_ruleset_zone_http_request_late_transform",
+			expected: []string{
+				`phase   = "http_request_late_transform"`,
+				`example-http-header-1 = {
+          operation = "remove"
+        }`,
+				`example-http-header-3 = {
+          expression = "(ip.geoip.continent eq \"pluto\")"
+          operation  = "set"
+        }`,
+				`example-http-static-header-1 = {
+          operation = "set"
+          value     = "my-http-header-1"
+        }`,
+			},
+		},
+		"log custom fields": {
+			cassette: "cloudflare_ruleset_zone_http_log_custom_fields",
+			expected: []string{
+				`action = "log_custom_field"`,
+				`cookie_fields = [{
+        name = "cookie"
+        }, {
+        name = "fields"
+      }]`,
+				`request_fields = [{
+        name = "request"
+        }, {
+        name = "fields"
+      }]`,
+			},
+		},
+		"skip rules": {
+			cassette: "cloudflare_ruleset_zone_http_request_firewall_managed",
+			expected: []string{
+				`action = "skip"`,
+				`"4814384a9e5d4991b9815dcfc25d2f1f" = ["37da7855d2f94f69865365d894a556a4", "6afe6795ee6a48d6a1dfe59255395a78", "5a6f5a57cde8428ab0668ce17cdec0c8", "5e4903d6afa841c9b88b96203297003f", "2380cd409b604c2a9273042f3eb29c4e", "f5aebedc99a14c8d9e8cfa2ce5f94216", "edf8c37cc81747d382690b3c77e82ce4", "1129dfb383bb42e48466488cf3b37cb1"]`,
+			},
+		},
+		"skip phases and products": {
+			cassette: "cloudflare_ruleset_zone_http_request_firewall_custom",
+			expected: []string{
+				`phases   = ["http_ratelimit", "http_request_firewall_managed"]`,
+				`ruleset  = "current"`,
+			},
+		},
+		"cache key wildcard": {
+			cassette: "cloudflare_ruleset_http_request_cache_settings",
+			expected: []string{
+				`query_string = {
+            exclude = {
+              all = true
+            }
+          }`,
+			},
+		},
+		"zone ruleset": {
+			cassette: "cloudflare_ruleset_zone",
+			expected: []string{
+				`name    = "Zone sanitize ruleset"`,
+				`phase   = "http_request_sanitize"`,
+				`id      = "78723a9e0c7c4c6dbec5684cb766231d"`,
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			useRulesetCassette(t, tc.cassette, "", cloudflareTestZoneID)
+
+			rulesets, err := fetchResourcesV5("cloudflare_ruleset", nil)
+			require.NoError(t, err)
+			rulesets = expandResourcesV5("cloudflare_ruleset", rulesets)
+			require.NotEmpty(t, rulesets)
+
+			f := hclwrite.NewEmptyFile()
+			for i, r := range rulesets {
+				resource := f.Body().AppendNewBlock("resource", []string{"cloudflare_ruleset", fmt.Sprintf("terraform_managed_resource_%d", i)}).Body()
+				writeResourceBody(rulesetSchemaV5, r.(map[string]interface{}), resource)
+				f.Body().AppendNewline()
+			}
+			output := string(hclwrite.Format(f.Bytes()))
+
+			assert.Contains(t, output, `zone_id = "0da42c8d2132a9ddaf714f9e7c920711"`)
+			for _, expected := range tc.expected {
+				assert.Contains(t, output, expected)
+			}
+			// computed attributes of the rules aren't written.
+			assert.NotContains(t, output, "last_updated")
+			assert.NotRegexp(t, `version\s+= "\d+"`, output)
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const (
+	variablesFilename = "variables.tf"
+	secretsFilename   = "secrets.auto.tfvars"
+)
+
+// redactedValue matches the placeholders the Cloudflare API returns in place
+// of secrets, such as `********`, `****1234` or `[REDACTED]`.
+var redactedValue = regexp.MustCompile(`(?i)^(\*{3,}[^*]{0,4}|\[redacted\]|<redacted>)$`)
+
+// isRedactedValue reports whether an API value is a redacted secret rather
+// than the real value.
+func isRedactedValue(v interface{}) bool {
+	s, ok := v.(string)
+	return ok && redactedValue.MatchString(s)
+}
+
+// sensitiveValues are the values removed from an API result so that they can
+// be replaced by variable references once the resource has been written.
+type sensitiveValues struct {
+	variables []generatedVariable
+	// objects are nested attributes with some values replaced by variable
+	// references, keyed by attribute name.
+	objects map[string]interface{}
+}
+
+// extractSensitiveValues removes every attribute the schema marks as
+// sensitive, or that holds a redacted value, from structData ahead of it being
+// written. The attributes of single, list and set nested attributes are
+// checked one level deep.
+func extractSensitiveValues(r *tfjson.Schema, resourceType, resourceName string, structData map[string]interface{}) sensitiveValues {
+	sv := sensitiveValues{
+		objects: make(map[string]interface{}),
+	}
+
+	names := make([]string, 0, len(r.Block.Attributes))
+	for name := range r.Block.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		attr := r.Block.Attributes[name]
+		value := structData[name]
+		if name == "id" || value == nil || (attr.Computed && !attr.Optional) {
+			continue
+		}
+
+		if attr.Sensitive || isRedactedValue(value) {
+			sv.variables = append(sv.variables, sensitiveVariable(resourceType, resourceName, name, attr.AttributeType, value))
+			delete(structData, name)
+			continue
+		}
+
+		if attr.AttributeNestedType == nil {
+			continue
+		}
+
+		var replaced interface{}
+		var variables []generatedVariable
+		switch attr.AttributeNestedType.NestingMode {
+		case tfjson.SchemaNestingModeSingle:
+			obj, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			replaced, variables = replaceSensitiveAttributes(attr.AttributeNestedType.Attributes, resourceType, resourceName, name, obj)
+		case tfjson.SchemaNestingModeList, tfjson.SchemaNestingModeSet:
+			items, ok := value.([]interface{})
+			if !ok {
+				continue
+			}
+			replacedItems := make([]interface{}, len(items))
+			for i, item := range items {
+				obj, ok := item.(map[string]interface{})
+				if !ok {
+					replacedItems[i] = item
+					continue
+				}
+				var itemVariables []generatedVariable
+				replacedItems[i], itemVariables = replaceSensitiveAttributes(attr.AttributeNestedType.Attributes, resourceType, resourceName, fmt.Sprintf("%s.%d", name, i), obj)
+				variables = append(variables, itemVariables...)
+			}
+			replaced = replacedItems
+		}
+
+		if len(variables) > 0 {
+			sv.variables = append(sv.variables, variables...)
+			sv.objects[name] = replaced
+			delete(structData, name)
+		}
+	}
+
+	return sv
+}
+
+// replaceSensitiveAttributes returns a copy of the nested object at path with
+// the sensitive and redacted values replaced by references to the variables
+// that are returned.
+func replaceSensitiveAttributes(attributes map[string]*tfjson.SchemaAttribute, resourceType, resourceName, path string, obj map[string]interface{}) (map[string]interface{}, []generatedVariable) {
+	replaced := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		replaced[k] = v
+	}
+
+	var variables []generatedVariable
+	for _, k := range sortedKeys(obj) {
+		inner := attributes[k]
+		if inner == nil || obj[k] == nil || (inner.Computed && !inner.Optional) {
+			continue
+		}
+		if inner.Sensitive || isRedactedValue(obj[k]) {
+			v := sensitiveVariable(resourceType, resourceName, path+"."+k, inner.AttributeType, obj[k])
+			variables = append(variables, v)
+			replaced[k] = variableReference(v.Name)
+		}
+	}
+
+	return replaced, variables
+}
+
+// sensitiveVariable builds the variable replacing a sensitive value. Redacted
+// values aren't kept as the real value is unknown.
+func sensitiveVariable(resourceType, resourceName, path string, ty cty.Type, value interface{}) generatedVariable {
+	v := generatedVariable{
+		Name:      placeholderVariableName(resourceType, resourceName, strings.ReplaceAll(path, ".", "_")),
+		Type:      ty,
+		Sensitive: true,
+		Address:   resourceType + "." + resourceName,
+		Attribute: path,
+	}
+
+	if isRedactedValue(value) {
+		v.Description = fmt.Sprintf("Value of %s for %s.%s. The Cloudflare API redacts it so it must be provided.", path, resourceType, resourceName)
+		return v
+	}
+
+	v.Description = fmt.Sprintf("Sensitive value of %s for %s.%s.", path, resourceType, resourceName)
+	v.Value = processExpression(value)
+
+	return v
+}
+
+// writeSensitiveReferences sets the attributes removed by
+// extractSensitiveValues to reference their variables.
+func writeSensitiveReferences(body *hclwrite.Body, sv sensitiveValues) {
+	for _, v := range sv.variables {
+		if !strings.Contains(v.Attribute, ".") {
+			body.SetAttributeTraversal(v.Attribute, variableTraversal(v.Name))
+		}
+	}
+
+	names := make([]string, 0, len(sv.objects))
+	for name := range sv.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		body.SetAttributeRaw(name, configValueTokens(sv.objects[name]))
+	}
+}
+
+// writeVariablesFile declares the variables in variables.tf within dir,
+// keeping any existing content and skipping variables already declared.
+func writeVariablesFile(dir string, variables []generatedVariable) error {
+	path := filepath.Join(dir, variablesFilename)
+	f, err := parseOrCreateHCLFile(path)
+	if err != nil {
+		return err
+	}
+
+	declared := make(map[string]bool)
+	for _, block := range f.Body().Blocks() {
+		if block.Type() == "variable" && len(block.Labels()) == 1 {
+			declared[block.Labels()[0]] = true
+		}
+	}
+
+	changed := false
+	for _, v := range variables {
+		if declared[v.Name] {
+			continue
+		}
+		declared[v.Name] = true
+		if len(f.Body().Blocks()) > 0 || len(f.Body().Attributes()) > 0 {
+			f.Body().AppendNewline()
+		}
+		appendVariableBlock(f.Body(), v)
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return os.WriteFile(path, hclwrite.Format(f.Bytes()), 0o644)
+}
+
+// writeSecretsFile writes the known sensitive values to secrets.auto.tfvars
+// within dir and makes sure git ignores it.
+func writeSecretsFile(dir string, variables []generatedVariable) error {
+	path := filepath.Join(dir, secretsFilename)
+	f, err := parseOrCreateHCLFile(path)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range variables {
+		if v.Value.IsNull() {
+			continue
+		}
+		f.Body().SetAttributeValue(v.Name, v.Value)
+	}
+
+	if err := os.WriteFile(path, hclwrite.Format(f.Bytes()), 0o600); err != nil {
+		return err
+	}
+
+	return ensureGitIgnored(dir, secretsFilename)
+}
+
+// ensureGitIgnored adds name to the .gitignore within dir unless it's already
+// listed.
+func ensureGitIgnored(dir, name string) error {
+	path := filepath.Join(dir, ".gitignore")
+	b, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, line := range strings.Split(string(b), "\n") {
+		if strings.TrimSpace(line) == name || strings.TrimSpace(line) == "/"+name {
+			return nil
+		}
+	}
+
+	if len(b) > 0 && !bytes.HasSuffix(b, []byte("\n")) {
+		b = append(b, '\n')
+	}
+	b = append(b, []byte(name+"\n")...)
+
+	return os.WriteFile(path, b, 0o644)
+}
+
+// parseOrCreateHCLFile parses the file at path or returns an empty file when
+// it doesn't exist yet.
+func parseOrCreateHCLFile(path string) (*hclwrite.File, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return hclwrite.NewEmptyFile(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f, diags := hclwrite.ParseConfig(b, path, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to parse %s: %s", path, diags.Error())
+	}
+
+	return f, nil
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestIsRedactedValue(t *testing.T) {
+	for value, expected := range map[interface{}]bool{
+		"********":      true,
+		"****1234":      true,
+		"[REDACTED]":    true,
+		"<redacted>":    true,
+		"REDACTED":      false,
+		"redacted":      false,
+		"**":            false,
+		"hunter2":       false,
+		"a****":         false,
+		"***1234567890": false,
+		42:              false,
+	} {
+		assert.Equal(t, expected, isRedactedValue(value), value)
+	}
+}
+
+func TestSensitiveVariables(t *testing.T) {
+	useSensitiveVariables = true
+	defer func() { useSensitiveVariables = false }()
+
+	schema := &tfjson.Schema{
+		Block: &tfjson.SchemaBlock{
+			Attributes: map[string]*tfjson.SchemaAttribute{
+				"account_id":       {AttributeType: cty.String, Required: true},
+				"name":             {AttributeType: cty.String, Required: true},
+				"secret":           {AttributeType: cty.String, Optional: true, Sensitive: true},
+				"destination_conf": {AttributeType: cty.String, Required: true},
+				"config": {
+					Optional: true,
+					AttributeNestedType: &tfjson.SchemaNestedAttributeType{
+						NestingMode: tfjson.SchemaNestingModeSingle,
+						Attributes: map[string]*tfjson.SchemaAttribute{
+							"client_id":     {AttributeType: cty.String, Optional: true},
+							"client_secret": {AttributeType: cty.String, Optional: true, Sensitive: true},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	f := hclwrite.NewEmptyFile()
+	resource := f.Body().AppendNewBlock("resource", []string{"cloudflare_example", "a"}).Body()
+	variables := renderResource(schema, "cloudflare_example", "a", map[string]interface{}{
+		"account_id":       "f037e56e89293a057740de681ac9abbe",
+		"name":             "example",
+		"secret":           "hunter2",
+		"destination_conf": "s3://bucket?secret-access-key=********",
+		"config": map[string]interface{}{
+			"client_id":     "abc",
+			"client_secret": "****",
+		},
+	}, resource)
+
+	assert.Equal(t, `resource "cloudflare_example" "a" {
+  account_id       = "f037e56e89293a057740de681ac9abbe"
+  destination_conf = "s3://bucket?secret-access-key=********"
+  name             = "example"
+  secret           = var.example_a_secret
+  config = {
+    client_id     = "abc"
+    client_secret = var.example_a_config_client_secret
+  }
+}
+`, string(hclwrite.Format(f.Bytes())))
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, variablesFilename), []byte("variable \"existing\" {}\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitignore"), []byte(".terraform"), 0o644))
+	require.NoError(t, writeVariablesFile(dir, variables))
+	require.NoError(t, writeSecretsFile(dir, variables))
+
+	vars, err := os.ReadFile(filepath.Join(dir, variablesFilename))
+	require.NoError(t, err)
+	assert.Equal(t, `variable "existing" {}
+
+variable "example_a_config_client_secret" {
+  type        = string
+  description = "Value of config.client_secret for cloudflare_example.a. The Cloudflare API redacts it so it must be provided."
+  sensitive   = true
+}
+
+variable "example_a_secret" {
+  type        = string
+  description = "Sensitive value of secret for cloudflare_example.a."
+  sensitive   = true
+}
+`, string(vars))
+
+	secrets, err := os.ReadFile(filepath.Join(dir, secretsFilename))
+	require.NoError(t, err)
+	assert.Equal(t, "example_a_secret = \"hunter2\"\n", string(secrets))
+
+	gitignore, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	require.NoError(t, err)
+	assert.Equal(t, ".terraform\nsecrets.auto.tfvars\n", string(gitignore))
+
+	// writing the same variables again doesn't duplicate them.
+	require.NoError(t, writeVariablesFile(dir, variables))
+	require.NoError(t, writeSecretsFile(dir, variables))
+	again, err := os.ReadFile(filepath.Join(dir, variablesFilename))
+	require.NoError(t, err)
+	assert.Equal(t, string(vars), string(again))
+	gitignore, err = os.ReadFile(filepath.Join(dir, ".gitignore"))
+	require.NoError(t, err)
+	assert.Equal(t, ".terraform\nsecrets.auto.tfvars\n", string(gitignore))
+}
+
+func TestSensitiveVariablesInNestedLists(t *testing.T) {
+	useSensitiveVariables = true
+	defer func() { useSensitiveVariables = false }()
+
+	schema := &tfjson.Schema{
+		Block: &tfjson.SchemaBlock{
+			Attributes: map[string]*tfjson.SchemaAttribute{
+				"name": {AttributeType: cty.String, Required: true},
+				"headers": {
+					Optional: true,
+					AttributeNestedType: &tfjson.SchemaNestedAttributeType{
+						NestingMode: tfjson.SchemaNestingModeList,
+						Attributes: map[string]*tfjson.SchemaAttribute{
+							"name":  {AttributeType: cty.String, Required: true},
+							"value": {AttributeType: cty.String, Required: true, Sensitive: true},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	f := hclwrite.NewEmptyFile()
+	resource := f.Body().AppendNewBlock("resource", []string{"cloudflare_example", "a"}).Body()
+	variables := renderResource(schema, "cloudflare_example", "a", map[string]interface{}{
+		"name": "redacted",
+		"headers": []interface{}{
+			map[string]interface{}{"name": "X-Auth", "value": "hunter2"},
+			map[string]interface{}{"name": "X-Key", "value": "[REDACTED]"},
+		},
+	}, resource)
+
+	assert.Equal(t, `resource "cloudflare_example" "a" {
+  name = "redacted"
+  headers = [{
+    name  = "X-Auth"
+    value = var.example_a_headers_0_value
+    }, {
+    name  = "X-Key"
+    value = var.example_a_headers_1_value
+  }]
+}
+`, string(hclwrite.Format(f.Bytes())))
+
+	if assert.Len(t, variables, 2) {
+		assert.Equal(t, "headers.0.value", variables[0].Attribute)
+		assert.Equal(t, cty.StringVal("hunter2"), variables[0].Value)
+		assert.Equal(t, "headers.1.value", variables[1].Attribute)
+		assert.Equal(t, cty.NilVal, variables[1].Value)
+	}
+}
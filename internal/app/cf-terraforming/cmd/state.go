@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/sirupsen/logrus"
+)
+
+// stateResources flattens all managed resources in the Terraform state,
+// including those nested inside of child modules, keyed by their address.
+func stateResources(state *tfjson.State) map[string]*tfjson.StateResource {
+	resources := make(map[string]*tfjson.StateResource)
+	if state == nil || state.Values == nil {
+		return resources
+	}
+
+	var walk func(module *tfjson.StateModule)
+	walk = func(module *tfjson.StateModule) {
+		if module == nil {
+			return
+		}
+
+		for _, r := range module.Resources {
+			if r.Mode != tfjson.ManagedResourceMode {
+				continue
+			}
+			resources[r.Address] = r
+		}
+
+		for _, child := range module.ChildModules {
+			walk(child)
+		}
+	}
+	walk(state.Values.RootModule)
+
+	return resources
+}
+
+// managedIDs returns the IDs of the managed resources in state grouped by
+// resource type.
+func managedIDs(resources map[string]*tfjson.StateResource) map[string]map[string]bool {
+	ids := make(map[string]map[string]bool)
+	for address, id := range stateIDs(resources) {
+		r := resources[address]
+		if ids[r.Type] == nil {
+			ids[r.Type] = make(map[string]bool)
+		}
+		ids[r.Type][id] = true
+	}
+
+	return ids
+}
+
+// excludeManagedResources removes the API results that are already tracked in
+// state, either by their ID or by the address they would be generated at.
+func excludeManagedResources(resourceType string, jsonStructData []interface{}, resources map[string]*tfjson.StateResource) []interface{} {
+	ids := managedIDs(resources)[resourceType]
+
+	unmanaged := make([]interface{}, 0, len(jsonStructData))
+	for _, data := range jsonStructData {
+		id := resourceIDFromData(data.(map[string]interface{}))
+		if ids[id] {
+			continue
+		}
+		if _, ok := resources[resourceType+"."+terraformResourceName(id)]; ok {
+			continue
+		}
+		unmanaged = append(unmanaged, data)
+	}
+
+	log.WithFields(logrus.Fields{
+		"resource": resourceType,
+		"managed":  len(jsonStructData) - len(unmanaged),
+	}).Debug("excluded resources already in state")
+
+	return unmanaged
+}
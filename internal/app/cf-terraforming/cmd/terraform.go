@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/hc-install/product"
+	"github.com/hashicorp/hc-install/releases"
+	"github.com/hashicorp/terraform-exec/tfexec"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// initTerraform configures Terraform to operate in the working directory,
+// downloading a binary if an existing one wasn't provided, and detects the
+// version of the Cloudflare provider in use. The returned function cleans up
+// any downloaded binary and must be called once Terraform is no longer needed.
+func initTerraform() (*tfexec.Terraform, string, func()) {
+	workingDir := viper.GetString("terraform-install-path")
+	execPath := viper.GetString("terraform-binary-path")
+	cleanup := func() {}
+
+	// Download terraform if no existing binary was provided
+	if execPath == "" {
+		tmpDir, err := os.MkdirTemp("", "tfinstall")
+		if err != nil {
+			log.Fatal(err)
+		}
+		cleanup = func() { os.RemoveAll(tmpDir) }
+
+		installConstraints, err := version.NewConstraint("~> 1.0")
+		if err != nil {
+			log.Fatal("failed to parse version constraints for installation version")
+		}
+
+		installer := &releases.LatestVersion{
+			Product:     product.Terraform,
+			Constraints: installConstraints,
+		}
+
+		execPath, err = installer.Install(context.Background())
+		if err != nil {
+			log.Fatalf("error installing Terraform: %s", err)
+		}
+	}
+
+	// Setup and configure Terraform to operate in the temporary directory where
+	// the provider is already configured.
+	log.WithFields(logrus.Fields{
+		"directory": workingDir,
+	}).Debug("initializing Terraform")
+	tf, err := tfexec.NewTerraform(workingDir, execPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, providerVersion, err := tf.Version(context.Background(), true)
+	if err != nil {
+		log.Fatalf("failed to retrieve terraform and provider version information: %s", err)
+	}
+
+	var registryPath string
+	for provider := range providerVersion {
+		if strings.Contains(provider, "/cloudflare/cloudflare") {
+			registryPath = provider
+			continue
+		}
+	}
+
+	detectedVersion, ok := providerVersion[registryPath]
+	if !ok {
+		log.WithFields(logrus.Fields{
+			"available_registries": providerVersion,
+		}).Fatal("failed to find registry")
+	}
+
+	providerVersionString = detectedVersion.String()
+	log.WithFields(logrus.Fields{
+		"version":  providerVersionString,
+		"registry": registryPath,
+	}).Debug("detected provider")
+
+	return tf, registryPath, cleanup
+}
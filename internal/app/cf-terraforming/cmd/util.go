@@ -2,8 +2,10 @@ package cmd
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -79,6 +81,12 @@ func sharedPreRun(cmd *cobra.Command, args []string) {
 		log.Fatal("--account and --zone are mutually exclusive, support for both is deprecated")
 	}
 
+	// Generating from state doesn't need credentials as the Cloudflare API
+	// isn't used.
+	if cmd.Flags().Changed("from-state") && generateFromStatePath != "" {
+		return
+	}
+
 	if apiToken = viper.GetString("token"); apiToken == "" {
 		if apiEmail = viper.GetString("email"); apiEmail == "" {
 			log.Error("'email' must be set.")
@@ -131,6 +139,38 @@ func sharedPreRun(cmd *cobra.Command, args []string) {
 	}
 }
 
+// resolveEndpointScope rewrites endpoints that are shared between accounts and
+// zones to the scope being targeted and fills in the account and zone IDs.
+func resolveEndpointScope(endpoint string) string {
+	if strings.Contains(endpoint, "{accounts_or_zones}") {
+		if accountID != "" {
+			endpoint = strings.Replace(endpoint, "/{accounts_or_zones}/{account_or_zone_id}/", "/accounts/{account_id}/", 1)
+		} else {
+			endpoint = strings.Replace(endpoint, "/{accounts_or_zones}/{account_or_zone_id}/", "/zones/{zone_id}/", 1)
+		}
+	}
+
+	return strings.NewReplacer("{account_id}", accountID, "{zone_id}", zoneID).Replace(endpoint)
+}
+
+// resourceEndpoint returns the endpoint used to fetch every resource of
+// resourceType. By default, we want to use the `list` operation however, there
+// are times when resources exist only as `get` operations but contain multiple
+// resources.
+func resourceEndpoint(resourceType string) string {
+	if endpoint := resourceToEndpoint[resourceType]["list"]; endpoint != "" {
+		return endpoint
+	}
+
+	return resourceToEndpoint[resourceType]["get"]
+}
+
+// isNotFound reports whether err is a 404 from the Cloudflare API.
+func isNotFound(err error) bool {
+	var apierr *cloudflare.Error
+	return errors.As(err, &apierr) && apierr.StatusCode == http.StatusNotFound
+}
+
 // sanitiseTerraformResourceName ensures that a Terraform resource name matches
 // the restrictions imposed by core.
 func sanitiseTerraformResourceName(s string) string {
@@ -138,6 +178,36 @@ func sanitiseTerraformResourceName(s string) string {
 	return re.ReplaceAllString(s, "_")
 }
 
+// invalidResourceNameChars matches the characters Terraform doesn't permit
+// within a resource name.
+var invalidResourceNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// terraformResourceName builds the name of a generated resource from its ID.
+// IDs can contain characters (such as `/` or `:`) that aren't valid in a
+// resource name so they are replaced to keep the resource and import blocks
+// referencing the same address.
+func terraformResourceName(id string) string {
+	return terraformResourceNamePrefix + "_" + invalidResourceNameChars.ReplaceAllString(id, "_")
+}
+
+// resourceIDFromData returns the identifier used in the Terraform resource
+// name for a single API result. Singleton resources that don't carry their own
+// `id` fall back to the account or zone ID being targeted.
+func resourceIDFromData(data map[string]interface{}) string {
+	switch id := data["id"].(type) {
+	case float64:
+		return fmt.Sprintf("%f", id)
+	case string:
+		return id
+	}
+
+	if zoneID != "" {
+		return zoneID
+	}
+
+	return accountID
+}
+
 // flattenAttrMap takes a list of attributes defined as a list of maps comprising {"id": "attrId", "value": "attrValue"}
 // and flattens it to a single map of {"attrId": "attrValue"}.
 func flattenAttrMap(l []interface{}) map[string]interface{} {
@@ -1,12 +1,14 @@
 package cmd
 
 import (
+	"slices"
 	"sort"
 	"testing"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclparse"
 	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
 	"github.com/zclconf/go-cty/cty"
 )
@@ -355,3 +357,41 @@ func ctyValuesEquivalent(a, b cty.Value) bool {
 
 	return a.RawEquals(b)
 }
+
+func TestCommandFlags(t *testing.T) {
+	tests := map[string][]*cobra.Command{
+		"state-rm":             {orphansCmd, migrateCmd, convertZoneSettingsCmd},
+		"format":               {driftCmd, coverageCmd},
+		"from-state":           {generateCmd},
+		"verify":               {generateCmd},
+		"verify-imports":       {importCommand},
+		"module":               {generateCmd},
+		"merge-into":           {generateCmd},
+		"for-each":             {generateCmd},
+		"for-each-data-dir":    {generateCmd},
+		"explain":              {generateCmd},
+		"parameterize":         {generateCmd},
+		"sensitive-variables":  {generateCmd},
+		"variables-dir":        {generateCmd},
+		"write-secrets":        {generateCmd},
+		"collection-threshold": {generateCmd},
+		"collection-format":    {generateCmd},
+		"collection-dir":       {generateCmd},
+		"exclude-managed":      {generateCmd, importCommand},
+		"ruleset-phase":        {generateCmd, importCommand},
+		"ruleset-kind":         {generateCmd, importCommand},
+	}
+
+	all := rootCmd.Commands()
+	for i := 0; i < len(all); i++ {
+		all = append(all, all[i].Commands()...)
+	}
+
+	for name, commands := range tests {
+		assert.Nil(t, rootCmd.PersistentFlags().Lookup(name), "--%s is registered on the root command", name)
+		for _, c := range all {
+			registered := c.Flags().Lookup(name) != nil
+			assert.Equal(t, slices.Contains(commands, c), registered, "--%s on %s", name, c.Name())
+		}
+	}
+}
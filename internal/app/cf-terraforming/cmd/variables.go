@@ -0,0 +1,354 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// generatedVariable is an input variable that generated configuration
+// references in place of a value that can't be generated.
+type generatedVariable struct {
+	Name        string
+	Type        cty.Type
+	Description string
+	Sensitive   bool
+	// Value is the value the variable replaces, if it's known.
+	Value cty.Value
+
+	// Address and Attribute are the resource attribute set by the variable.
+	Address   string
+	Attribute string
+}
+
+// variableReference is a value within a nested attribute that is replaced by
+// a reference to the named variable.
+type variableReference string
+
+// appendRequiredPlaceholders sets every required attribute that wasn't written
+// from the API response, such as secrets the API never returns, to a
+// reference to a new variable so that the configuration still validates.
+// Nested attributes, found in values, and nested blocks are checked too.
+func appendRequiredPlaceholders(r *tfjson.Schema, resourceType, resourceName string, values map[string]interface{}, body *hclwrite.Body) []generatedVariable {
+	return appendBlockPlaceholders(r.Block, resourceType, resourceName, "", values, body)
+}
+
+// appendBlockPlaceholders adds the required placeholders of
+// appendRequiredPlaceholders for a single block, prefixing the attribute paths
+// with prefix.
+func appendBlockPlaceholders(block *tfjson.SchemaBlock, resourceType, resourceName, prefix string, values map[string]interface{}, body *hclwrite.Body) []generatedVariable {
+	names := make([]string, 0, len(block.Attributes))
+	for name := range block.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var variables []generatedVariable
+	for _, name := range names {
+		attr := block.Attributes[name]
+		if prefix == "" && name == "id" {
+			continue
+		}
+
+		if body.GetAttribute(name) == nil {
+			if !attr.Required {
+				continue
+			}
+			v := placeholderVariable(resourceType, resourceName, prefix+name, attr)
+			body.SetAttributeTraversal(name, variableTraversal(v.Name))
+			variables = append(variables, v)
+			continue
+		}
+
+		if attr.AttributeNestedType == nil || values[name] == nil {
+			continue
+		}
+		value, nested := nestedPlaceholders(attr.AttributeNestedType, resourceType, resourceName, prefix+name, values[name])
+		if len(nested) > 0 {
+			body.SetAttributeRaw(name, configValueTokens(value))
+			variables = append(variables, nested...)
+		}
+	}
+
+	blockNames := make([]string, 0, len(block.NestedBlocks))
+	for name := range block.NestedBlocks {
+		blockNames = append(blockNames, name)
+	}
+	sort.Strings(blockNames)
+
+	for _, name := range blockNames {
+		i := 0
+		for _, b := range body.Blocks() {
+			if b.Type() != name {
+				continue
+			}
+			path := fmt.Sprintf("%s%s.%d.", prefix, name, i)
+			variables = append(variables, appendBlockPlaceholders(block.NestedBlocks[name].Block, resourceType, resourceName, path, nil, b.Body())...)
+			i++
+		}
+	}
+
+	return variables
+}
+
+// nestedPlaceholders returns a copy of the nested attribute value at path
+// with every missing required attribute set to a variable reference.
+func nestedPlaceholders(nested *tfjson.SchemaNestedAttributeType, resourceType, resourceName, path string, value interface{}) (interface{}, []generatedVariable) {
+	var variables []generatedVariable
+
+	switch nested.NestingMode {
+	case tfjson.SchemaNestingModeSingle:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return value, nil
+		}
+		return objectPlaceholders(nested.Attributes, resourceType, resourceName, path, obj)
+	case tfjson.SchemaNestingModeMap:
+		items, ok := value.(map[string]interface{})
+		if !ok {
+			return value, nil
+		}
+		result := make(map[string]interface{}, len(items))
+		for _, k := range sortedKeys(items) {
+			obj, ok := items[k].(map[string]interface{})
+			if !ok {
+				result[k] = items[k]
+				continue
+			}
+			item, vs := objectPlaceholders(nested.Attributes, resourceType, resourceName, path+"."+k, obj)
+			result[k] = item
+			variables = append(variables, vs...)
+		}
+		return result, variables
+	default:
+		var items []interface{}
+		switch s := value.(type) {
+		case []interface{}:
+			items = s
+		case []map[string]interface{}:
+			for _, item := range s {
+				items = append(items, item)
+			}
+		default:
+			return value, nil
+		}
+		result := make([]interface{}, len(items))
+		for i, item := range items {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				result[i] = item
+				continue
+			}
+			item, vs := objectPlaceholders(nested.Attributes, resourceType, resourceName, fmt.Sprintf("%s.%d", path, i), obj)
+			result[i] = item
+			variables = append(variables, vs...)
+		}
+		return result, variables
+	}
+}
+
+// objectPlaceholders returns a copy of a single object within a nested
+// attribute with every missing required attribute set to a variable
+// reference.
+func objectPlaceholders(attributes map[string]*tfjson.SchemaAttribute, resourceType, resourceName, path string, obj map[string]interface{}) (map[string]interface{}, []generatedVariable) {
+	result := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		result[k] = v
+	}
+
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var variables []generatedVariable
+	for _, name := range names {
+		attr := attributes[name]
+		switch {
+		case result[name] == nil && attr.Required:
+			v := placeholderVariable(resourceType, resourceName, path+"."+name, attr)
+			result[name] = variableReference(v.Name)
+			variables = append(variables, v)
+		case result[name] != nil && attr.AttributeNestedType != nil:
+			value, vs := nestedPlaceholders(attr.AttributeNestedType, resourceType, resourceName, path+"."+name, result[name])
+			result[name] = value
+			variables = append(variables, vs...)
+		}
+	}
+
+	return result, variables
+}
+
+// placeholderVariable builds the variable for a required attribute at path
+// that the API didn't return.
+func placeholderVariable(resourceType, resourceName, path string, attr *tfjson.SchemaAttribute) generatedVariable {
+	return generatedVariable{
+		Name:        placeholderVariableName(resourceType, resourceName, strings.ReplaceAll(path, ".", "_")),
+		Type:        attr.AttributeType,
+		Description: fmt.Sprintf("Value of %s for %s.%s. The Cloudflare API doesn't return it so it must be provided.", path, resourceType, resourceName),
+		Sensitive:   attr.Sensitive,
+		Address:     resourceType + "." + resourceName,
+		Attribute:   path,
+	}
+}
+
+// configValueTokens builds the expression for an attribute value that may
+// contain variable references.
+func configValueTokens(value interface{}) hclwrite.Tokens {
+	if !containsVariableReference(value) {
+		return hclwrite.TokensForValue(processExpression(value))
+	}
+
+	switch v := value.(type) {
+	case variableReference:
+		return hclwrite.TokensForTraversal(variableTraversal(string(v)))
+	case map[string]interface{}:
+		attrs := make([]hclwrite.ObjectAttrTokens, 0, len(v))
+		for _, k := range sortedKeys(v) {
+			name := hclwrite.TokensForIdentifier(k)
+			if !hclsyntax.ValidIdentifier(k) {
+				name = hclwrite.TokensForValue(cty.StringVal(k))
+			}
+			attrs = append(attrs, hclwrite.ObjectAttrTokens{Name: name, Value: configValueTokens(v[k])})
+		}
+		return hclwrite.TokensForObject(attrs)
+	case []interface{}:
+		items := make([]hclwrite.Tokens, len(v))
+		for i, item := range v {
+			items[i] = configValueTokens(item)
+		}
+		return hclwrite.TokensForTuple(items)
+	}
+
+	return hclwrite.TokensForValue(processExpression(value))
+}
+
+// containsVariableReference reports whether value or anything nested within
+// it is a variable reference.
+func containsVariableReference(value interface{}) bool {
+	switch v := value.(type) {
+	case variableReference:
+		return true
+	case map[string]interface{}:
+		for _, item := range v {
+			if containsVariableReference(item) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if containsVariableReference(item) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// placeholderVariableName builds a variable name unique to a resource
+// attribute.
+func placeholderVariableName(resourceType, resourceName, attribute string) string {
+	return sanitiseTerraformResourceName(strings.TrimPrefix(resourceType, "cloudflare_") + "_" + resourceName + "_" + attribute)
+}
+
+// variableTraversal is a `var.<name>` reference.
+func variableTraversal(name string) hcl.Traversal {
+	return hcl.Traversal{
+		hcl.TraverseRoot{Name: "var"},
+		hcl.TraverseAttr{Name: name},
+	}
+}
+
+// appendVariableBlock outputs the `variable` block declaring v.
+func appendVariableBlock(body *hclwrite.Body, v generatedVariable) {
+	block := body.AppendNewBlock("variable", []string{v.Name}).Body()
+	if v.Type != cty.NilType {
+		block.SetAttributeRaw("type", hclwrite.Tokens{{Type: hclsyntax.TokenIdent, Bytes: []byte(typeexpr.TypeString(v.Type))}})
+	}
+	block.SetAttributeValue("description", cty.StringVal(v.Description))
+	if v.Sensitive {
+		block.SetAttributeValue("sensitive", cty.True)
+	}
+}
+
+// renderResource writes the body of a single resource. Sensitive values are
+// swapped for variables when using `--sensitive-variables` and required
+// attributes without a value reference a variable instead.
+func renderResource(r *tfjson.Schema, resourceType, resourceName string, structData map[string]interface{}, body *hclwrite.Body) []generatedVariable {
+	var sv sensitiveValues
+	if useSensitiveVariables {
+		sv = extractSensitiveValues(r, resourceType, resourceName, structData)
+	}
+
+	writeResourceBody(r, structData, body)
+	writeSensitiveReferences(body, sv)
+
+	values := make(map[string]interface{}, len(structData)+len(sv.objects))
+	for k, v := range structData {
+		values[k] = v
+	}
+	for k, v := range sv.objects {
+		values[k] = v
+	}
+
+	return append(sv.variables, appendRequiredPlaceholders(r, resourceType, resourceName, values, body)...)
+}
+
+// writeGeneratedVariables writes the variables referenced by generated
+// configuration to variables.tf, and their values to secrets.auto.tfvars,
+// when using `--sensitive-variables`. Otherwise the variable blocks have
+// already been output alongside the resources.
+func writeGeneratedVariables(w io.Writer, variables []generatedVariable) {
+	if useSensitiveVariables && len(variables) > 0 {
+		if err := writeVariablesFile(variablesDir, variables); err != nil {
+			log.Fatalf("failed to write %s: %s", variablesFilename, err)
+		}
+		if writeSecrets {
+			if err := writeSecretsFile(variablesDir, variables); err != nil {
+				log.Fatalf("failed to write %s: %s", secretsFilename, err)
+			}
+		}
+	}
+
+	writeRequiredPlaceholders(w, variables)
+}
+
+// writeRequiredPlaceholders lists the attributes that reference a variable
+// without a known value, as the API didn't return it.
+func writeRequiredPlaceholders(w io.Writer, variables []generatedVariable) {
+	var unknown []generatedVariable
+	for _, v := range variables {
+		if v.Value.IsNull() {
+			unknown = append(unknown, v)
+		}
+	}
+	if len(unknown) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "%d attributes weren't returned by the API and reference a variable that must be set:\n", len(unknown))
+	for _, v := range unknown {
+		fmt.Fprintf(w, "  %s.%s = var.%s\n", v.Address, v.Attribute, v.Name)
+	}
+}
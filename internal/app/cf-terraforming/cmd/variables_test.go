@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestAppendRequiredPlaceholders(t *testing.T) {
+	schema := &tfjson.Schema{
+		Block: &tfjson.SchemaBlock{
+			Attributes: map[string]*tfjson.SchemaAttribute{
+				"id":            {AttributeType: cty.String, Computed: true},
+				"account_id":    {AttributeType: cty.String, Required: true},
+				"name":          {AttributeType: cty.String, Required: true},
+				"tunnel_secret": {AttributeType: cty.String, Required: true, Sensitive: true},
+				"config_src":    {AttributeType: cty.String, Optional: true},
+			},
+		},
+	}
+
+	f := hclwrite.NewEmptyFile()
+	resource := f.Body().AppendNewBlock("resource", []string{"cloudflare_zero_trust_tunnel_cloudflared", "terraform_managed_resource_abc"}).Body()
+	structData := map[string]interface{}{
+		"id":         "abc",
+		"account_id": "f037e56e89293a057740de681ac9abbe",
+		"name":       "blog",
+	}
+	writeResourceBody(schema, structData, resource)
+
+	variables := appendRequiredPlaceholders(schema, "cloudflare_zero_trust_tunnel_cloudflared", "terraform_managed_resource_abc", structData, resource)
+	f.Body().AppendNewline()
+	for _, v := range variables {
+		appendVariableBlock(f.Body(), v)
+	}
+
+	assert.Equal(t, `resource "cloudflare_zero_trust_tunnel_cloudflared" "terraform_managed_resource_abc" {
+  account_id    = "f037e56e89293a057740de681ac9abbe"
+  name          = "blog"
+  tunnel_secret = var.zero_trust_tunnel_cloudflared_terraform_managed_resource_abc_tunnel_secret
+}
+
+variable "zero_trust_tunnel_cloudflared_terraform_managed_resource_abc_tunnel_secret" {
+  type        = string
+  description = "Value of tunnel_secret for cloudflare_zero_trust_tunnel_cloudflared.terraform_managed_resource_abc. The Cloudflare API doesn't return it so it must be provided."
+  sensitive   = true
+}
+`, string(hclwrite.Format(f.Bytes())))
+
+	var buf bytes.Buffer
+	writeRequiredPlaceholders(&buf, variables)
+	assert.Equal(t, `1 attributes weren't returned by the API and reference a variable that must be set:
+  cloudflare_zero_trust_tunnel_cloudflared.terraform_managed_resource_abc.tunnel_secret = var.zero_trust_tunnel_cloudflared_terraform_managed_resource_abc_tunnel_secret
+`, buf.String())
+}
+
+func TestAppendRequiredPlaceholders_Nested(t *testing.T) {
+	schema := &tfjson.Schema{
+		Block: &tfjson.SchemaBlock{
+			Attributes: map[string]*tfjson.SchemaAttribute{
+				"id":         {AttributeType: cty.String, Computed: true},
+				"account_id": {AttributeType: cty.String, Required: true},
+				"config": {
+					Required: true,
+					AttributeNestedType: &tfjson.SchemaNestedAttributeType{
+						NestingMode: tfjson.SchemaNestingModeSingle,
+						Attributes: map[string]*tfjson.SchemaAttribute{
+							"client_id":     {AttributeType: cty.String, Optional: true},
+							"client_secret": {AttributeType: cty.String, Required: true, Sensitive: true},
+						},
+					},
+				},
+				"headers": {
+					Optional: true,
+					AttributeNestedType: &tfjson.SchemaNestedAttributeType{
+						NestingMode: tfjson.SchemaNestingModeList,
+						Attributes: map[string]*tfjson.SchemaAttribute{
+							"name":  {AttributeType: cty.String, Required: true},
+							"value": {AttributeType: cty.String, Required: true},
+						},
+					},
+				},
+			},
+			NestedBlocks: map[string]*tfjson.SchemaBlockType{
+				"origin": {
+					NestingMode: tfjson.SchemaNestingModeList,
+					Block: &tfjson.SchemaBlock{
+						Attributes: map[string]*tfjson.SchemaAttribute{
+							"address": {AttributeType: cty.String, Optional: true},
+							"token":   {AttributeType: cty.String, Required: true},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	f := hclwrite.NewEmptyFile()
+	resource := f.Body().AppendNewBlock("resource", []string{"cloudflare_zero_trust_access_identity_provider", "a"}).Body()
+	structData := map[string]interface{}{
+		"id":         "abc",
+		"account_id": "f037e56e89293a057740de681ac9abbe",
+		"config": map[string]interface{}{
+			"client_id": "example",
+		},
+		"headers": []interface{}{
+			map[string]interface{}{"name": "x-example", "value": "1"},
+			map[string]interface{}{"name": "x-secret"},
+		},
+		"origin": []interface{}{
+			map[string]interface{}{"address": "192.0.2.1"},
+		},
+	}
+	writeResourceBody(schema, structData, resource)
+
+	variables := appendRequiredPlaceholders(schema, "cloudflare_zero_trust_access_identity_provider", "a", structData, resource)
+
+	assert.Equal(t, `resource "cloudflare_zero_trust_access_identity_provider" "a" {
+  account_id = "f037e56e89293a057740de681ac9abbe"
+  config = {
+    client_id     = "example"
+    client_secret = var.zero_trust_access_identity_provider_a_config_client_secret
+  }
+  headers = [{
+    name  = "x-example"
+    value = "1"
+    }, {
+    name  = "x-secret"
+    value = var.zero_trust_access_identity_provider_a_headers_1_value
+  }]
+  origin {
+    address = "192.0.2.1"
+    token   = var.zero_trust_access_identity_provider_a_origin_0_token
+  }
+}
+`, string(hclwrite.Format(f.Bytes())))
+
+	attributes := make([]string, len(variables))
+	for i, v := range variables {
+		attributes[i] = v.Attribute
+	}
+	assert.Equal(t, []string{"config.client_secret", "headers.1.value", "origin.0.token"}, attributes)
+	assert.True(t, variables[0].Sensitive)
+}